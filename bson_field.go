@@ -0,0 +1,121 @@
+package mongodbr
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// struct tag used by mongodbr
+	tagName = "mongodbr"
+)
+
+var (
+	_timeType     = reflect.TypeOf(time.Time{})
+	_objectIdType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// bson tag info of a struct field
+type bsonFieldTag struct {
+	name      string
+	skip      bool
+	inline    bool
+	omitEmpty bool
+}
+
+// parse the bson tag of a struct field, follow the rules of the bson codec
+func parseBsonFieldTag(field reflect.StructField) bsonFieldTag {
+	tag := bsonFieldTag{}
+	value, ok := field.Tag.Lookup("bson")
+	if !ok && !strings.Contains(string(field.Tag), ":") && len(field.Tag) > 0 {
+		value = string(field.Tag)
+	}
+	if value == "-" {
+		tag.skip = true
+		return tag
+	}
+	parts := strings.Split(value, ",")
+	tag.name = parts[0]
+	for _, eachPart := range parts[1:] {
+		switch eachPart {
+		case "inline":
+			tag.inline = true
+		case "omitempty":
+			tag.omitEmpty = true
+		}
+	}
+	if len(tag.name) <= 0 {
+		tag.name = strings.ToLower(field.Name)
+	}
+	return tag
+}
+
+// split the mongodbr tag into directives,directives are separated by ';'
+func parseTagDirectives(field reflect.StructField) []string {
+	value, ok := field.Tag.Lookup(tagName)
+	if !ok || len(value) <= 0 {
+		return nil
+	}
+	directiveList := make([]string, 0)
+	for _, eachDirective := range strings.Split(value, ";") {
+		eachDirective = strings.TrimSpace(eachDirective)
+		if len(eachDirective) > 0 {
+			directiveList = append(directiveList, eachDirective)
+		}
+	}
+	return directiveList
+}
+
+// walk all exported fields of struct type t, embedded and nested structs are flattened
+// into dotted paths the same way they are stored in mongodb
+func walkBsonFields(t reflect.Type, fn func(path string, field reflect.StructField)) {
+	walkBsonFieldsWithPrefix(t, "", fn, map[reflect.Type]bool{})
+}
+
+func walkBsonFieldsWithPrefix(t reflect.Type, prefix string, fn func(path string, field reflect.StructField), visiting map[reflect.Type]bool) {
+	t = indirectType(t)
+	if t.Kind() != reflect.Struct || visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := parseBsonFieldTag(field)
+		if tag.skip {
+			continue
+		}
+		fieldType := indirectType(field.Type)
+		if tag.inline && fieldType.Kind() == reflect.Struct {
+			walkBsonFieldsWithPrefix(fieldType, prefix, fn, visiting)
+			continue
+		}
+		path := prefix + tag.name
+		fn(path, field)
+		if isNestedDocumentType(fieldType) {
+			walkBsonFieldsWithPrefix(fieldType, path+".", fn, visiting)
+		}
+	}
+}
+
+// whether the type is stored as an embedded document
+func isNestedDocumentType(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	return t != _timeType && t.PkgPath() != _objectIdType.PkgPath()
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
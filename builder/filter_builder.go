@@ -0,0 +1,89 @@
+package builder
+
+import "go.mongodb.org/mongo-driver/bson"
+
+// build a query filter document
+type FilterBuilder struct {
+	filter bson.M
+}
+
+func NewFilterBuilder() *FilterBuilder {
+	return &FilterBuilder{
+		filter: bson.M{},
+	}
+}
+
+// field equals value
+func (b *FilterBuilder) Eq(fieldName string, value interface{}) *FilterBuilder {
+	return b.Where(fieldName, Op_Eq(), value)
+}
+
+// append a {fieldName:{op:value}} condition,conditions on the same field are merged
+func (b *FilterBuilder) Where(fieldName string, op *Op, value interface{}) *FilterBuilder {
+	if len(fieldName) <= 0 || op == nil {
+		return b
+	}
+	condition, ok := b.filter[fieldName].(bson.M)
+	if !ok {
+		condition = bson.M{}
+		b.filter[fieldName] = condition
+	}
+	condition[op.String()] = value
+	return b
+}
+
+// join the filters with $and
+func (b *FilterBuilder) And(filters ...*FilterBuilder) *FilterBuilder {
+	return b.logical(Op_And(), filters)
+}
+
+// join the filters with $or
+func (b *FilterBuilder) Or(filters ...*FilterBuilder) *FilterBuilder {
+	return b.logical(Op_Or(), filters)
+}
+
+// join the filters with $nor
+func (b *FilterBuilder) Nor(filters ...*FilterBuilder) *FilterBuilder {
+	return b.logical(Op_Nor(), filters)
+}
+
+func (b *FilterBuilder) logical(op *Op, filters []*FilterBuilder) *FilterBuilder {
+	if len(filters) <= 0 {
+		return b
+	}
+	list, _ := b.filter[op.String()].(bson.A)
+	for _, eachFilter := range filters {
+		if eachFilter == nil || len(eachFilter.filter) <= 0 {
+			continue
+		}
+		list = append(list, eachFilter.ToValue())
+	}
+	if len(list) > 0 {
+		b.filter[op.String()] = list
+	}
+	return b
+}
+
+// copy of the filter,the later calls of the builder do not change it
+func (b *FilterBuilder) ToValue() bson.M {
+	return copyFilterValue(b.filter).(bson.M)
+}
+
+// copy the documents and arrays built by the builder,the other values are shared
+func copyFilterValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case bson.M:
+		result := make(bson.M, len(value))
+		for eachKey, eachValue := range value {
+			result[eachKey] = copyFilterValue(eachValue)
+		}
+		return result
+	case bson.A:
+		result := make(bson.A, 0, len(value))
+		for _, eachValue := range value {
+			result = append(result, copyFilterValue(eachValue))
+		}
+		return result
+	}
+	return v
+}
@@ -0,0 +1,25 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestFilterBuilderToValueCopy(t *testing.T) {
+	b := NewFilterBuilder().Where("age", Op_Gte(), 18).Or(NewFilterBuilder().Eq("name", "a"))
+	filter := b.ToValue()
+	expect := bson.M{
+		"age": bson.M{"$gte": 18},
+		"$or": bson.A{bson.M{"name": bson.M{"$eq": "a"}}},
+	}
+	if !reflect.DeepEqual(filter, expect) {
+		t.Fatalf("expect %v,got %v", expect, filter)
+	}
+
+	b.Where("age", Op_Lt(), 60).Or(NewFilterBuilder().Eq("name", "b")).Eq("city", "x")
+	if !reflect.DeepEqual(filter, expect) {
+		t.Fatalf("the filter handed out must not change,got %v", filter)
+	}
+}
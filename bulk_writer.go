@@ -0,0 +1,499 @@
+package mongodbr
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	mongodbrerr "github.com/shanluzhineng/mongodbr/err"
+)
+
+var (
+	ErrBulkWriterClosed = errors.New("bulk writer is closed")
+	// an ordered writer stops at the first failed item
+	ErrBulkWriterStopped = errors.New("bulk writer is stopped by a failed ordered write")
+)
+
+// counts of the items written by a BulkWriter
+type BulkWriteProgress struct {
+	// items added to the writer
+	Submitted int64
+	// items sent to the server,including the failed ones
+	Processed int64
+	Failed    int64
+	// items not written because an ordered write failed before them
+	Skipped int64
+	Batches int64
+
+	Inserted int64
+	Matched  int64
+	Modified int64
+	Deleted  int64
+	Upserted int64
+}
+
+// a write error mapped back to the item
+type BulkWriteItemError struct {
+	// position of the item in the order of Add,starting from 0
+	Index int64
+	// the key given to AddWithKey,nil for Add
+	Key   interface{}
+	Model mongo.WriteModel
+	// the classified write error,such as mongodbrerr.IsDuplicateKey(Err)
+	Err error
+}
+
+func (e *BulkWriteItemError) Error() string {
+	if e.Key != nil {
+		return fmt.Sprintf("item %d(%v): %s", e.Index, e.Key, e.Err.Error())
+	}
+	return fmt.Sprintf("item %d: %s", e.Index, e.Err.Error())
+}
+
+func (e *BulkWriteItemError) Unwrap() error {
+	return e.Err
+}
+
+// the errors of the batches flushed by a BulkWriter
+type BulkWriterError struct {
+	ItemErrors []*BulkWriteItemError
+	// errors failing a whole batch,such as a network error
+	BatchErrors []error
+}
+
+func (e *BulkWriterError) Error() string {
+	message := fmt.Sprintf("bulk write failed: %d item errors, %d batch errors", len(e.ItemErrors), len(e.BatchErrors))
+	if len(e.ItemErrors) > 0 {
+		return message + ", first: " + e.ItemErrors[0].Error()
+	}
+	return message + ", first: " + e.BatchErrors[0].Error()
+}
+
+func (e *BulkWriterError) Unwrap() []error {
+	errList := make([]error, 0, len(e.ItemErrors)+len(e.BatchErrors))
+	for _, eachErr := range e.ItemErrors {
+		errList = append(errList, eachErr)
+	}
+	return append(errList, e.BatchErrors...)
+}
+
+type bulkWriterOptions struct {
+	batchSize     int
+	flushInterval time.Duration
+	concurrency   int
+	ordered       bool
+	onProgress    func(BulkWriteProgress)
+}
+
+type BulkWriterOption func(*bulkWriterOptions)
+
+// flush when n items are pending,default is 500
+func BulkWriterWithBatchSize(n int) BulkWriterOption {
+	return func(o *bulkWriterOptions) {
+		if n > 0 {
+			o.batchSize = n
+		}
+	}
+}
+
+// flush the pending items every d,default is 0 which flushes only when the batch is full
+func BulkWriterWithFlushInterval(d time.Duration) BulkWriterOption {
+	return func(o *bulkWriterOptions) {
+		o.flushInterval = d
+	}
+}
+
+// run at most n unordered batches at the same time,default is 1
+func BulkWriterWithConcurrency(n int) BulkWriterOption {
+	return func(o *bulkWriterOptions) {
+		if n > 0 {
+			o.concurrency = n
+		}
+	}
+}
+
+// write the items in the order of Add and stop at the first error,the batches run one by one in
+// the order they are taken.after an error the following items are skipped and Add returns
+// ErrBulkWriterStopped.default is unordered
+func BulkWriterWithOrdered(ordered bool) BulkWriterOption {
+	return func(o *bulkWriterOptions) {
+		o.ordered = ordered
+	}
+}
+
+// call fn after each batch,the calls are serialized
+func BulkWriterWithProgress(fn func(BulkWriteProgress)) BulkWriterOption {
+	return func(o *bulkWriterOptions) {
+		o.onProgress = fn
+	}
+}
+
+type bulkWriteItem struct {
+	index int64
+	key   interface{}
+	model mongo.WriteModel
+}
+
+// BulkWriter collects the write models and sends them in batches by BulkWrite,it is safe for
+// concurrent use.Close must be called to write the last batch
+type BulkWriter struct {
+	mongoCol *MongoCol
+	options  *bulkWriterOptions
+	//BulkWrite of mongoCol
+	bulkWrite func(models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error)
+
+	mu       sync.Mutex
+	pending  []bulkWriteItem
+	progress BulkWriteProgress
+	err      *BulkWriterError
+	closed   bool
+
+	progressMu sync.Mutex
+	semaphore  chan struct{}
+	//the batches taken and not written yet,guarded by mu.a WaitGroup cannot be used as Add and
+	//Wait are called concurrently by Add and Flush
+	inflight     int
+	inflightCond *sync.Cond
+	stop         chan struct{}
+	stopped      chan struct{}
+
+	//the ordered batches waiting for the writing goroutine,guarded by mu
+	queue [][]bulkWriteItem
+	//signaled when a batch is queued or started
+	queueCond *sync.Cond
+	queued    int64
+	started   int64
+	//an ordered write failed,the following items are skipped
+	failed     bool
+	workerStop bool
+	workerDone chan struct{}
+}
+
+func (r *MongoCol) NewBulkWriter(opts ...BulkWriterOption) *BulkWriter {
+	o := &bulkWriterOptions{
+		batchSize:   500,
+		concurrency: 1,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	if o.ordered {
+		o.concurrency = 1
+	}
+	w := &BulkWriter{
+		mongoCol:  r,
+		options:   o,
+		pending:   make([]bulkWriteItem, 0, o.batchSize),
+		semaphore: make(chan struct{}, o.concurrency),
+	}
+	w.bulkWrite = r.BulkWrite
+	w.queueCond = sync.NewCond(&w.mu)
+	w.inflightCond = sync.NewCond(&w.mu)
+	if o.ordered {
+		w.workerDone = make(chan struct{})
+		go w.writeInOrder()
+	}
+	if o.flushInterval > 0 {
+		w.stop = make(chan struct{})
+		w.stopped = make(chan struct{})
+		go w.flushPeriodically()
+	}
+	return w
+}
+
+// add a model such as mongo.NewInsertOneModel().SetDocument(doc),it blocks when the batch is
+// full and the concurrency limit is reached
+func (w *BulkWriter) Add(model mongo.WriteModel) error {
+	return w.AddWithKey(nil, model)
+}
+
+// add a model with the key reported by the BulkWriteItemError of the model,such as the line
+// number of an imported file
+func (w *BulkWriter) AddWithKey(key interface{}, model mongo.WriteModel) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrBulkWriterClosed
+	}
+	if w.failed {
+		w.mu.Unlock()
+		return ErrBulkWriterStopped
+	}
+	w.pending = append(w.pending, bulkWriteItem{
+		index: w.progress.Submitted,
+		key:   key,
+		model: model,
+	})
+	w.progress.Submitted++
+	var batch []bulkWriteItem
+	if len(w.pending) >= w.options.batchSize {
+		batch = w.takePending()
+	}
+	w.runAndUnlock(batch)
+	return nil
+}
+
+// write the pending items and wait for the running batches,the errors since the last Flush are
+// returned as *BulkWriterError
+func (w *BulkWriter) Flush() error {
+	w.mu.Lock()
+	w.runAndUnlock(w.takePending())
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.inflight > 0 {
+		w.inflightCond.Wait()
+	}
+	if w.err == nil {
+		return nil
+	}
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// flush and stop the writer,the following Add returns ErrBulkWriterClosed
+func (w *BulkWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	if w.stop != nil {
+		close(w.stop)
+		<-w.stopped
+	}
+	err := w.Flush()
+	if w.workerDone != nil {
+		w.mu.Lock()
+		w.workerStop = true
+		w.queueCond.Broadcast()
+		w.mu.Unlock()
+		<-w.workerDone
+	}
+	return err
+}
+
+// the counts so far
+func (w *BulkWriter) Progress() BulkWriteProgress {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.progress
+}
+
+func (w *BulkWriter) flushPeriodically() {
+	defer close(w.stopped)
+	ticker := time.NewTicker(w.options.flushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			w.runAndUnlock(w.takePending())
+		}
+	}
+}
+
+// must be called with w.mu held,the batch is counted as running so that Flush waits for it
+func (w *BulkWriter) takePending() []bulkWriteItem {
+	if len(w.pending) <= 0 {
+		return nil
+	}
+	batch := w.pending
+	w.pending = make([]bulkWriteItem, 0, w.options.batchSize)
+	w.inflight++
+	return batch
+}
+
+// mark a batch taken by takePending as written
+func (w *BulkWriter) done() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.inflight--
+	if w.inflight <= 0 {
+		w.inflightCond.Broadcast()
+	}
+}
+
+// run the batch,must be called with w.mu held which is released.
+// the ordered batches are queued in the order they are taken and it waits until the batch starts,
+// as dispatch waits for the concurrency limit
+func (w *BulkWriter) runAndUnlock(batch []bulkWriteItem) {
+	if !w.options.ordered {
+		w.mu.Unlock()
+		w.dispatch(batch)
+		return
+	}
+	if len(batch) > 0 {
+		w.queue = append(w.queue, batch)
+		w.queued++
+		seq := w.queued
+		w.queueCond.Broadcast()
+		for w.started < seq {
+			w.queueCond.Wait()
+		}
+	}
+	w.mu.Unlock()
+}
+
+// write the queued ordered batches one by one until Close,the batches after a failure are skipped
+func (w *BulkWriter) writeInOrder() {
+	defer close(w.workerDone)
+	w.mu.Lock()
+	for {
+		for len(w.queue) <= 0 && !w.workerStop {
+			w.queueCond.Wait()
+		}
+		if len(w.queue) <= 0 {
+			w.mu.Unlock()
+			return
+		}
+		batch := w.queue[0]
+		w.queue = w.queue[1:]
+		w.started++
+		w.queueCond.Broadcast()
+		skip := w.failed
+		if skip {
+			w.progress.Skipped += int64(len(batch))
+		}
+		w.mu.Unlock()
+
+		if !skip {
+			w.write(batch)
+		}
+		w.done()
+		w.mu.Lock()
+	}
+}
+
+// run the batch in the background once the concurrency allows
+func (w *BulkWriter) dispatch(batch []bulkWriteItem) {
+	if len(batch) <= 0 {
+		return
+	}
+	w.semaphore <- struct{}{}
+	go func() {
+		defer w.done()
+		defer func() { <-w.semaphore }()
+		w.write(batch)
+	}()
+}
+
+func (w *BulkWriter) write(batch []bulkWriteItem) {
+	modelList := make([]mongo.WriteModel, 0, len(batch))
+	for _, eachItem := range batch {
+		modelList = append(modelList, eachItem.model)
+	}
+	result, err := w.bulkWrite(modelList, options.BulkWrite().SetOrdered(w.options.ordered))
+	batchResult := newBulkBatchResult(batch, w.options.ordered, result, err)
+
+	w.mu.Lock()
+	w.progress.add(batchResult.progress)
+	if batchResult.failed() {
+		if w.options.ordered {
+			w.failed = true
+		}
+		if w.err == nil {
+			w.err = &BulkWriterError{}
+		}
+		w.err.ItemErrors = append(w.err.ItemErrors, batchResult.itemErrList...)
+		if batchResult.batchErr != nil {
+			w.err.BatchErrors = append(w.err.BatchErrors, batchResult.batchErr)
+		}
+	}
+	w.mu.Unlock()
+
+	if w.options.onProgress != nil {
+		w.progressMu.Lock()
+		defer w.progressMu.Unlock()
+		w.options.onProgress(w.Progress())
+	}
+}
+
+// the outcome of a batch written by BulkWrite
+type bulkBatchResult struct {
+	//the counts of the batch,Submitted is not included
+	progress    BulkWriteProgress
+	itemErrList []*BulkWriteItemError
+	//the error failing the whole batch or the write concern error
+	batchErr error
+}
+
+// map the result and the error of BulkWrite back to the items of the batch
+func newBulkBatchResult(batch []bulkWriteItem, ordered bool, result *mongo.BulkWriteResult, err error) *bulkBatchResult {
+	r := &bulkBatchResult{
+		itemErrList: make([]*BulkWriteItemError, 0),
+	}
+	//items sent to the server,an ordered write stops after the first failed item
+	attempted := len(batch)
+	var bulkWriteException mongo.BulkWriteException
+	if errors.As(err, &bulkWriteException) && len(bulkWriteException.WriteErrors) > 0 {
+		for _, eachWriteError := range bulkWriteException.WriteErrors {
+			if eachWriteError.Index < 0 || eachWriteError.Index >= len(batch) {
+				continue
+			}
+			if ordered && eachWriteError.Index+1 < attempted {
+				attempted = eachWriteError.Index + 1
+			}
+			item := batch[eachWriteError.Index]
+			r.itemErrList = append(r.itemErrList, &BulkWriteItemError{
+				Index: item.index,
+				Key:   item.key,
+				Model: item.model,
+				//classified as a bulk write exception of the item
+				Err: mongodbrerr.Wrap(mongo.BulkWriteException{
+					WriteErrors: []mongo.BulkWriteError{eachWriteError},
+					Labels:      bulkWriteException.Labels,
+				}),
+			})
+		}
+		if bulkWriteException.WriteConcernError != nil {
+			r.batchErr = bulkWriteException.WriteConcernError
+		}
+	} else if err != nil {
+		r.batchErr = err
+	}
+
+	r.progress.Batches = 1
+	r.progress.Processed = int64(attempted)
+	r.progress.Skipped = int64(len(batch) - attempted)
+	if r.batchErr != nil && len(r.itemErrList) <= 0 {
+		r.progress.Failed = int64(len(batch))
+	} else {
+		r.progress.Failed = int64(len(r.itemErrList))
+	}
+	if result != nil {
+		r.progress.Inserted = result.InsertedCount
+		r.progress.Matched = result.MatchedCount
+		r.progress.Modified = result.ModifiedCount
+		r.progress.Deleted = result.DeletedCount
+		r.progress.Upserted = result.UpsertedCount
+	}
+	return r
+}
+
+func (r *bulkBatchResult) failed() bool {
+	return len(r.itemErrList) > 0 || r.batchErr != nil
+}
+
+func (p *BulkWriteProgress) add(other BulkWriteProgress) {
+	p.Submitted += other.Submitted
+	p.Processed += other.Processed
+	p.Failed += other.Failed
+	p.Skipped += other.Skipped
+	p.Batches += other.Batches
+	p.Inserted += other.Inserted
+	p.Matched += other.Matched
+	p.Modified += other.Modified
+	p.Deleted += other.Deleted
+	p.Upserted += other.Upserted
+}
@@ -0,0 +1,184 @@
+package mongodbr
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	mongodbrerr "github.com/shanluzhineng/mongodbr/err"
+)
+
+func newBulkWriteTestBatch(n int) []bulkWriteItem {
+	batch := make([]bulkWriteItem, 0, n)
+	for i := 0; i < n; i++ {
+		batch = append(batch, bulkWriteItem{
+			//the batch is the second one of the writer
+			index: int64(10 + i),
+			key:   i,
+			model: mongo.NewInsertOneModel(),
+		})
+	}
+	return batch
+}
+
+func newBulkWriteTestError(index int, code int) mongo.BulkWriteError {
+	return mongo.BulkWriteError{WriteError: mongo.WriteError{Index: index, Code: code, Message: "failed"}}
+}
+
+func TestNewBulkBatchResult(t *testing.T) {
+	writeConcernError := &mongo.WriteConcernError{Code: 64, Message: "waiting for replication timed out"}
+	networkError := errors.New("connection reset")
+	testCases := []struct {
+		name       string
+		ordered    bool
+		result     *mongo.BulkWriteResult
+		err        error
+		expect     BulkWriteProgress
+		itemIndex  []int64
+		batchErr   error
+		duplicated bool
+	}{
+		{
+			name:   "succeeded",
+			result: &mongo.BulkWriteResult{InsertedCount: 4},
+			expect: BulkWriteProgress{Batches: 1, Processed: 4, Inserted: 4},
+		},
+		{
+			name:       "ordered stops at the failed item",
+			ordered:    true,
+			result:     &mongo.BulkWriteResult{InsertedCount: 1},
+			err:        mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{newBulkWriteTestError(1, 11000)}},
+			expect:     BulkWriteProgress{Batches: 1, Processed: 2, Skipped: 2, Failed: 1, Inserted: 1},
+			itemIndex:  []int64{11},
+			duplicated: true,
+		},
+		{
+			name:   "unordered with several errors",
+			result: &mongo.BulkWriteResult{InsertedCount: 2},
+			err: mongo.BulkWriteException{WriteErrors: []mongo.BulkWriteError{
+				newBulkWriteTestError(0, 11000),
+				newBulkWriteTestError(2, 121),
+				//not an item of the batch
+				newBulkWriteTestError(9, 121),
+			}},
+			expect:     BulkWriteProgress{Batches: 1, Processed: 4, Failed: 2, Inserted: 2},
+			itemIndex:  []int64{10, 12},
+			duplicated: true,
+		},
+		{
+			name:     "write concern error",
+			result:   &mongo.BulkWriteResult{InsertedCount: 4},
+			err:      mongo.BulkWriteException{WriteConcernError: writeConcernError},
+			expect:   BulkWriteProgress{Batches: 1, Processed: 4, Failed: 4, Inserted: 4},
+			batchErr: mongo.BulkWriteException{WriteConcernError: writeConcernError},
+		},
+		{
+			name:    "ordered write error with write concern error",
+			ordered: true,
+			result:  &mongo.BulkWriteResult{InsertedCount: 2},
+			err: mongo.BulkWriteException{
+				WriteErrors:       []mongo.BulkWriteError{newBulkWriteTestError(2, 11000)},
+				WriteConcernError: writeConcernError,
+			},
+			expect:     BulkWriteProgress{Batches: 1, Processed: 3, Skipped: 1, Failed: 1, Inserted: 2},
+			itemIndex:  []int64{12},
+			batchErr:   writeConcernError,
+			duplicated: true,
+		},
+		{
+			name:     "network error",
+			err:      networkError,
+			expect:   BulkWriteProgress{Batches: 1, Processed: 4, Failed: 4},
+			batchErr: networkError,
+		},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			batch := newBulkWriteTestBatch(4)
+			r := newBulkBatchResult(batch, eachCase.ordered, eachCase.result, eachCase.err)
+			if r.progress != eachCase.expect {
+				t.Fatalf("expect %+v,got %+v", eachCase.expect, r.progress)
+			}
+			itemIndex := make([]int64, 0)
+			for _, eachErr := range r.itemErrList {
+				itemIndex = append(itemIndex, eachErr.Index)
+				if eachErr.Key != batch[eachErr.Index-10].key || eachErr.Model != batch[eachErr.Index-10].model {
+					t.Fatalf("item error %d is not mapped to its item", eachErr.Index)
+				}
+			}
+			if len(eachCase.itemIndex) <= 0 {
+				eachCase.itemIndex = []int64{}
+			}
+			if !reflect.DeepEqual(itemIndex, eachCase.itemIndex) {
+				t.Fatalf("expect item errors %v,got %v", eachCase.itemIndex, itemIndex)
+			}
+			if eachCase.duplicated && !mongodbrerr.IsDuplicateKey(r.itemErrList[0]) {
+				t.Fatalf("expect the first item error classified as duplicate key,got %v", r.itemErrList[0])
+			}
+			if !reflect.DeepEqual(r.batchErr, eachCase.batchErr) {
+				t.Fatalf("expect batch error %v,got %v", eachCase.batchErr, r.batchErr)
+			}
+			if r.failed() != (eachCase.err != nil) {
+				t.Fatalf("expect failed %v", eachCase.err != nil)
+			}
+		})
+	}
+}
+
+func TestBulkWriterConcurrentAddAndFlush(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []BulkWriterOption
+	}{
+		{"unordered", []BulkWriterOption{BulkWriterWithBatchSize(7), BulkWriterWithConcurrency(3)}},
+		{"ordered", []BulkWriterOption{BulkWriterWithBatchSize(7), BulkWriterWithOrdered(true)}},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			var mu sync.Mutex
+			written := 0
+			w := (&MongoCol{}).NewBulkWriter(eachCase.opts...)
+			w.bulkWrite = func(models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				written += len(models)
+				return &mongo.BulkWriteResult{InsertedCount: int64(len(models))}, nil
+			}
+
+			const goroutines, perGoroutine = 8, 50
+			var wg sync.WaitGroup
+			for i := 0; i < goroutines; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < perGoroutine; j++ {
+						if err := w.Add(mongo.NewInsertOneModel()); err != nil {
+							t.Error(err)
+							return
+						}
+						if j%10 == 0 {
+							if err := w.Flush(); err != nil {
+								t.Error(err)
+								return
+							}
+						}
+					}
+				}()
+			}
+			wg.Wait()
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			progress := w.Progress()
+			total := int64(goroutines * perGoroutine)
+			if progress.Submitted != total || progress.Processed != total || progress.Inserted != total || int64(written) != total {
+				t.Fatalf("expect %d items written,got %+v and %d written", total, progress, written)
+			}
+		})
+	}
+}
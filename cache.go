@@ -0,0 +1,563 @@
+package mongodbr
+
+import (
+	"container/list"
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// default is 10000 entries
+	DefaultLRUCacheCapacity = 10000
+
+	cacheKindId     = "id"
+	cacheKindFilter = "filter"
+)
+
+var (
+	// time to live of the cached documents,default is 1 minute
+	DefaultCacheTTL = time.Minute
+)
+
+// Cache is the backend of CachedRepository,the values are raw bson documents.
+// it must be safe for concurrent use,it can be shared by the repositories of a collection,such as
+// a redis shared by the processes
+type Cache interface {
+	Get(key string) ([]byte, bool)
+	// ttl <= 0 means the value never expires
+	Set(key string, value []byte, ttl time.Duration)
+	Delete(key string)
+	// add delta to the counter of key and return the new value,Incr(key, 0) reads it.
+	// the counters start from 0,they must not expire or be evicted,such as INCRBY of redis
+	Incr(key string, delta int64) int64
+}
+
+// #region lru
+
+// in memory cache evicting the least recently used entries,the expired entries are removed
+// when they are read or evicted
+type LRUCache struct {
+	mu        sync.Mutex
+	capacity  int
+	entries   map[string]*list.Element
+	evictList *list.List
+	//not counted in the capacity and never evicted
+	counters map[string]int64
+}
+
+var _ Cache = (*LRUCache)(nil)
+
+type lruEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// capacity is the max number of the entries,default is DefaultLRUCacheCapacity
+func NewLRUCache(capacity int) *LRUCache {
+	if capacity <= 0 {
+		capacity = DefaultLRUCacheCapacity
+	}
+	return &LRUCache{
+		capacity:  capacity,
+		entries:   make(map[string]*list.Element),
+		evictList: list.New(),
+		counters:  make(map[string]int64),
+	}
+}
+
+func (c *LRUCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*lruEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		c.removeElement(element)
+		return nil, false
+	}
+	c.evictList.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *LRUCache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expiresAt := time.Time{}
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*lruEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.evictList.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.evictList.PushFront(&lruEntry{key: key, value: value, expiresAt: expiresAt})
+	for c.evictList.Len() > c.capacity {
+		c.removeElement(c.evictList.Back())
+	}
+}
+
+func (c *LRUCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *LRUCache) Incr(key string, delta int64) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[key] += delta
+	return c.counters[key]
+}
+
+// number of the entries including the expired ones not removed yet
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.evictList.Len()
+}
+
+func (c *LRUCache) removeElement(element *list.Element) {
+	c.evictList.Remove(element)
+	delete(c.entries, element.Value.(*lruEntry).key)
+}
+
+// #endregion
+
+// #region cached repository
+
+type cacheOptions struct {
+	ttl     time.Duration
+	metrics *Metrics
+}
+
+type CacheOption func(*cacheOptions)
+
+// default is DefaultCacheTTL
+func CacheWithTTL(ttl time.Duration) CacheOption {
+	return func(o *cacheOptions) {
+		o.ttl = ttl
+	}
+}
+
+// record the cache hits and misses into metrics,default is the metrics of the repository
+func CacheWithMetrics(metrics *Metrics) CacheOption {
+	return func(o *cacheOptions) {
+		if metrics != nil {
+			o.metrics = metrics
+		}
+	}
+}
+
+// CachedRepository serves FindByObjectId and FindOne from the cache and reads through the
+// repository on a miss,the other methods go to the repository.
+// the writes through CachedRepository invalidate the cached documents,the writes of other
+// repositories or processes are seen after the ttl unless InvalidateOnChanges is running or they
+// share the cache.the documents not found are not cached
+type CachedRepository struct {
+	IRepository
+
+	cache         Cache
+	ttl           time.Duration
+	metrics       *Metrics
+	configuration *Configuration
+	database      string
+	collection    string
+
+	mu sync.Mutex
+	// changed by every write,the documents read before a write are not cached
+	writeVersion uint64
+}
+
+var _ IRepository = (*CachedRepository)(nil)
+
+// cache the documents of repository in cache,such as
+//
+//	cached := mongodbr.NewCachedRepository(repository, mongodbr.NewLRUCache(1000), mongodbr.CacheWithTTL(5*time.Minute))
+func NewCachedRepository(repository IRepository, cache Cache, opts ...CacheOption) *CachedRepository {
+	configuration := NewConfiguration()
+	if r, ok := repository.(*RepositoryBase); ok {
+		configuration = r.configuration
+	}
+	o := &cacheOptions{
+		ttl:     DefaultCacheTTL,
+		metrics: configuration.getMetrics(),
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	collection := repository.GetCollection()
+	return &CachedRepository{
+		IRepository:   repository,
+		cache:         cache,
+		ttl:           o.ttl,
+		metrics:       o.metrics,
+		configuration: configuration,
+		database:      collection.Database().Name(),
+		collection:    collection.Name(),
+	}
+}
+
+// #region find members
+
+func (c *CachedRepository) FindByObjectId(id primitive.ObjectID) IFindResult {
+	return c.findOne(cacheKindId, idCacheKey(id), func() IFindResult {
+		return c.IRepository.FindByObjectId(id)
+	})
+}
+
+// the filter is normalized,such as the order of the fields of bson.M does not matter
+func (c *CachedRepository) FindOne(filter interface{}, opts ...FindOneOption) IFindResult {
+	findOne := func() IFindResult {
+		return c.IRepository.FindOne(filter, opts...)
+	}
+	if id, ok := idOfFilter(filter); ok && len(opts) <= 0 {
+		return c.findOne(cacheKindId, idCacheKey(id), findOne)
+	}
+	key, ok := filterCacheKey(filter, opts)
+	if !ok {
+		return findOne()
+	}
+	return c.findOne(cacheKindFilter, key, findOne)
+}
+
+// key is the id or filter part of the cache key,empty if it cannot be built
+func (c *CachedRepository) findOne(kind string, key string, find func() IFindResult) IFindResult {
+	if len(key) <= 0 {
+		return find()
+	}
+	c.mu.Lock()
+	writeVersion := c.writeVersion
+	c.mu.Unlock()
+	key = c.cacheKey(kind, key)
+
+	if value, ok := c.cache.Get(key); ok {
+		c.metrics.observeCache(c.database, c.collection, kind, true)
+		return &findResult{
+			configuration: c.configuration,
+			collection:    c.GetCollection(),
+			res:           mongo.NewSingleResultFromDocument(bson.Raw(value), nil, nil),
+		}
+	}
+	c.metrics.observeCache(c.database, c.collection, kind, false)
+
+	result := find()
+	if result.GetError() != nil || result.GetSingleResult() == nil {
+		return result
+	}
+	raw, err := result.GetSingleResult().DecodeBytes()
+	if err != nil {
+		return result
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.writeVersion == writeVersion {
+		c.cache.Set(key, raw, c.ttl)
+	}
+	return result
+}
+
+// namespace,generation and key
+func (c *CachedRepository) cacheKey(kind string, key string) string {
+	generation := c.cache.Incr(c.generationKey(kind), 0)
+	return strings.Join([]string{c.database, c.collection, kind, strconv.FormatInt(generation, 10), key}, "|")
+}
+
+// key of the generation counter of kind,it is a part of the keys of the cached documents and
+// increased to drop the documents which cannot be deleted one by one.
+// it is kept in the cache so that the repositories sharing the cache see the same generation
+func (c *CachedRepository) generationKey(kind string) string {
+	return strings.Join([]string{c.database, c.collection, kind, "generation"}, "|")
+}
+
+// #endregion
+
+// #region invalidation
+
+// drop every cached document of the repository
+func (c *CachedRepository) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeVersion++
+	c.cache.Incr(c.generationKey(cacheKindId), 1)
+	c.cache.Incr(c.generationKey(cacheKindFilter), 1)
+}
+
+// drop the cached document of id and the cached filters
+func (c *CachedRepository) InvalidateId(id interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeVersion++
+	c.cache.Incr(c.generationKey(cacheKindFilter), 1)
+	if key := idCacheKey(id); len(key) > 0 {
+		c.cache.Delete(c.cacheKey(cacheKindId, key))
+	} else {
+		c.cache.Incr(c.generationKey(cacheKindId), 1)
+	}
+}
+
+// drop the cached documents which may match filter,all of them unless filter is by _id only
+func (c *CachedRepository) invalidateFilter(filter interface{}) {
+	if id, ok := idOfFilter(filter); ok {
+		c.InvalidateId(id)
+		return
+	}
+	c.Invalidate()
+}
+
+// drop the cached filters,the inserted documents may match them
+func (c *CachedRepository) invalidateFilters() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeVersion++
+	c.cache.Incr(c.generationKey(cacheKindFilter), 1)
+}
+
+// invalidate the cache by the change events of the collection until ctx is done,so that the
+// writes of other repositories and processes are seen before the ttl. it blocks,run it in a
+// goroutine:
+//
+//	go cached.InvalidateOnChanges(ctx)
+func (c *CachedRepository) InvalidateOnChanges(ctx context.Context, opts ...WatchOption) error {
+	changeStream, err := c.Watch(ctx, nil, opts...)
+	if err != nil {
+		return err
+	}
+	return changeStream.Run(ctx, func(_ context.Context, event *ChangeEvent) error {
+		switch event.OperationType {
+		case ChangeOperationInsert:
+			c.invalidateFilters()
+		case ChangeOperationUpdate, ChangeOperationReplace, ChangeOperationDelete:
+			if id := event.DocumentID(); id != nil {
+				c.InvalidateId(id)
+			} else {
+				c.Invalidate()
+			}
+		default:
+			c.Invalidate()
+		}
+		return nil
+	})
+}
+
+// #endregion
+
+// #region write members
+
+func (c *CachedRepository) Create(data interface{}, opts ...*options.InsertOneOptions) (primitive.ObjectID, error) {
+	defer c.invalidateFilters()
+	return c.IRepository.Create(data, opts...)
+}
+
+func (c *CachedRepository) CreateMany(itemList []interface{}, opts ...*options.InsertManyOptions) ([]primitive.ObjectID, error) {
+	defer c.invalidateFilters()
+	return c.IRepository.CreateMany(itemList, opts...)
+}
+
+func (c *CachedRepository) FindOneAndUpdate(entity IEntity, opts ...*options.FindOneAndUpdateOptions) error {
+	defer c.InvalidateId(entity.GetObjectId())
+	return c.IRepository.FindOneAndUpdate(entity, opts...)
+}
+
+func (c *CachedRepository) FindOneAndUpdateWithId(objectId primitive.ObjectID, update interface{}, opts ...*options.FindOneAndUpdateOptions) error {
+	defer c.InvalidateId(objectId)
+	return c.IRepository.FindOneAndUpdateWithId(objectId, update, opts...)
+}
+
+func (c *CachedRepository) UpdateOne(filter interface{}, update interface{}, opts ...*options.UpdateOptions) error {
+	defer c.invalidateFilter(filter)
+	return c.IRepository.UpdateOne(filter, update, opts...)
+}
+
+func (c *CachedRepository) UpdateMany(filter interface{}, update interface{}, opts ...*options.UpdateOptions) (interface{}, error) {
+	defer c.invalidateFilter(filter)
+	return c.IRepository.UpdateMany(filter, update, opts...)
+}
+
+func (c *CachedRepository) DeleteOne(id primitive.ObjectID, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	defer c.InvalidateId(id)
+	return c.IRepository.DeleteOne(id, opts...)
+}
+
+func (c *CachedRepository) DeleteOneByFilter(filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	defer c.invalidateFilter(filter)
+	return c.IRepository.DeleteOneByFilter(filter, opts...)
+}
+
+func (c *CachedRepository) DeleteMany(filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	defer c.invalidateFilter(filter)
+	return c.IRepository.DeleteMany(filter, opts...)
+}
+
+func (c *CachedRepository) ReplaceById(id primitive.ObjectID, doc interface{}, opts ...*options.ReplaceOptions) error {
+	defer c.InvalidateId(id)
+	return c.IRepository.ReplaceById(id, doc, opts...)
+}
+
+func (c *CachedRepository) Replace(filter interface{}, doc interface{}, opts ...*options.ReplaceOptions) error {
+	defer c.invalidateFilter(filter)
+	return c.IRepository.Replace(filter, doc, opts...)
+}
+
+func (c *CachedRepository) BulkWrite(models []mongo.WriteModel, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	defer c.Invalidate()
+	return c.IRepository.BulkWrite(models, opts...)
+}
+
+func (c *CachedRepository) BulkWriteEntityList(entityList []IEntity, opts ...*options.BulkWriteOptions) (*mongo.BulkWriteResult, error) {
+	defer c.Invalidate()
+	return c.IRepository.BulkWriteEntityList(entityList, opts...)
+}
+
+// the pipelines with $out or $merge invalidate the cache
+func (c *CachedRepository) Aggregate(pipeline interface{}, dataList interface{}, opts ...AggregateOption) error {
+	if isWritePipeline(pipeline) {
+		defer c.Invalidate()
+	}
+	return c.IRepository.Aggregate(pipeline, dataList, opts...)
+}
+
+// #endregion
+
+// #endregion
+
+// #region keys
+
+// _id of a filter matching only by _id,such as bson.M{"_id": id}
+func idOfFilter(filter interface{}) (interface{}, bool) {
+	document, ok := toBsonD(filter)
+	if !ok || len(document) != 1 || document[0].Key != "_id" {
+		return nil, false
+	}
+	if subDocument, ok := document[0].Value.(bson.D); ok && isOperatorDocument(subDocument) {
+		return nil, false
+	}
+	return document[0].Value, true
+}
+
+// canonical extended json of the _id,empty if it cannot be marshaled
+func idCacheKey(id interface{}) string {
+	data, err := bson.MarshalExtJSON(bson.D{{Key: "_id", Value: id}}, true, false)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// the normalized filter and the options changing the result of FindOne
+func filterCacheKey(filter interface{}, opts []FindOneOption) (string, bool) {
+	document := bson.D{}
+	if filter != nil {
+		var ok bool
+		document, ok = toBsonD(filter)
+		if !ok {
+			return "", false
+		}
+	}
+	findOneOptions := options.FindOne()
+	for _, eachOpt := range opts {
+		eachOpt(findOneOptions)
+	}
+	key := bson.D{{Key: "filter", Value: normalizeQuery(document)}}
+	for _, eachOption := range []bson.E{
+		{Key: "sort", Value: findOneOptions.Sort},
+		{Key: "projection", Value: findOneOptions.Projection},
+		{Key: "skip", Value: findOneOptions.Skip},
+		{Key: "hint", Value: findOneOptions.Hint},
+		{Key: "collation", Value: findOneOptions.Collation},
+		{Key: "min", Value: findOneOptions.Min},
+		{Key: "max", Value: findOneOptions.Max},
+		{Key: "showRecordId", Value: findOneOptions.ShowRecordID},
+		{Key: "returnKey", Value: findOneOptions.ReturnKey},
+	} {
+		if !isNilValue(eachOption.Value) {
+			key = append(key, eachOption)
+		}
+	}
+	data, err := bson.MarshalExtJSON(key, true, false)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// nil or a nil pointer of the unset options
+func isNilValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return value.IsNil()
+	}
+	return false
+}
+
+// sort the fields of the query levels and the operator documents,the embedded documents
+// compared as a whole keep the order of their fields
+func normalizeQuery(document bson.D) bson.D {
+	result := make(bson.D, 0, len(document))
+	for _, eachElement := range document {
+		result = append(result, bson.E{Key: eachElement.Key, Value: normalizeQueryValue(eachElement.Key, eachElement.Value)})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+func normalizeQueryValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.A:
+		if key != "$and" && key != "$or" && key != "$nor" {
+			return v
+		}
+		list := make(bson.A, 0, len(v))
+		for _, eachItem := range v {
+			if subDocument, ok := eachItem.(bson.D); ok {
+				list = append(list, normalizeQuery(subDocument))
+			} else {
+				list = append(list, eachItem)
+			}
+		}
+		return list
+	case bson.D:
+		if key == "$elemMatch" || key == "$not" || isOperatorDocument(v) {
+			return normalizeQuery(v)
+		}
+	}
+	return value
+}
+
+// every field is an operator,such as {$gte: 1, $lt: 10}
+func isOperatorDocument(document bson.D) bool {
+	if len(document) <= 0 {
+		return false
+	}
+	for _, eachElement := range document {
+		if !strings.HasPrefix(eachElement.Key, "$") {
+			return false
+		}
+	}
+	return true
+}
+
+// #endregion
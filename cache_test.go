@@ -0,0 +1,151 @@
+package mongodbr
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestLRUCache(t *testing.T) {
+	cache := NewLRUCache(2)
+	cache.Set("a", []byte("a"), 0)
+	cache.Set("b", []byte("b"), 0)
+	//a becomes the most recently used,b is evicted by c
+	cache.Get("a")
+	cache.Set("c", []byte("c"), 0)
+	cache.Set("expired", []byte("expired"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	testCases := []struct {
+		key    string
+		exists bool
+	}{
+		{"a", false},
+		{"b", false},
+		{"c", true},
+		{"expired", false},
+	}
+	for _, eachCase := range testCases {
+		if _, ok := cache.Get(eachCase.key); ok != eachCase.exists {
+			t.Errorf("%s: expect exists %v,got %v", eachCase.key, eachCase.exists, ok)
+		}
+	}
+	if cache.Len() != 1 {
+		t.Errorf("expect 1 entry,got %d", cache.Len())
+	}
+
+	cache.Incr("generation", 1)
+	for i := 0; i < 10; i++ {
+		cache.Set(string(rune('d'+i)), []byte{}, 0)
+	}
+	if generation := cache.Incr("generation", 0); generation != 1 {
+		t.Errorf("the counters must not be evicted,got %d", generation)
+	}
+}
+
+func TestFilterCacheKey(t *testing.T) {
+	sort := func(fo *options.FindOneOptions) { fo.SetSort(bson.D{{Key: "name", Value: 1}}) }
+	testCases := []struct {
+		name  string
+		a     interface{}
+		aOpts []FindOneOption
+		b     interface{}
+		bOpts []FindOneOption
+		same  bool
+	}{
+		{"field order", bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 2}}, nil, bson.D{{Key: "b", Value: 2}, {Key: "a", Value: 1}}, nil, true},
+		{"map", bson.M{"a": 1, "b": bson.M{"$gte": 1, "$lt": 5}}, nil, bson.D{{Key: "b", Value: bson.D{{Key: "$lt", Value: 5}, {Key: "$gte", Value: 1}}}, {Key: "a", Value: 1}}, nil, true},
+		{"$or", bson.M{"$or": bson.A{bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 1}}}}, nil, bson.M{"$or": bson.A{bson.D{{Key: "b", Value: 1}, {Key: "a", Value: 1}}}}, nil, true},
+		{"embedded document", bson.D{{Key: "a", Value: bson.D{{Key: "x", Value: 1}, {Key: "y", Value: 1}}}}, nil, bson.D{{Key: "a", Value: bson.D{{Key: "y", Value: 1}, {Key: "x", Value: 1}}}}, nil, false},
+		{"nil filter", nil, nil, bson.M{}, nil, true},
+		{"sort", bson.M{"a": 1}, []FindOneOption{sort}, bson.M{"a": 1}, nil, false},
+		{"value", bson.M{"a": 1}, nil, bson.M{"a": 2}, nil, false},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			a, ok := filterCacheKey(eachCase.a, eachCase.aOpts)
+			if !ok {
+				t.Fatal("no key of a")
+			}
+			b, ok := filterCacheKey(eachCase.b, eachCase.bOpts)
+			if !ok {
+				t.Fatal("no key of b")
+			}
+			if (a == b) != eachCase.same {
+				t.Errorf("expect same %v,got %s and %s", eachCase.same, a, b)
+			}
+		})
+	}
+}
+
+// documents in memory,only the members used by CachedRepository
+type cacheTestRepository struct {
+	IRepository
+	collection *mongo.Collection
+	documents  map[primitive.ObjectID]bson.D
+	finds      int
+}
+
+func (r *cacheTestRepository) GetCollection() *mongo.Collection {
+	return r.collection
+}
+
+func (r *cacheTestRepository) FindByObjectId(id primitive.ObjectID) IFindResult {
+	r.finds++
+	document, ok := r.documents[id]
+	if !ok {
+		return &findResult{configuration: NewConfiguration(), err: mongo.ErrNoDocuments}
+	}
+	return &findResult{configuration: NewConfiguration(), res: mongo.NewSingleResultFromDocument(document, nil, nil)}
+}
+
+func (r *cacheTestRepository) UpdateOne(filter interface{}, update interface{}, opts ...*options.UpdateOptions) error {
+	id, _ := idOfFilter(filter)
+	r.documents[id.(primitive.ObjectID)] = update.(bson.D)
+	return nil
+}
+
+func TestCachedRepositorySharedCache(t *testing.T) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := primitive.NewObjectID()
+	repository := &cacheTestRepository{
+		collection: client.Database("test").Collection("products"),
+		documents:  map[primitive.ObjectID]bson.D{id: {{Key: "_id", Value: id}, {Key: "name", Value: "v1"}}},
+	}
+	cache := NewLRUCache(10)
+	a := NewCachedRepository(repository, cache, CacheWithMetrics(nil))
+	b := NewCachedRepository(repository, cache)
+
+	nameOf := func(c *CachedRepository) string {
+		document := bson.M{}
+		if err := c.FindByObjectId(id).One(&document); err != nil {
+			t.Fatal(err)
+		}
+		return document["name"].(string)
+	}
+	if name := nameOf(a); name != "v1" {
+		t.Fatalf("expect v1,got %s", name)
+	}
+	if name := nameOf(b); name != "v1" || repository.finds != 1 {
+		t.Fatalf("expect v1 from the shared cache,got %s after %d finds", name, repository.finds)
+	}
+
+	if err := b.UpdateOne(bson.M{"_id": id}, bson.D{{Key: "_id", Value: id}, {Key: "name", Value: "v2"}}); err != nil {
+		t.Fatal(err)
+	}
+	if name := nameOf(a); name != "v2" {
+		t.Fatalf("expect v2 after the write of the other repository,got %s", name)
+	}
+
+	b.Invalidate()
+	if name := nameOf(a); name != "v2" || repository.finds != 3 {
+		t.Fatalf("expect v2 read again after Invalidate,got %s after %d finds", name, repository.finds)
+	}
+}
@@ -0,0 +1,99 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CheckpointStore keeps the resume tokens of the change streams by name
+type CheckpointStore interface {
+	// the saved token,nil if not saved yet
+	Load(ctx context.Context, name string) (bson.Raw, error)
+	Save(ctx context.Context, name string, token bson.Raw) error
+}
+
+// #region memory
+
+// keep the tokens in memory,used by tests and the consumers which do not need to resume after
+// restarts
+type MemoryCheckpointStore struct {
+	mu     sync.RWMutex
+	tokens map[string]bson.Raw
+}
+
+var _ CheckpointStore = (*MemoryCheckpointStore)(nil)
+
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{
+		tokens: make(map[string]bson.Raw),
+	}
+}
+
+func (s *MemoryCheckpointStore) Load(_ context.Context, name string) (bson.Raw, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	token, ok := s.tokens[name]
+	if !ok {
+		return nil, nil
+	}
+	//the caller may change the token
+	return append(bson.Raw(nil), token...), nil
+}
+
+func (s *MemoryCheckpointStore) Save(_ context.Context, name string, token bson.Raw) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[name] = append(bson.Raw(nil), token...)
+	return nil
+}
+
+// #endregion
+
+// #region collection
+
+// keep the tokens in a collection as {_id: name, token: ..., updatedAt: ...}
+type CollectionCheckpointStore struct {
+	collection *mongo.Collection
+}
+
+var _ CheckpointStore = (*CollectionCheckpointStore)(nil)
+
+func NewCollectionCheckpointStore(collection *mongo.Collection) *CollectionCheckpointStore {
+	return &CollectionCheckpointStore{
+		collection: collection,
+	}
+}
+
+type checkpointRecord struct {
+	Name      string    `bson:"_id"`
+	Token     bson.Raw  `bson:"token"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+}
+
+func (s *CollectionCheckpointStore) Load(ctx context.Context, name string) (bson.Raw, error) {
+	record := checkpointRecord{}
+	err := s.collection.FindOne(ctx, bson.M{"_id": name}).Decode(&record)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record.Token, nil
+}
+
+func (s *CollectionCheckpointStore) Save(ctx context.Context, name string, token bson.Raw) error {
+	_, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": name},
+		bson.M{"$set": bson.M{"token": token, "updatedAt": time.Now()}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+// #endregion
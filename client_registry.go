@@ -0,0 +1,253 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrClientNotRegistered     = errors.New("client is not registered")
+	ErrClientAlreadyRegistered = errors.New("client is already registered")
+
+	// registry used by SetupDefaultClient,RegistClient and GetClient
+	DefaultClientRegistry = NewClientRegistry()
+)
+
+type ClientConnectHook func(key string, client *mongo.Client)
+type ClientDisconnectHook func(key string, client *mongo.Client, err error)
+
+// a registered client,connected on first use
+type clientEntry struct {
+	uri  string
+	opts []func(*options.ClientOptions)
+
+	mu     sync.Mutex
+	client *mongo.Client
+	//removed from the registry,it cannot be connected again
+	closed bool
+}
+
+type clientRegistryHooks struct {
+	connect    []ClientConnectHook
+	disconnect []ClientDisconnectHook
+}
+
+// ClientRegistry keeps the clients by key,it is safe for concurrent use
+type ClientRegistry struct {
+	mu      sync.RWMutex
+	entries map[string]*clientEntry
+	//replaced by Swap,still used by the handles taken before,disconnected by CloseAll
+	retired []retiredClientEntry
+	hooks   clientRegistryHooks
+}
+
+type retiredClientEntry struct {
+	key   string
+	entry *clientEntry
+}
+
+func NewClientRegistry() *ClientRegistry {
+	return &ClientRegistry{
+		entries: make(map[string]*clientEntry),
+	}
+}
+
+// call fn after a client is connected
+func (r *ClientRegistry) OnConnect(fn ClientConnectHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks.connect = append(r.hooks.connect, fn)
+}
+
+// call fn after a client is disconnected,err is the error of the disconnection
+func (r *ClientRegistry) OnDisconnect(fn ClientDisconnectHook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks.disconnect = append(r.hooks.disconnect, fn)
+}
+
+// register the client of uri as key,the client is connected on the first Get
+func (r *ClientRegistry) Register(key string, uri string, opts ...func(*options.ClientOptions)) error {
+	if len(key) <= 0 {
+		return errors.New("client key cannot be empty")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.entries[key]; ok {
+		return fmt.Errorf("%w: %s", ErrClientAlreadyRegistered, key)
+	}
+	r.entries[key] = &clientEntry{uri: uri, opts: opts}
+	return nil
+}
+
+// register the client of uri as key,the client previously registered as key is disconnected
+func (r *ClientRegistry) Replace(ctx context.Context, key string, uri string, opts ...func(*options.ClientOptions)) error {
+	if len(key) <= 0 {
+		return errors.New("client key cannot be empty")
+	}
+	r.mu.Lock()
+	old := r.entries[key]
+	r.entries[key] = &clientEntry{uri: uri, opts: opts}
+	r.mu.Unlock()
+
+	if old == nil {
+		return nil
+	}
+	return r.disconnect(ctx, key, old)
+}
+
+// connect the client of uri and register it as key,return the connected client.
+// nothing is changed if it cannot be connected.the client previously registered as key is kept
+// connected,because the repositories created before still use its collections,it is
+// disconnected by CloseAll
+func (r *ClientRegistry) Swap(key string, uri string, opts ...func(*options.ClientOptions)) (*mongo.Client, error) {
+	if len(key) <= 0 {
+		return nil, errors.New("client key cannot be empty")
+	}
+	client, err := createClient(key, uri, opts...)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	if old, ok := r.entries[key]; ok {
+		r.retired = append(r.retired, retiredClientEntry{key: key, entry: old})
+	}
+	r.entries[key] = &clientEntry{uri: uri, opts: opts, client: client}
+	r.mu.Unlock()
+
+	for _, eachHook := range r.copyHooks().connect {
+		eachHook(key, client)
+	}
+	return client, nil
+}
+
+// disconnect the client of key and remove it from the registry
+func (r *ClientRegistry) Remove(ctx context.Context, key string) error {
+	r.mu.Lock()
+	entry, ok := r.entries[key]
+	delete(r.entries, key)
+	r.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrClientNotRegistered, key)
+	}
+	return r.disconnect(ctx, key, entry)
+}
+
+// the client of key,it is connected if not yet
+func (r *ClientRegistry) Get(key string) (*mongo.Client, error) {
+	r.mu.RLock()
+	entry, ok := r.entries[key]
+	r.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrClientNotRegistered, key)
+	}
+
+	entry.mu.Lock()
+	if entry.closed {
+		entry.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrClientNotRegistered, key)
+	}
+	if entry.client != nil {
+		entry.mu.Unlock()
+		return entry.client, nil
+	}
+	client, err := createClient(key, entry.uri, entry.opts...)
+	if err != nil {
+		entry.mu.Unlock()
+		return nil, err
+	}
+	entry.client = client
+	entry.mu.Unlock()
+
+	for _, eachHook := range r.copyHooks().connect {
+		eachHook(key, client)
+	}
+	return client, nil
+}
+
+// keys of the registered clients,sorted
+func (r *ClientRegistry) Keys() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	keyList := make([]string, 0, len(r.entries))
+	for eachKey := range r.entries {
+		keyList = append(keyList, eachKey)
+	}
+	sort.Strings(keyList)
+	return keyList
+}
+
+// the connected clients by key,the clients not used yet are not included
+func (r *ClientRegistry) Clients() map[string]*mongo.Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	clients := make(map[string]*mongo.Client, len(r.entries))
+	for eachKey, eachEntry := range r.entries {
+		eachEntry.mu.Lock()
+		if eachEntry.client != nil {
+			clients[eachKey] = eachEntry.client
+		}
+		eachEntry.mu.Unlock()
+	}
+	return clients
+}
+
+// disconnect and remove every client including the ones replaced by Swap,used for graceful shutdown
+func (r *ClientRegistry) CloseAll(ctx context.Context) error {
+	r.mu.Lock()
+	entries := r.entries
+	retired := r.retired
+	r.entries = make(map[string]*clientEntry)
+	r.retired = nil
+	r.mu.Unlock()
+
+	errList := make([]error, 0)
+	for eachKey, eachEntry := range entries {
+		if err := r.disconnect(ctx, eachKey, eachEntry); err != nil {
+			errList = append(errList, err)
+		}
+	}
+	for _, eachRetired := range retired {
+		if err := r.disconnect(ctx, eachRetired.key, eachRetired.entry); err != nil {
+			errList = append(errList, err)
+		}
+	}
+	return errors.Join(errList...)
+}
+
+func (r *ClientRegistry) disconnect(ctx context.Context, key string, entry *clientEntry) error {
+	entry.mu.Lock()
+	client := entry.client
+	entry.client = nil
+	entry.closed = true
+	entry.mu.Unlock()
+	if client == nil {
+		return nil
+	}
+
+	err := client.Disconnect(ctx)
+	for _, eachHook := range r.copyHooks().disconnect {
+		eachHook(key, client, err)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to disconnect client %s: %w", key, err)
+	}
+	return nil
+}
+
+// copy of the hooks,they are called without holding the lock
+func (r *ClientRegistry) copyHooks() clientRegistryHooks {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return clientRegistryHooks{
+		connect:    append([]ClientConnectHook(nil), r.hooks.connect...),
+		disconnect: append([]ClientDisconnectHook(nil), r.hooks.disconnect...),
+	}
+}
@@ -0,0 +1,47 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestClientRegistrySwap(t *testing.T) {
+	ctx := context.Background()
+	registry := NewClientRegistry()
+	old, err := registry.Swap("main", "mongodb://localhost:27017")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := registry.Swap("main", "invalid://uri"); err == nil {
+		t.Fatal("expect the connect error")
+	}
+	if client, err := registry.Get("main"); err != nil || client != old {
+		t.Fatalf("a failed swap must keep the registered client,got %v %v", client, err)
+	}
+
+	client, err := registry.Swap("main", "mongodb://localhost:27018")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := registry.Get("main"); current != client {
+		t.Fatal("expect the swapped client")
+	}
+	session, err := old.StartSession()
+	if err != nil {
+		t.Fatalf("the replaced client must stay connected,got %v", err)
+	}
+	session.EndSession(ctx)
+
+	if err := registry.CloseAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, eachClient := range []*mongo.Client{old, client} {
+		if err := eachClient.Disconnect(ctx); !errors.Is(err, mongo.ErrClientDisconnected) {
+			t.Errorf("expect the client disconnected by CloseAll,got %v", err)
+		}
+	}
+}
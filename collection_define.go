@@ -0,0 +1,437 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	TimeSeriesGranularitySeconds = "seconds"
+	TimeSeriesGranularityMinutes = "minutes"
+	TimeSeriesGranularityHours   = "hours"
+)
+
+var (
+	ErrInvalidCollectionDefine = errors.New("invalid collection define")
+)
+
+type TimeSeriesDefine struct {
+	TimeField   string
+	MetaField   string
+	Granularity string
+}
+
+// declarative definition of a collection or a read-only view
+type CollectionDefine struct {
+	Name string
+
+	//capped collection
+	Capped       bool
+	SizeInBytes  int64
+	MaxDocuments int64
+
+	TimeSeries *TimeSeriesDefine
+	//cluster the collection by _id
+	ClusteredIndex bool
+	Collation      *options.Collation
+
+	Validator        interface{}
+	ValidationLevel  string
+	ValidationAction string
+
+	//remove documents of a time-series or clustered collection after the specified number of seconds
+	ExpireAfterSeconds *int64
+
+	//source collection of a view
+	ViewOn string
+	//pipeline of a view,such as builder.NewAggregatePipelineBuilder().BuildAggregatePipeline()
+	Pipeline interface{}
+}
+
+func NewCollectionDefine(name string) *CollectionDefine {
+	return &CollectionDefine{
+		Name: name,
+	}
+}
+
+// define a read-only view on viewOn
+func NewViewDefine(name string, viewOn string, pipeline interface{}) *CollectionDefine {
+	return &CollectionDefine{
+		Name:     name,
+		ViewOn:   viewOn,
+		Pipeline: pipeline,
+	}
+}
+
+func (d *CollectionDefine) WithCapped(sizeInBytes int64, maxDocuments int64) *CollectionDefine {
+	d.Capped = true
+	d.SizeInBytes = sizeInBytes
+	d.MaxDocuments = maxDocuments
+	return d
+}
+
+func (d *CollectionDefine) WithTimeSeries(timeField string, metaField string, granularity string) *CollectionDefine {
+	d.TimeSeries = &TimeSeriesDefine{
+		TimeField:   timeField,
+		MetaField:   metaField,
+		Granularity: granularity,
+	}
+	return d
+}
+
+func (d *CollectionDefine) WithClusteredIndex() *CollectionDefine {
+	d.ClusteredIndex = true
+	return d
+}
+
+func (d *CollectionDefine) WithCollation(collation *options.Collation) *CollectionDefine {
+	d.Collation = collation
+	return d
+}
+
+func (d *CollectionDefine) WithValidator(validator interface{}, level string, action string) *CollectionDefine {
+	d.Validator = validator
+	d.ValidationLevel = level
+	d.ValidationAction = action
+	return d
+}
+
+func (d *CollectionDefine) WithExpireAfterSeconds(seconds int64) *CollectionDefine {
+	d.ExpireAfterSeconds = &seconds
+	return d
+}
+
+func (d *CollectionDefine) IsView() bool {
+	return len(d.ViewOn) > 0
+}
+
+func (d *CollectionDefine) Validate() error {
+	if len(d.Name) <= 0 {
+		return d.invalid("name is required")
+	}
+	if d.IsView() {
+		if d.Capped || d.TimeSeries != nil || d.ClusteredIndex || d.Validator != nil || d.ExpireAfterSeconds != nil {
+			return d.invalid("a view only supports pipeline and collation")
+		}
+		return nil
+	}
+	if d.Capped {
+		if d.SizeInBytes <= 0 {
+			return d.invalid("capped collection requires size")
+		}
+		if d.TimeSeries != nil || d.ClusteredIndex {
+			return d.invalid("capped collection cannot be time-series or clustered")
+		}
+	}
+	if d.TimeSeries != nil {
+		if len(d.TimeSeries.TimeField) <= 0 {
+			return d.invalid("time-series collection requires timeField")
+		}
+		switch d.TimeSeries.Granularity {
+		case "", TimeSeriesGranularitySeconds, TimeSeriesGranularityMinutes, TimeSeriesGranularityHours:
+		default:
+			return d.invalid(fmt.Sprintf("unknown granularity %s", d.TimeSeries.Granularity))
+		}
+		if d.ClusteredIndex {
+			return d.invalid("time-series collection cannot be clustered")
+		}
+	}
+	if d.ExpireAfterSeconds != nil && d.TimeSeries == nil && !d.ClusteredIndex {
+		return d.invalid("expireAfterSeconds requires a time-series or clustered collection")
+	}
+	switch d.ValidationLevel {
+	case "", ValidationLevelOff, ValidationLevelStrict, ValidationLevelModerate:
+	default:
+		return d.invalid(fmt.Sprintf("unknown validationLevel %s", d.ValidationLevel))
+	}
+	switch d.ValidationAction {
+	case "", ValidationActionError, ValidationActionWarn:
+	default:
+		return d.invalid(fmt.Sprintf("unknown validationAction %s", d.ValidationAction))
+	}
+	return nil
+}
+
+func (d *CollectionDefine) invalid(message string) error {
+	return fmt.Errorf("%w %s: %s", ErrInvalidCollectionDefine, d.Name, message)
+}
+
+// an option of the existing collection that differs from the define
+type CollectionDrift struct {
+	Option   string
+	Desired  interface{}
+	Existing interface{}
+}
+
+func (d CollectionDrift) String() string {
+	return fmt.Sprintf("%s desired:%v existing:%v", d.Option, d.Desired, d.Existing)
+}
+
+type CollectionEnsureResult struct {
+	Name    string
+	Created bool
+	Drifts  []CollectionDrift
+}
+
+// create the collections and views that do not exist,report the drifts of the existing ones.
+// existing collections are never modified
+func EnsureCollections(ctx context.Context, db *mongo.Database, defineList ...*CollectionDefine) ([]CollectionEnsureResult, error) {
+	resultList := make([]CollectionEnsureResult, 0, len(defineList))
+	for _, eachDefine := range defineList {
+		result, err := EnsureCollection(ctx, db, eachDefine)
+		if err != nil {
+			return resultList, err
+		}
+		resultList = append(resultList, *result)
+	}
+	return resultList, nil
+}
+
+// create the collection or view if not exists,otherwise compare it with the define
+func EnsureCollection(ctx context.Context, db *mongo.Database, define *CollectionDefine) (*CollectionEnsureResult, error) {
+	if define == nil {
+		return nil, errors.New("define cannot be nil")
+	}
+	if err := define.Validate(); err != nil {
+		return nil, err
+	}
+	cur, err := db.ListCollections(ctx, bson.M{"name": define.Name})
+	if err != nil {
+		return nil, err
+	}
+	specList := make([]bson.D, 0)
+	if err := cur.All(ctx, &specList); err != nil {
+		return nil, err
+	}
+	result := &CollectionEnsureResult{
+		Name: define.Name,
+	}
+	if len(specList) > 0 {
+		result.Drifts = define.diff(specList[0])
+		return result, nil
+	}
+
+	if define.IsView() {
+		viewOptions := options.CreateView()
+		if define.Collation != nil {
+			viewOptions.SetCollation(define.Collation)
+		}
+		err = db.CreateView(ctx, define.Name, define.ViewOn, define.pipeline(), viewOptions)
+	} else {
+		err = db.CreateCollection(ctx, define.Name, define.createOptions())
+	}
+	if err != nil {
+		//created by another instance at the same time
+		if isNamespaceExistsError(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+	result.Created = true
+	return result, nil
+}
+
+func (d *CollectionDefine) pipeline() interface{} {
+	if d.Pipeline == nil {
+		return mongo.Pipeline{}
+	}
+	return d.Pipeline
+}
+
+func (d *CollectionDefine) createOptions() *options.CreateCollectionOptions {
+	o := options.CreateCollection()
+	if d.Capped {
+		o.SetCapped(true).SetSizeInBytes(d.SizeInBytes)
+		if d.MaxDocuments > 0 {
+			o.SetMaxDocuments(d.MaxDocuments)
+		}
+	}
+	if d.TimeSeries != nil {
+		timeSeriesOptions := options.TimeSeries().SetTimeField(d.TimeSeries.TimeField)
+		if len(d.TimeSeries.MetaField) > 0 {
+			timeSeriesOptions.SetMetaField(d.TimeSeries.MetaField)
+		}
+		if len(d.TimeSeries.Granularity) > 0 {
+			timeSeriesOptions.SetGranularity(d.TimeSeries.Granularity)
+		}
+		o.SetTimeSeriesOptions(timeSeriesOptions)
+	}
+	if d.ClusteredIndex {
+		o.SetClusteredIndex(bson.D{
+			{Key: "key", Value: bson.D{{Key: "_id", Value: 1}}},
+			{Key: "unique", Value: true},
+		})
+	}
+	if d.Collation != nil {
+		o.SetCollation(d.Collation)
+	}
+	if d.Validator != nil {
+		o.SetValidator(d.Validator)
+	}
+	if len(d.ValidationLevel) > 0 {
+		o.SetValidationLevel(d.ValidationLevel)
+	}
+	if len(d.ValidationAction) > 0 {
+		o.SetValidationAction(d.ValidationAction)
+	}
+	if d.ExpireAfterSeconds != nil {
+		o.SetExpireAfterSeconds(*d.ExpireAfterSeconds)
+	}
+	return o
+}
+
+// compare the define with the listCollections spec of the existing collection
+func (d *CollectionDefine) diff(spec bson.D) []CollectionDrift {
+	var (
+		collectionType string
+		existing       = map[string]interface{}{}
+	)
+	for _, eachElement := range spec {
+		switch eachElement.Key {
+		case "type":
+			collectionType, _ = eachElement.Value.(string)
+		case "options":
+			if optionMap, ok := normalizeBsonValue(eachElement.Value).(map[string]interface{}); ok {
+				existing = optionMap
+			}
+		}
+	}
+
+	driftList := make([]CollectionDrift, 0)
+	addDrift := func(option string, desired interface{}, existingValue interface{}) {
+		driftList = append(driftList, CollectionDrift{
+			Option:   option,
+			Desired:  desired,
+			Existing: existingValue,
+		})
+	}
+	compare := func(option string, desired interface{}, defaultValue interface{}) {
+		desired = normalizeBsonValue(desired)
+		existingValue, ok := existing[option]
+		if !ok {
+			existingValue = normalizeBsonValue(defaultValue)
+		}
+		if !reflect.DeepEqual(desired, existingValue) {
+			addDrift(option, desired, existingValue)
+		}
+	}
+
+	if d.IsView() {
+		if collectionType != "view" {
+			addDrift("type", "view", collectionType)
+			return driftList
+		}
+		compare("viewOn", d.ViewOn, nil)
+		if stageList, ok := toStageList(d.pipeline()); ok {
+			compare("pipeline", toInterfaceList(stageList), []interface{}{})
+		}
+		d.compareCollation(existing, addDrift)
+		return driftList
+	}
+	if collectionType == "view" {
+		addDrift("type", "collection", collectionType)
+		return driftList
+	}
+
+	compare("capped", d.Capped, false)
+	if d.Capped {
+		//the server rounds the size up to a multiple of 256
+		compare("size", (d.SizeInBytes+255)/256*256, nil)
+		if d.MaxDocuments > 0 {
+			compare("max", d.MaxDocuments, nil)
+		}
+	}
+	existingTimeSeries, hasTimeSeries := existing["timeseries"].(map[string]interface{})
+	if d.TimeSeries != nil {
+		timeSeries := bson.M{"timeField": d.TimeSeries.TimeField}
+		if len(d.TimeSeries.MetaField) > 0 {
+			timeSeries["metaField"] = d.TimeSeries.MetaField
+		}
+		if len(d.TimeSeries.Granularity) > 0 {
+			timeSeries["granularity"] = d.TimeSeries.Granularity
+		}
+		//the server fills in granularity and bucketMaxSpanSeconds
+		desired := normalizeBsonValue(timeSeries)
+		_, hasMetaField := existingTimeSeries["metaField"]
+		if !hasTimeSeries || !isBsonValueSubset(desired, existingTimeSeries) || (hasMetaField && len(d.TimeSeries.MetaField) <= 0) {
+			addDrift("timeseries", desired, existing["timeseries"])
+		}
+	} else if hasTimeSeries {
+		addDrift("timeseries", nil, existingTimeSeries)
+	}
+	if _, ok := existing["clusteredIndex"]; ok != d.ClusteredIndex {
+		addDrift("clusteredIndex", d.ClusteredIndex, existing["clusteredIndex"])
+	}
+	if d.Validator != nil {
+		//the server returns the arrays of the validator as []interface{},such as required
+		compare("validator", bsonRoundTrip(d.Validator), nil)
+	} else if existingValidator, ok := existing["validator"]; ok {
+		addDrift("validator", nil, existingValidator)
+	}
+	if len(d.ValidationLevel) > 0 {
+		compare("validationLevel", d.ValidationLevel, ValidationLevelStrict)
+	}
+	if len(d.ValidationAction) > 0 {
+		compare("validationAction", d.ValidationAction, ValidationActionError)
+	}
+	if d.ExpireAfterSeconds != nil {
+		compare("expireAfterSeconds", *d.ExpireAfterSeconds, nil)
+	}
+	d.compareCollation(existing, addDrift)
+	return driftList
+}
+
+// the server expands the collation with defaults,only the fields of the define are compared
+func (d *CollectionDefine) compareCollation(existing map[string]interface{}, addDrift func(string, interface{}, interface{})) {
+	existingCollation, hasCollation := existing["collation"]
+	if d.Collation == nil {
+		if hasCollation {
+			addDrift("collation", nil, existingCollation)
+		}
+		return
+	}
+	collation := bson.D{}
+	if err := bson.Unmarshal(d.Collation.ToDocument(), &collation); err != nil {
+		return
+	}
+	desired := normalizeBsonValue(collation)
+	if !hasCollation || !isBsonValueSubset(desired, existingCollation) {
+		addDrift("collation", desired, existingCollation)
+	}
+}
+
+// the value as decoded from the server,the go types such as []string and structs become
+// bson documents and arrays.the value is returned as is if it cannot be marshaled
+func bsonRoundTrip(v interface{}) interface{} {
+	data, err := bson.Marshal(bson.D{{Key: "v", Value: v}})
+	if err != nil {
+		return v
+	}
+	wrapper := bson.D{}
+	if err := bson.Unmarshal(data, &wrapper); err != nil || len(wrapper) <= 0 {
+		return v
+	}
+	return wrapper[0].Value
+}
+
+func toInterfaceList(stageList []bson.D) []interface{} {
+	result := make([]interface{}, 0, len(stageList))
+	for _, eachStage := range stageList {
+		result = append(result, normalizeBsonValue(eachStage))
+	}
+	return result
+}
+
+func isNamespaceExistsError(err error) bool {
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) {
+		return commandErr.Code == 48
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package mongodbr
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type collectionDefineTestProduct struct {
+	Sku   string   `bson:"sku" validate:"required"`
+	Price float64  `bson:"price" validate:"min=0"`
+	Tags  []string `bson:"tags"`
+}
+
+func TestCollectionDefineDiffValidator(t *testing.T) {
+	validator, err := NewJSONSchemaValidator(collectionDefineTestProduct{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the listCollections spec of the collection created with the generated validator,as the
+	//driver decodes it
+	spec := bson.D{
+		{Key: "name", Value: "products"},
+		{Key: "type", Value: "collection"},
+		{Key: "options", Value: bson.D{
+			{Key: "validator", Value: bson.D{
+				{Key: "$jsonSchema", Value: bson.D{
+					{Key: "bsonType", Value: "object"},
+					{Key: "required", Value: bson.A{"sku"}},
+					{Key: "properties", Value: bson.D{
+						{Key: "sku", Value: bson.D{{Key: "bsonType", Value: "string"}}},
+						{Key: "price", Value: bson.D{{Key: "bsonType", Value: "double"}, {Key: "minimum", Value: float64(0)}}},
+						{Key: "tags", Value: bson.D{
+							{Key: "bsonType", Value: bson.A{"array", "null"}},
+							{Key: "items", Value: bson.D{{Key: "bsonType", Value: "string"}}},
+						}},
+					}},
+				}},
+			}},
+			{Key: "validationLevel", Value: "strict"},
+			{Key: "validationAction", Value: "error"},
+		}},
+		{Key: "info", Value: bson.D{
+			{Key: "readOnly", Value: false},
+			{Key: "uuid", Value: primitive.Binary{Subtype: 4, Data: []byte{0x5c, 0x1d, 0x9b, 0x3e, 0x6f, 0x0a, 0x4b, 0x2c, 0x8e, 0x51, 0x7d, 0x22, 0x90, 0xab, 0x13, 0xf4}}},
+		}},
+		{Key: "idIndex", Value: bson.D{
+			{Key: "v", Value: int32(2)},
+			{Key: "key", Value: bson.D{{Key: "_id", Value: int32(1)}}},
+			{Key: "name", Value: "_id_"},
+		}},
+	}
+
+	testCases := []struct {
+		name      string
+		validator interface{}
+		drift     bool
+	}{
+		{"generated schema", validator, false},
+		{
+			"same schema with an int minimum",
+			bson.M{"$jsonSchema": bson.M{
+				"bsonType": "object",
+				"required": []string{"sku"},
+				"properties": bson.M{
+					"sku":   bson.M{"bsonType": "string"},
+					"price": bson.M{"bsonType": "double", "minimum": 0},
+					"tags":  bson.M{"bsonType": []string{"array", "null"}, "items": bson.M{"bsonType": "string"}},
+				},
+			}},
+			false,
+		},
+		{"other schema", bson.M{"$jsonSchema": bson.M{"bsonType": "object", "required": []string{"name"}}}, true},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			define := NewCollectionDefine("products").
+				WithValidator(eachCase.validator, ValidationLevelStrict, ValidationActionError)
+			driftList := define.diff(spec)
+			if (len(driftList) > 0) != eachCase.drift {
+				t.Errorf("expect drift %v,got %v", eachCase.drift, driftList)
+			}
+		})
+	}
+}
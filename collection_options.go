@@ -0,0 +1,114 @@
+package mongodbr
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/tag"
+)
+
+// read with rp,such as readpref.SecondaryPreferred(readpref.WithMaxStaleness(90*time.Second))
+func WithReadPreference(rp *readpref.ReadPref) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.readPreference = rp
+	}
+}
+
+func WithReadConcern(rc *readconcern.ReadConcern) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.readConcern = rc
+	}
+}
+
+// write with wc,such as writeconcern.New(writeconcern.WMajority(),writeconcern.J(true))
+func WithWriteConcern(wc *writeconcern.WriteConcern) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.writeConcern = wc
+	}
+}
+
+// build a read preference from the mode name,the tag sets and maxStaleness.
+// maxStaleness and tag sets are not allowed for primary
+func NewReadPreference(mode string, maxStaleness time.Duration, tagSets ...map[string]string) (*readpref.ReadPref, error) {
+	readMode, err := readpref.ModeFromString(mode)
+	if err != nil {
+		return nil, fmt.Errorf("unknown read preference mode %q,use primary,primaryPreferred,secondary,secondaryPreferred or nearest", mode)
+	}
+	optionList := make([]readpref.Option, 0, 2)
+	if maxStaleness > 0 {
+		optionList = append(optionList, readpref.WithMaxStaleness(maxStaleness))
+	}
+	if len(tagSets) > 0 {
+		setList := make([]tag.Set, 0, len(tagSets))
+		for _, eachTagSet := range tagSets {
+			setList = append(setList, tag.NewTagSetFromMap(eachTagSet))
+		}
+		optionList = append(optionList, readpref.WithTagSets(setList...))
+	}
+	return readpref.New(readMode, optionList...)
+}
+
+// the copy of the MongoCol using the options for the following calls,such as
+//
+//	repository.WithOptions(WithReadPreference(readpref.Secondary())).FindAll()
+//
+// panic if the collection options cannot be applied
+func (r *MongoCol) WithOptions(opts ...RepositoryOption) *MongoCol {
+	configuration := *r.configuration
+	for _, eachOpt := range opts {
+		eachOpt(&configuration)
+	}
+	mongoCol := *r
+	mongoCol.configuration = &configuration
+	if err := mongoCol.applyCollectionOptions(r.collection, r.readCollection); err != nil {
+		panic(err)
+	}
+	return &mongoCol
+}
+
+// the copy of the repository using the options for the following calls
+func (r *RepositoryBase) WithOptions(opts ...RepositoryOption) *RepositoryBase {
+	repository := *r
+	repository.MongoCol = r.MongoCol.WithOptions(opts...)
+	return &repository
+}
+
+// clone the collection with the read preference,read concern and write concern of the configuration
+func (c *Configuration) applyCollectionOptions(collection *mongo.Collection) (*mongo.Collection, error) {
+	if c.readPreference == nil && c.readConcern == nil && c.writeConcern == nil {
+		return collection, nil
+	}
+	collectionOptions := options.Collection()
+	if c.readPreference != nil {
+		collectionOptions.SetReadPreference(c.readPreference)
+	}
+	if c.readConcern != nil {
+		collectionOptions.SetReadConcern(c.readConcern)
+	}
+	if c.writeConcern != nil {
+		collectionOptions.SetWriteConcern(c.writeConcern)
+	}
+	cloned, err := collection.Clone(collectionOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply the options to collection %s: %w", collection.Name(), err)
+	}
+	return cloned, nil
+}
+
+// set the write and the read collection cloned with the options of the configuration,readCollection
+// can be nil
+func (r *MongoCol) applyCollectionOptions(collection *mongo.Collection, readCollection *mongo.Collection) (err error) {
+	if r.collection, err = r.configuration.applyCollectionOptions(collection); err != nil {
+		return err
+	}
+	if readCollection == nil {
+		return nil
+	}
+	r.readCollection, err = r.configuration.applyCollectionOptions(readCollection)
+	return err
+}
@@ -0,0 +1,659 @@
+package mongodbr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// prefix of the environment variables overriding the configuration
+	DefaultConfigEnvPrefix = "MONGODBR"
+)
+
+var (
+	ErrInvalidConfig = errors.New("invalid mongodbr configuration")
+
+	// repository settings and default databases of the applied configuration
+	_appliedConfig      *Config
+	_appliedConfigMutex sync.RWMutex
+)
+
+// duration written as a string such as 5s or 1m30s
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5s\": %w", err)
+	}
+	return d.parse(value)
+}
+
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return d.parse(node.Value)
+}
+
+func (d *Duration) parse(value string) error {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid duration %q,use a value such as \"5s\"", value)
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+type WriteConcernConfig struct {
+	// number of nodes,majority or a tag set name
+	W        interface{} `json:"w,omitempty" yaml:"w,omitempty"`
+	Journal  *bool       `json:"journal,omitempty" yaml:"journal,omitempty"`
+	WTimeout Duration    `json:"wtimeout,omitempty" yaml:"wtimeout,omitempty"`
+}
+
+type ClientConfig struct {
+	// connection string,${ENV} and ${ENV:-default} are expanded
+	URI string `json:"uri,omitempty" yaml:"uri,omitempty"`
+	// file containing the connection string,such as a mounted secret
+	URIFile  string `json:"uriFile,omitempty" yaml:"uriFile,omitempty"`
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	// file containing the password
+	PasswordFile string `json:"passwordFile,omitempty" yaml:"passwordFile,omitempty"`
+	AuthSource   string `json:"authSource,omitempty" yaml:"authSource,omitempty"`
+	// database used by the repositories of the client without a database name
+	DefaultDatabase string `json:"defaultDatabase,omitempty" yaml:"defaultDatabase,omitempty"`
+
+	MaxPoolSize            *uint64  `json:"maxPoolSize,omitempty" yaml:"maxPoolSize,omitempty"`
+	MinPoolSize            *uint64  `json:"minPoolSize,omitempty" yaml:"minPoolSize,omitempty"`
+	MaxConnIdleTime        Duration `json:"maxConnIdleTime,omitempty" yaml:"maxConnIdleTime,omitempty"`
+	ConnectTimeout         Duration `json:"connectTimeout,omitempty" yaml:"connectTimeout,omitempty"`
+	ServerSelectionTimeout Duration `json:"serverSelectionTimeout,omitempty" yaml:"serverSelectionTimeout,omitempty"`
+	SocketTimeout          Duration `json:"socketTimeout,omitempty" yaml:"socketTimeout,omitempty"`
+
+	ConcernConfig `yaml:",inline"`
+}
+
+// read preference,read concern and write concern of a client or a repository
+type ConcernConfig struct {
+	// primary,primaryPreferred,secondary,secondaryPreferred or nearest
+	ReadPreference     string              `json:"readPreference,omitempty" yaml:"readPreference,omitempty"`
+	ReadPreferenceTags []map[string]string `json:"readPreferenceTags,omitempty" yaml:"readPreferenceTags,omitempty"`
+	MaxStaleness       Duration            `json:"maxStaleness,omitempty" yaml:"maxStaleness,omitempty"`
+	// local,available,majority,linearizable or snapshot
+	ReadConcern  string              `json:"readConcern,omitempty" yaml:"readConcern,omitempty"`
+	WriteConcern *WriteConcernConfig `json:"writeConcern,omitempty" yaml:"writeConcern,omitempty"`
+}
+
+type RepositoryConfig struct {
+	// key of the client,default is DefaultClientKey
+	Client string `json:"client,omitempty" yaml:"client,omitempty"`
+	// key of the client serving the reads,such as an analytics cluster
+	ReadClient string `json:"readClient,omitempty" yaml:"readClient,omitempty"`
+	// database name,default is the defaultDatabase of the client
+	Database     string   `json:"database,omitempty" yaml:"database,omitempty"`
+	Collection   string   `json:"collection" yaml:"collection"`
+	QueryTimeout Duration `json:"queryTimeout,omitempty" yaml:"queryTimeout,omitempty"`
+	// sort fields,prefix - for descending such as ["-creationTime","name"]
+	DefaultSort []string `json:"defaultSort,omitempty" yaml:"defaultSort,omitempty"`
+
+	ConcernConfig `yaml:",inline"`
+}
+
+// Config is the mongodb configuration of a service
+type Config struct {
+	Clients      map[string]*ClientConfig `json:"clients" yaml:"clients"`
+	Repositories []*RepositoryConfig      `json:"repositories,omitempty" yaml:"repositories,omitempty"`
+}
+
+// #region load
+
+// load the configuration from a .yaml,.yml or .json file
+func LoadConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConfig, err.Error())
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return LoadConfigJSON(data)
+	case ".yaml", ".yml":
+		return LoadConfigYAML(data)
+	}
+	return nil, fmt.Errorf("%w: unsupported file %s,use .yaml,.yml or .json", ErrInvalidConfig, path)
+}
+
+func LoadConfigJSON(data []byte) (*Config, error) {
+	c := &Config{}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConfig, err.Error())
+	}
+	return c, nil
+}
+
+func LoadConfigYAML(data []byte) (*Config, error) {
+	c := &Config{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConfig, err.Error())
+	}
+	return c, nil
+}
+
+// override the configuration with the environment variables of prefix,such as
+//
+//	MONGODBR_CLIENTS=default,report
+//	MONGODBR_DEFAULT_URI=mongodb://localhost:27017
+//	MONGODBR_REPORT_MAX_POOL_SIZE=20
+//
+// a client listed in <prefix>_CLIENTS or having a <prefix>_<KEY>_URI is added if not configured
+func (c *Config) ApplyEnv(prefix string) error {
+	if len(prefix) <= 0 {
+		prefix = DefaultConfigEnvPrefix
+	}
+	if c.Clients == nil {
+		c.Clients = make(map[string]*ClientConfig)
+	}
+	if value, ok := os.LookupEnv(prefix + "_CLIENTS"); ok {
+		for _, eachKey := range strings.Split(value, ",") {
+			eachKey = strings.TrimSpace(eachKey)
+			if len(eachKey) > 0 && c.Clients[eachKey] == nil {
+				c.Clients[eachKey] = &ClientConfig{}
+			}
+		}
+	}
+	if _, ok := os.LookupEnv(prefix + "_" + envKey(DefaultClientKey) + "_URI"); ok && c.Clients[DefaultClientKey] == nil {
+		c.Clients[DefaultClientKey] = &ClientConfig{}
+	}
+
+	problemList := make([]string, 0)
+	for eachKey, eachClient := range c.Clients {
+		if eachClient == nil {
+			continue
+		}
+		envPrefix := prefix + "_" + envKey(eachKey) + "_"
+		lookup := func(name string) (string, bool) {
+			return os.LookupEnv(envPrefix + name)
+		}
+		setString := func(name string, target *string) {
+			if value, ok := lookup(name); ok {
+				*target = value
+			}
+		}
+		setUint := func(name string, target **uint64) {
+			if value, ok := lookup(name); ok {
+				number, err := strconv.ParseUint(value, 10, 64)
+				if err != nil {
+					problemList = append(problemList, fmt.Sprintf("%s%s: %q is not a non-negative integer", envPrefix, name, value))
+					return
+				}
+				*target = &number
+			}
+		}
+		setDuration := func(name string, target *Duration) {
+			if value, ok := lookup(name); ok {
+				if err := target.parse(value); err != nil {
+					problemList = append(problemList, fmt.Sprintf("%s%s: %s", envPrefix, name, err.Error()))
+				}
+			}
+		}
+		setString("URI", &eachClient.URI)
+		setString("USERNAME", &eachClient.Username)
+		setString("PASSWORD", &eachClient.Password)
+		setString("DEFAULT_DATABASE", &eachClient.DefaultDatabase)
+		setString("READ_PREFERENCE", &eachClient.ReadPreference)
+		setString("READ_CONCERN", &eachClient.ReadConcern)
+		setUint("MAX_POOL_SIZE", &eachClient.MaxPoolSize)
+		setUint("MIN_POOL_SIZE", &eachClient.MinPoolSize)
+		setDuration("CONNECT_TIMEOUT", &eachClient.ConnectTimeout)
+		setDuration("SERVER_SELECTION_TIMEOUT", &eachClient.ServerSelectionTimeout)
+		setDuration("SOCKET_TIMEOUT", &eachClient.SocketTimeout)
+		if value, ok := lookup("WRITE_CONCERN"); ok {
+			if eachClient.WriteConcern == nil {
+				eachClient.WriteConcern = &WriteConcernConfig{}
+			}
+			eachClient.WriteConcern.W = value
+		}
+	}
+	return configError(problemList)
+}
+
+// client key as a part of an environment variable name,such as report-db => REPORT_DB
+func envKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(key))
+}
+
+// #endregion
+
+// #region validate
+
+// check the configuration,every problem is reported with its path
+func (c *Config) Validate() error {
+	problemList := make([]string, 0)
+	if len(c.Clients) <= 0 {
+		problemList = append(problemList, "clients: at least one client is required")
+	}
+	for _, eachKey := range c.clientKeys() {
+		eachClient := c.Clients[eachKey]
+		path := "clients." + eachKey
+		if eachClient == nil {
+			problemList = append(problemList, path+": client is empty")
+			continue
+		}
+		if _, err := eachClient.resolveURI(); err != nil {
+			problemList = append(problemList, path+".uri: "+err.Error())
+		}
+		if _, err := expandEnv(eachClient.Username); err != nil {
+			problemList = append(problemList, path+".username: "+err.Error())
+		}
+		if _, err := eachClient.resolvePassword(); err != nil {
+			problemList = append(problemList, path+".password: "+err.Error())
+		}
+		if eachClient.MaxPoolSize != nil && eachClient.MinPoolSize != nil && *eachClient.MinPoolSize > *eachClient.MaxPoolSize {
+			problemList = append(problemList, path+".minPoolSize: cannot be greater than maxPoolSize")
+		}
+		problemList = append(problemList, eachClient.ConcernConfig.validate(path)...)
+	}
+	for i, eachRepository := range c.Repositories {
+		path := fmt.Sprintf("repositories[%d]", i)
+		if eachRepository == nil {
+			problemList = append(problemList, path+": repository is empty")
+			continue
+		}
+		if len(eachRepository.Collection) <= 0 {
+			problemList = append(problemList, path+".collection: required")
+		}
+		clientKey := eachRepository.clientKey()
+		client, ok := c.Clients[clientKey]
+		if !ok {
+			problemList = append(problemList, fmt.Sprintf("%s.client: client %q is not configured", path, clientKey))
+		} else if len(eachRepository.Database) <= 0 && (client == nil || len(client.DefaultDatabase) <= 0) {
+			problemList = append(problemList, fmt.Sprintf("%s.database: required because client %q has no defaultDatabase", path, clientKey))
+		}
+		if eachRepository.QueryTimeout < 0 {
+			problemList = append(problemList, path+".queryTimeout: cannot be negative")
+		}
+		if len(eachRepository.ReadClient) > 0 {
+			if _, ok := c.Clients[eachRepository.ReadClient]; !ok {
+				problemList = append(problemList, fmt.Sprintf("%s.readClient: client %q is not configured", path, eachRepository.ReadClient))
+			}
+		}
+		problemList = append(problemList, eachRepository.ConcernConfig.validate(path)...)
+		for j, eachField := range eachRepository.DefaultSort {
+			if len(strings.TrimLeft(eachField, "+-")) <= 0 {
+				problemList = append(problemList, fmt.Sprintf("%s.defaultSort[%d]: field name is required", path, j))
+			}
+		}
+	}
+	return configError(problemList)
+}
+
+func configError(problemList []string) error {
+	if len(problemList) <= 0 {
+		return nil
+	}
+	return fmt.Errorf("%w:\n  %s", ErrInvalidConfig, strings.Join(problemList, "\n  "))
+}
+
+func (c *ConcernConfig) validate(path string) []string {
+	problemList := make([]string, 0)
+	if len(c.ReadPreference) > 0 {
+		if _, err := c.readPreference(); err != nil {
+			problemList = append(problemList, path+".readPreference: "+err.Error())
+		}
+	} else if len(c.ReadPreferenceTags) > 0 || c.MaxStaleness > 0 {
+		problemList = append(problemList, path+".readPreference: required by readPreferenceTags and maxStaleness")
+	}
+	if len(c.ReadConcern) > 0 && !isReadConcernLevel(c.ReadConcern) {
+		problemList = append(problemList, fmt.Sprintf("%s.readConcern: unknown level %q,use local,available,majority,linearizable or snapshot", path, c.ReadConcern))
+	}
+	if c.WriteConcern != nil {
+		if _, err := c.WriteConcern.build(); err != nil {
+			problemList = append(problemList, path+".writeConcern: "+err.Error())
+		}
+	}
+	return problemList
+}
+
+func (c *ConcernConfig) readPreference() (*readpref.ReadPref, error) {
+	return NewReadPreference(c.ReadPreference, time.Duration(c.MaxStaleness), c.ReadPreferenceTags...)
+}
+
+func isReadConcernLevel(level string) bool {
+	switch level {
+	case "local", "available", "majority", "linearizable", "snapshot":
+		return true
+	}
+	return false
+}
+
+func (c *Config) clientKeys() []string {
+	keyList := make([]string, 0, len(c.Clients))
+	for eachKey := range c.Clients {
+		keyList = append(keyList, eachKey)
+	}
+	sort.Strings(keyList)
+	return keyList
+}
+
+// #endregion
+
+// #region apply
+
+// load the configuration file,override it with the environment variables of DefaultConfigEnvPrefix,
+// validate it and register the clients in DefaultClientRegistry
+func SetupFromConfigFile(ctx context.Context, path string) (*Config, error) {
+	c, err := LoadConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.ApplyEnv(DefaultConfigEnvPrefix); err != nil {
+		return nil, err
+	}
+	if err := c.Apply(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// validate the configuration and connect the clients in DefaultClientRegistry,the client of
+// DefaultClientKey becomes DefaultClient. the repositories created by NewRepository use the
+// QueryTimeout and default sort of the configuration
+func (c *Config) Apply(ctx context.Context) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	for _, eachKey := range c.clientKeys() {
+		eachClient := c.Clients[eachKey]
+		uri, _ := eachClient.resolveURI()
+		clientOptions, err := eachClient.clientOptions()
+		if err != nil {
+			return fmt.Errorf("clients.%s: %w", eachKey, err)
+		}
+		//the clients replaced stay connected for the repositories created from them
+		if eachKey == DefaultClientKey {
+			if _, err := SetupDefaultClient(uri, clientOptions); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := RegistClient(eachKey, uri, clientOptions); err != nil {
+			return err
+		}
+	}
+	_appliedConfigMutex.Lock()
+	_appliedConfig = c
+	_appliedConfigMutex.Unlock()
+	return nil
+}
+
+// connection string from uri or uriFile
+func (c *ClientConfig) resolveURI() (string, error) {
+	uri := c.URI
+	if len(c.URIFile) > 0 {
+		if len(uri) > 0 {
+			return "", errors.New("uri and uriFile cannot be both set")
+		}
+		data, err := os.ReadFile(c.URIFile)
+		if err != nil {
+			return "", fmt.Errorf("cannot read uriFile: %w", err)
+		}
+		uri = strings.TrimSpace(string(data))
+	}
+	uri, err := expandEnv(uri)
+	if err != nil {
+		return "", err
+	}
+	if len(uri) <= 0 {
+		return "", errors.New("required,set uri or uriFile")
+	}
+	if !strings.HasPrefix(uri, "mongodb://") && !strings.HasPrefix(uri, "mongodb+srv://") {
+		return "", errors.New("must start with mongodb:// or mongodb+srv://")
+	}
+	return uri, nil
+}
+
+// password from password or passwordFile
+func (c *ClientConfig) resolvePassword() (string, error) {
+	password := c.Password
+	if len(c.PasswordFile) > 0 {
+		if len(password) > 0 {
+			return "", errors.New("password and passwordFile cannot be both set")
+		}
+		data, err := os.ReadFile(c.PasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("cannot read passwordFile: %w", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	}
+	return expandEnv(password)
+}
+
+func (c *ClientConfig) clientOptions() (func(*options.ClientOptions), error) {
+	var credential *options.Credential
+	if len(c.Username) > 0 {
+		username, err := expandEnv(c.Username)
+		if err != nil {
+			return nil, fmt.Errorf("username: %w", err)
+		}
+		password, err := c.resolvePassword()
+		if err != nil {
+			return nil, fmt.Errorf("password: %w", err)
+		}
+		credential = &options.Credential{
+			Username:   username,
+			Password:   password,
+			AuthSource: c.AuthSource,
+		}
+	}
+	return func(co *options.ClientOptions) {
+		if credential != nil {
+			co.SetAuth(*credential)
+		}
+		if c.MaxPoolSize != nil {
+			co.SetMaxPoolSize(*c.MaxPoolSize)
+		}
+		if c.MinPoolSize != nil {
+			co.SetMinPoolSize(*c.MinPoolSize)
+		}
+		if c.MaxConnIdleTime > 0 {
+			co.SetMaxConnIdleTime(time.Duration(c.MaxConnIdleTime))
+		}
+		if c.ConnectTimeout > 0 {
+			co.SetConnectTimeout(time.Duration(c.ConnectTimeout))
+		}
+		if c.ServerSelectionTimeout > 0 {
+			co.SetServerSelectionTimeout(time.Duration(c.ServerSelectionTimeout))
+		}
+		if c.SocketTimeout > 0 {
+			co.SetSocketTimeout(time.Duration(c.SocketTimeout))
+		}
+		if len(c.ReadPreference) > 0 {
+			if rp, err := c.readPreference(); err == nil {
+				co.SetReadPreference(rp)
+			}
+		}
+		if len(c.ReadConcern) > 0 {
+			co.SetReadConcern(readconcern.New(readconcern.Level(c.ReadConcern)))
+		}
+		if c.WriteConcern != nil {
+			if wc, err := c.WriteConcern.build(); err == nil {
+				co.SetWriteConcern(wc)
+			}
+		}
+	}, nil
+}
+
+// RepositoryOption list of the concerns
+func (c *ConcernConfig) repositoryOptions() []RepositoryOption {
+	optionList := make([]RepositoryOption, 0, 3)
+	if len(c.ReadPreference) > 0 {
+		if rp, err := c.readPreference(); err == nil {
+			optionList = append(optionList, WithReadPreference(rp))
+		}
+	}
+	if len(c.ReadConcern) > 0 {
+		optionList = append(optionList, WithReadConcern(readconcern.New(readconcern.Level(c.ReadConcern))))
+	}
+	if c.WriteConcern != nil {
+		if wc, err := c.WriteConcern.build(); err == nil {
+			optionList = append(optionList, WithWriteConcern(wc))
+		}
+	}
+	return optionList
+}
+
+func (c *WriteConcernConfig) build() (*writeconcern.WriteConcern, error) {
+	optionList := make([]writeconcern.Option, 0, 3)
+	switch w := c.W.(type) {
+	case nil:
+	case int:
+		optionList = append(optionList, writeconcern.W(w))
+	case float64:
+		optionList = append(optionList, writeconcern.W(int(w)))
+	case string:
+		if number, err := strconv.Atoi(w); err == nil {
+			optionList = append(optionList, writeconcern.W(number))
+		} else if w == "majority" {
+			optionList = append(optionList, writeconcern.WMajority())
+		} else if len(w) > 0 {
+			optionList = append(optionList, writeconcern.WTagSet(w))
+		}
+	default:
+		return nil, fmt.Errorf("w must be a number,majority or a tag set name,but was %v", c.W)
+	}
+	if c.Journal != nil {
+		optionList = append(optionList, writeconcern.J(*c.Journal))
+	}
+	if c.WTimeout > 0 {
+		optionList = append(optionList, writeconcern.WTimeout(time.Duration(c.WTimeout)))
+	}
+	return writeconcern.New(optionList...), nil
+}
+
+// expand ${ENV} and ${ENV:-default},an unset variable without default is an error
+func expandEnv(value string) (string, error) {
+	missingList := make([]string, 0)
+	result := os.Expand(value, func(name string) string {
+		name, defaultValue, hasDefault := strings.Cut(name, ":-")
+		if envValue, ok := os.LookupEnv(name); ok {
+			return envValue
+		}
+		if !hasDefault {
+			missingList = append(missingList, name)
+		}
+		return defaultValue
+	})
+	if len(missingList) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set", strings.Join(missingList, ","))
+	}
+	return result, nil
+}
+
+func (r *RepositoryConfig) clientKey() string {
+	if len(r.Client) <= 0 {
+		return DefaultClientKey
+	}
+	return r.Client
+}
+
+func (r *RepositoryConfig) repositoryOptions() []RepositoryOption {
+	optionList := r.ConcernConfig.repositoryOptions()
+	if r.QueryTimeout > 0 {
+		queryTimeout := time.Duration(r.QueryTimeout)
+		optionList = append(optionList, func(configuration *Configuration) {
+			configuration.QueryTimeout = queryTimeout
+		})
+	}
+	if len(r.DefaultSort) > 0 {
+		sortDoc := bson.D{}
+		for _, eachField := range r.DefaultSort {
+			if strings.HasPrefix(eachField, "-") {
+				sortDoc = append(sortDoc, bson.E{Key: eachField[1:], Value: -1})
+			} else {
+				sortDoc = append(sortDoc, bson.E{Key: strings.TrimPrefix(eachField, "+"), Value: 1})
+			}
+		}
+		optionList = append(optionList, WithDefaultSort(func(fo *options.FindOptions) *options.FindOptions {
+			return fo.SetSort(sortDoc)
+		}))
+	}
+	return optionList
+}
+
+// default database of the client in the applied configuration
+func configuredDefaultDatabase(clientKey string) string {
+	_appliedConfigMutex.RLock()
+	defer _appliedConfigMutex.RUnlock()
+	if _appliedConfig == nil {
+		return ""
+	}
+	client, ok := _appliedConfig.Clients[clientKey]
+	if !ok || client == nil {
+		return ""
+	}
+	database, _ := expandEnv(client.DefaultDatabase)
+	return database
+}
+
+// repository options of the collection in the applied configuration,the read client must be
+// registered as RepositoryOptionWithReadClientKey requires
+func configuredRepositoryOptions(clientKey string, databaseName string, collectionName string) ([]RepositoryOption, error) {
+	_appliedConfigMutex.RLock()
+	defer _appliedConfigMutex.RUnlock()
+	if _appliedConfig == nil {
+		return nil, nil
+	}
+	for _, eachRepository := range _appliedConfig.Repositories {
+		if eachRepository.clientKey() != clientKey || eachRepository.Collection != collectionName {
+			continue
+		}
+		database := eachRepository.Database
+		if len(database) <= 0 {
+			if client := _appliedConfig.Clients[clientKey]; client != nil {
+				database, _ = expandEnv(client.DefaultDatabase)
+			}
+		}
+		if database != databaseName {
+			continue
+		}
+		optionList := eachRepository.repositoryOptions()
+		if len(eachRepository.ReadClient) > 0 {
+			readCollection := GetCollectionByKey(eachRepository.ReadClient, databaseName, collectionName)
+			if readCollection == nil {
+				return nil, newError(ErrorCodeReadClientNotRegistered, nil, "client", eachRepository.ReadClient)
+			}
+			optionList = append(optionList, WithReadCollection(readCollection))
+		}
+		return optionList, nil
+	}
+	return nil, nil
+}
+
+// #endregion
@@ -0,0 +1,76 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfiguredRepositoryOptionsReadClient(t *testing.T) {
+	_appliedConfigMutex.Lock()
+	previous := _appliedConfig
+	_appliedConfig = &Config{
+		Repositories: []*RepositoryConfig{
+			{Database: "shop", Collection: "products", ReadClient: "analytics-not-registered"},
+		},
+	}
+	_appliedConfigMutex.Unlock()
+	defer func() {
+		_appliedConfigMutex.Lock()
+		_appliedConfig = previous
+		_appliedConfigMutex.Unlock()
+	}()
+
+	_, err := configuredRepositoryOptions(DefaultClientKey, "shop", "products")
+	if !errors.Is(err, ErrReadClientNotRegistered) {
+		t.Fatalf("expect ErrReadClientNotRegistered,got %v", err)
+	}
+	optionList, err := configuredRepositoryOptions(DefaultClientKey, "shop", "orders")
+	if err != nil || optionList != nil {
+		t.Fatalf("expect no options for an unconfigured collection,got %v %v", optionList, err)
+	}
+}
+
+func TestConfigApplyKeepsReplacedClient(t *testing.T) {
+	ctx := context.Background()
+	config := &Config{Clients: map[string]*ClientConfig{
+		"config-test": {URI: "mongodb://localhost:27017"},
+	}}
+	if err := config.Apply(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer DefaultClientRegistry.Remove(ctx, "config-test")
+	old := GetClient("config-test")
+
+	config.Clients["config-test"].URI = "mongodb://localhost:27018"
+	if err := config.Apply(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if client := GetClient("config-test"); client == old {
+		t.Fatal("expect the client of the new configuration")
+	}
+	session, err := old.StartSession()
+	if err != nil {
+		t.Fatalf("the replaced client must stay connected,got %v", err)
+	}
+	session.EndSession(ctx)
+	old.Disconnect(ctx)
+}
+
+func TestClientConfigClientOptionsError(t *testing.T) {
+	testCases := []struct {
+		name   string
+		config *ClientConfig
+	}{
+		{"missing password file", &ClientConfig{Username: "user", PasswordFile: filepath.Join(t.TempDir(), "missing")}},
+		{"missing username variable", &ClientConfig{Username: "${MONGODBR_TEST_MISSING_USERNAME}"}},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			if _, err := eachCase.config.clientOptions(); err == nil {
+				t.Fatal("expect an error")
+			}
+		})
+	}
+}
@@ -2,7 +2,6 @@ package mongodbr
 
 import (
 	"context"
-	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -58,15 +57,14 @@ func GetCollectionByKey(key string, databaseName string, collectionName string,
 
 func Ping(client *mongo.Client) error {
 	if client == nil {
-		return fmt.Errorf("client is nil")
+		return ErrNilClient
 	}
 	//测试ping
 	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 	defer cancel()
 	err := client.Ping(ctx, readpref.Primary())
 	if err != nil {
-		return fmt.Errorf("mongodb ping测试时出现异常,异常信息:%s", err.Error())
+		return newError(ErrorCodePingFailed, err)
 	}
-	fmt.Println("mongodb ping测试正常")
 	return nil
 }
@@ -18,18 +18,22 @@ type IEntityBulkWrite interface {
 
 var _ IEntityBulkWrite = (*MongoCol)(nil)
 
-func _buildWriteModelForUpdate(list []IEntity) []mongo.WriteModel {
+func _buildWriteModelForUpdate(list []IEntity, configuration *Configuration) ([]mongo.WriteModel, error) {
 	modelList := make([]mongo.WriteModel, 0)
 	if len(list) <= 0 {
-		return modelList
+		return modelList, nil
 	}
 	for _, eachEntity := range list {
+		value, err := configuration.stampSchemaVersion(eachEntity)
+		if err != nil {
+			return nil, err
+		}
 		currentModel := mongo.NewUpdateOneModel()
 		currentModel.SetFilter(bson.M{"_id": eachEntity.GetObjectId()})
-		currentModel.SetUpdate(builder.NewBsonBuilder().NewOrUpdateSet(eachEntity))
+		currentModel.SetUpdate(builder.NewBsonBuilder().NewOrUpdateSet(value).ToValue())
 		modelList = append(modelList, currentModel)
 	}
-	return modelList
+	return modelList, nil
 }
 
 // build mongo.WriteModel list with ObjectId list
@@ -69,15 +73,16 @@ func (c *MongoCol) BulkWrite(models []mongo.WriteModel, opts ...*options.BulkWri
 	if len(models) <= 0 {
 		return nil, nil
 	}
-	//没有设置参数，使用默认的
-	ctx, cancel := CreateContext(c.configuration)
-	defer cancel()
-
-	res, err := c.collection.BulkWrite(
-		ctx,
-		models,
-		opts...,
-	)
+	var res *mongo.BulkWriteResult
+	err := c.executeWithIdempotency("bulkWrite", nil, func() bool { return isIdempotentWriteModels(models) }, func(op *operation) (err error) {
+		res, err = op.collection.BulkWrite(
+			op.ctx,
+			models,
+			opts...,
+		)
+		op.setBulkWriteResult(res)
+		return err
+	})
 	if err != nil {
 		return res, err
 	}
@@ -87,7 +92,10 @@ func (c *MongoCol) BulkWrite(models []mongo.WriteModel, opts ...*options.BulkWri
 
 func (c *MongoCol) BulkWriteEntityList(entityList []IEntity, opts ...*options.BulkWriteOptions) (
 	*mongo.BulkWriteResult, error) {
-	modelList := _buildWriteModelForUpdate(entityList)
+	modelList, err := _buildWriteModelForUpdate(entityList, c.configuration)
+	if err != nil {
+		return nil, err
+	}
 	return c.BulkWrite(modelList, opts...)
 }
 
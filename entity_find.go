@@ -3,6 +3,7 @@ package mongodbr
 import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -15,6 +16,7 @@ type IEntityFind interface {
 	FindByObjectId(id primitive.ObjectID) IFindResult
 	FindOne(filter interface{}, opts ...FindOneOption) IFindResult
 	FindByFilter(filter interface{}, opts ...FindOption) IFindResult
+	FindPage(filter interface{}, pageIndex int64, pageSize int64, opts ...PageOption) (*PageResult, error)
 
 	Distinct(fieldName string, filter interface{}) ([]interface{}, error)
 }
@@ -22,9 +24,12 @@ type IEntityFind interface {
 var _ IEntityFind = (*MongoCol)(nil)
 
 func (r *MongoCol) CountByFilter(filter interface{}) (int64, error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-	total, err := r.collection.CountDocuments(ctx, filter)
+	r.recordQueryShape("count", filter, nil)
+	var total int64
+	err := r.execute("count", filter, func(op *operation) (err error) {
+		total, err = op.collection.CountDocuments(op.ctx, filter)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -32,9 +37,11 @@ func (r *MongoCol) CountByFilter(filter interface{}) (int64, error) {
 }
 
 func (r *MongoCol) CountAll() (count int64, err error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-	total, err := r.collection.EstimatedDocumentCount(ctx)
+	var total int64
+	err = r.execute("estimatedDocumentCount", nil, func(op *operation) (err error) {
+		total, err = op.collection.EstimatedDocumentCount(op.ctx)
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -52,33 +59,36 @@ func (r *MongoCol) FindByObjectId(id primitive.ObjectID) IFindResult {
 
 // 查找一条记录
 func (r *MongoCol) FindOne(filter interface{}, opts ...FindOneOption) IFindResult {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
 	//设置默认搜索参数
 	findOneOptions := options.FindOne()
 	for _, o := range opts {
 		o(findOneOptions)
 	}
+	r.recordQueryShape("findOne", filter, findOneOptions.Sort)
 
-	res := r.collection.FindOne(ctx, filter, findOneOptions)
-	if res.Err() != nil {
+	var res *mongo.SingleResult
+	err := r.execute("findOne", filter, func(op *operation) error {
+		res = op.collection.FindOne(op.ctx, filter, findOneOptions)
+		if res.Err() == nil {
+			op.returned = 1
+		}
+		return res.Err()
+	})
+	if err != nil {
 		return &findResult{
 			configuration: r.configuration,
-			err:           res.Err(),
+			err:           err,
 		}
 	}
 	return &findResult{
 		configuration: r.configuration,
+		collection:    r.collection,
 		res:           res,
 	}
 }
 
 // 根据条件来筛选
 func (r *MongoCol) FindByFilter(filter interface{}, opts ...FindOption) IFindResult {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
 	//设置默认搜索参数
 	findOptions := options.Find()
 	if r.configuration.setDefaultSort != nil {
@@ -87,7 +97,17 @@ func (r *MongoCol) FindByFilter(filter interface{}, opts ...FindOption) IFindRes
 	for _, o := range opts {
 		o(findOptions)
 	}
-	cur, err := r.collection.Find(ctx, filter, findOptions)
+	r.recordQueryShape("find", filter, findOptions.Sort)
+
+	var cur *mongo.Cursor
+	err := r.execute("find", filter, func(op *operation) (err error) {
+		cur, err = op.collection.Find(op.ctx, filter, findOptions)
+		if err == nil {
+			//documents of the first batch
+			op.returned = int64(cur.RemainingBatchLength())
+		}
+		return err
+	})
 	if err != nil {
 		return &findResult{
 			configuration: r.configuration,
@@ -96,13 +116,24 @@ func (r *MongoCol) FindByFilter(filter interface{}, opts ...FindOption) IFindRes
 	}
 	return &findResult{
 		configuration: r.configuration,
+		collection:    r.collection,
 		cur:           cur,
 	}
 }
 
 func (r *MongoCol) Distinct(fieldName string, filter interface{}) ([]interface{}, error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
+	var valueList []interface{}
+	err := r.execute("distinct", filter, func(op *operation) (err error) {
+		valueList, err = op.collection.Distinct(op.ctx, fieldName, filter)
+		op.returned = int64(len(valueList))
+		return err
+	})
+	return valueList, err
+}
 
-	return r.collection.Distinct(ctx, fieldName, filter)
+func (r *MongoCol) recordQueryShape(operation string, filter interface{}, sort interface{}) {
+	if r.configuration.indexAdvisor == nil {
+		return
+	}
+	r.configuration.indexAdvisor.Record(r.collection, operation, filter, sort)
 }
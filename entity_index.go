@@ -1,6 +1,10 @@
 package mongodbr
 
 import (
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -14,16 +18,24 @@ type IEntityIndex interface {
 	DeleteIndex(name string) (err error)
 	DeleteAllIndexes() (err error)
 	ListIndexes() (indexes []map[string]interface{}, err error)
+	// create missing indexes and report the difference with the existing ones
+	EnsureIndexes(defineList []*EntityIndexDefine, opts ...EnsureIndexesOption) (*IndexPlan, error)
 }
 
 var _ IEntityIndex = (*MongoCol)(nil)
 
 // #region indexes members
 
+// the model is validated as EntityIndexDefine.Validate before it is sent to the server
 func (r *MongoCol) CreateIndex(indexModel mongo.IndexModel, opts ...*options.CreateIndexesOptions) (string, error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-	name, err := r.collection.Indexes().CreateOne(ctx, indexModel, opts...)
+	if err := validateIndexModel(indexModel); err != nil {
+		return "", err
+	}
+	var name string
+	err := r.execute("createIndexes", nil, func(op *operation) (err error) {
+		name, err = op.collection.Indexes().CreateOne(op.ctx, indexModel, opts...)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -31,54 +43,78 @@ func (r *MongoCol) CreateIndex(indexModel mongo.IndexModel, opts ...*options.Cre
 }
 
 func (r *MongoCol) CreateIndexes(indexModelList []mongo.IndexModel, opts ...*options.CreateIndexesOptions) ([]string, error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
-	return r.collection.Indexes().CreateMany(ctx, indexModelList, opts...)
+	for _, eachModel := range indexModelList {
+		if err := validateIndexModel(eachModel); err != nil {
+			return nil, err
+		}
+	}
+	var nameList []string
+	err := r.execute("createIndexes", nil, func(op *operation) (err error) {
+		nameList, err = op.collection.Indexes().CreateMany(op.ctx, indexModelList, opts...)
+		return err
+	})
+	return nameList, err
 }
 
+// create index,panic if failed
 func (r *MongoCol) MustCreateIndex(indexModel mongo.IndexModel, opts ...*options.CreateIndexesOptions) {
-	r.CreateIndex(indexModel, opts...)
+	if _, err := r.CreateIndex(indexModel, opts...); err != nil {
+		panic(fmt.Errorf("failed to create index %s on %s: %w", describeIndexModel(indexModel), r.collection.Name(), err))
+	}
 }
 
+// create indexes,panic if failed
 func (r *MongoCol) MustCreateIndexes(indexModelList []mongo.IndexModel, opts ...*options.CreateIndexesOptions) {
-	r.CreateIndexes(indexModelList, opts...)
+	if _, err := r.CreateIndexes(indexModelList, opts...); err != nil {
+		nameList := make([]string, 0, len(indexModelList))
+		for _, eachModel := range indexModelList {
+			nameList = append(nameList, describeIndexModel(eachModel))
+		}
+		panic(fmt.Errorf("failed to create indexes %s on %s: %w", strings.Join(nameList, ","), r.collection.Name(), err))
+	}
 }
 
 func (r *MongoCol) DeleteIndex(name string) (err error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
-	_, err = r.collection.Indexes().DropOne(ctx, name)
-	if err != nil {
+	return r.execute("dropIndexes", nil, func(op *operation) error {
+		_, err := op.collection.Indexes().DropOne(op.ctx, name)
 		return err
-	}
-	return nil
+	})
 }
 
 func (r *MongoCol) DeleteAllIndexes() (err error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
-	_, err = r.collection.Indexes().DropAll(ctx)
-	if err != nil {
+	return r.execute("dropIndexes", nil, func(op *operation) error {
+		_, err := op.collection.Indexes().DropAll(op.ctx)
 		return err
-	}
-	return nil
+	})
 }
 
 func (r *MongoCol) ListIndexes() (indexes []map[string]interface{}, err error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
-	cur, err := r.collection.Indexes().List(ctx)
+	err = r.execute("listIndexes", nil, func(op *operation) error {
+		cur, err := op.collection.Indexes().List(op.ctx)
+		if err != nil {
+			return err
+		}
+		if err := cur.All(op.ctx, &indexes); err != nil {
+			return err
+		}
+		op.returned = int64(len(indexes))
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	if err := cur.All(ctx, &indexes); err != nil {
-		return nil, err
-	}
 	return indexes, nil
 }
 
 // #endregion
+
+// index name if specified,otherwise the keys
+func describeIndexModel(indexModel mongo.IndexModel) string {
+	if indexModel.Options != nil && indexModel.Options.Name != nil {
+		return *indexModel.Options.Name
+	}
+	if keys, ok := indexModel.Keys.(bson.D); ok {
+		return "{" + indexKeysString(keys) + "}"
+	}
+	return fmt.Sprintf("%v", indexModel.Keys)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/shanluzhineng/mongodbr/builder"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -25,7 +26,11 @@ func (r *MongoCol) FindOneAndUpdate(entity IEntity, opts ...*options.FindOneAndU
 	// }
 
 	objectId := entity.GetObjectId()
-	update := builder.NewBsonBuilder().NewOrUpdateSet(entity).ToValue()
+	value, err := r.configuration.stampSchemaVersion(entity)
+	if err != nil {
+		return err
+	}
+	update := builder.NewBsonBuilder().NewOrUpdateSet(value).ToValue()
 	return r.FindOneAndUpdateWithId(objectId, update, opts...)
 }
 
@@ -34,42 +39,40 @@ func (r *MongoCol) FindOneAndUpdateWithId(objectId primitive.ObjectID, update in
 	// 	return fmt.Errorf("在保存%s数据时objectId不能为nil", r.documentName)
 	// }
 	//没有设置参数，使用默认的
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
 	if len(opts) <= 0 {
 		opts = make([]*options.FindOneAndUpdateOptions, 0)
 		opts = append(opts, options.FindOneAndUpdate().SetUpsert(false))
 	}
-	if err := r.collection.FindOneAndUpdate(
-		ctx,
-		bson.M{"_id": objectId},
-		update,
-		opts...,
-	).Err(); err != nil {
-		return err
-	}
-
-	return nil
+	filter := bson.M{"_id": objectId}
+	return r.executeWithIdempotency("findOneAndUpdate", filter, func() bool { return isIdempotentUpdate(update) }, func(op *operation) error {
+		if err := op.collection.FindOneAndUpdate(
+			op.ctx,
+			filter,
+			update,
+			opts...,
+		).Err(); err != nil {
+			return err
+		}
+		op.matched = 1
+		return nil
+	})
 }
 
 func (r *MongoCol) UpdateOne(filter interface{}, update interface{}, opts ...*options.UpdateOptions) error {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
-	_, err := r.collection.UpdateOne(ctx, filter, update, opts...)
-	if err != nil {
+	return r.executeWithIdempotency("updateOne", filter, func() bool { return isIdempotentUpdate(update) }, func(op *operation) error {
+		result, err := op.collection.UpdateOne(op.ctx, filter, update, opts...)
+		op.setUpdateResult(result)
 		return err
-	}
-
-	return nil
+	})
 }
 
 func (r *MongoCol) UpdateMany(filter interface{}, update interface{}, opts ...*options.UpdateOptions) (interface{}, error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
-	result, err := r.collection.UpdateMany(ctx, filter, update, opts...)
+	var result *mongo.UpdateResult
+	err := r.executeWithIdempotency("updateMany", filter, func() bool { return isIdempotentUpdate(update) }, func(op *operation) (err error) {
+		result, err = op.collection.UpdateMany(op.ctx, filter, update, opts...)
+		op.setUpdateResult(result)
+		return err
+	})
 	if err != nil {
 		if result != nil {
 			return result.UpsertedID, err
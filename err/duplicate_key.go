@@ -0,0 +1,116 @@
+package err
+
+import (
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	// E11000 duplicate key error collection: db.users index: email_1 dup key: { email: "a@b.c" }
+	_duplicateKeyCollectionRegexp = regexp.MustCompile(`collection: (\S+)`)
+	_duplicateKeyIndexRegexp      = regexp.MustCompile(`index: (\S+)`)
+	_duplicateKeyValueRegexp      = regexp.MustCompile(`dup key: (\{.*\})`)
+	// unquoted field names of the dup key
+	_fieldNameRegexp = regexp.MustCompile(`([{,]\s*)([A-Za-z_$][\w.$]*)\s*:`)
+)
+
+// a write violating a unique index
+type DuplicateKeyError struct {
+	// position of the write within InsertMany or BulkWrite,-1 if the operation has only one write
+	WriteIndex int
+	// full name of the collection,such as db.users
+	Collection string
+	// name of the violated index,such as email_1
+	IndexName  string
+	KeyPattern bson.M
+	// the values conflicting with an existing document,nil if they cannot be parsed
+	KeyValue bson.M
+	Message  string
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return e.Message
+}
+
+func (e *DuplicateKeyError) Unwrap() error {
+	return ErrDuplicateKey
+}
+
+func isDuplicateKeyCode(code int, message string) bool {
+	switch code {
+	case CodeDuplicateKey, CodeDuplicateKeyOnUpdate, CodeDuplicateKeyOnCreateIndex:
+		return true
+	case CodeDuplicateKeyOnMapReduce:
+		return strings.Contains(message, " E11000 ")
+	}
+	return false
+}
+
+// the keyPattern and keyValue are reported by the server since 4.2,the message is parsed for the
+// older servers
+func newDuplicateKeyError(writeIndex int, message string, raw bson.Raw) *DuplicateKeyError {
+	e := &DuplicateKeyError{
+		WriteIndex: writeIndex,
+		Message:    message,
+		KeyPattern: lookupDocument(raw, "keyPattern"),
+		KeyValue:   lookupDocument(raw, "keyValue"),
+	}
+	if match := _duplicateKeyCollectionRegexp.FindStringSubmatch(message); match != nil {
+		e.Collection = match[1]
+	}
+	if match := _duplicateKeyIndexRegexp.FindStringSubmatch(message); match != nil {
+		e.IndexName = match[1]
+		//index: db.users.$email_1 before 3.4
+		if i := strings.LastIndex(e.IndexName, ".$"); i >= 0 {
+			if len(e.Collection) <= 0 {
+				e.Collection = e.IndexName[:i]
+			}
+			e.IndexName = e.IndexName[i+2:]
+		}
+	}
+	if e.KeyValue == nil {
+		if match := _duplicateKeyValueRegexp.FindStringSubmatch(message); match != nil {
+			e.KeyValue = parseDuplicateKeyValue(match[1])
+		}
+	}
+	return e
+}
+
+// parse { email: "a@b.c" } of the message,nil if the values are not ext json,such as ObjectId('...')
+func parseDuplicateKeyValue(text string) bson.M {
+	quoted := _fieldNameRegexp.ReplaceAllString(text, `$1"$2":`)
+	keyValue := bson.M{}
+	if err := bson.UnmarshalExtJSON([]byte(quoted), false, &keyValue); err != nil {
+		return nil
+	}
+	return keyValue
+}
+
+// the sub document of raw,nil if not exists
+func lookupDocument(raw bson.Raw, key string) bson.M {
+	if len(raw) <= 0 {
+		return nil
+	}
+	value, err := raw.LookupErr(key)
+	if err != nil {
+		return nil
+	}
+	document, ok := value.DocumentOK()
+	if !ok {
+		return nil
+	}
+	return toDocument(document)
+}
+
+func toDocument(raw bson.Raw) bson.M {
+	if len(raw) <= 0 {
+		return nil
+	}
+	m := bson.M{}
+	if err := bson.Unmarshal(raw, &m); err != nil {
+		return nil
+	}
+	return m
+}
@@ -0,0 +1,137 @@
+package err
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestNewDuplicateKeyError(t *testing.T) {
+	raw, err := bson.Marshal(bson.D{
+		{Key: "keyPattern", Value: bson.D{{Key: "email", Value: 1}}},
+		{Key: "keyValue", Value: bson.D{{Key: "email", Value: "raw@b.c"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name       string
+		message    string
+		raw        bson.Raw
+		collection string
+		indexName  string
+		keyPattern bson.M
+		keyValue   bson.M
+	}{
+		{
+			name:       "keyPattern and keyValue from raw",
+			message:    `E11000 duplicate key error collection: db.users index: email_1 dup key: { email: "a@b.c" }`,
+			raw:        raw,
+			collection: "db.users",
+			indexName:  "email_1",
+			keyPattern: bson.M{"email": int32(1)},
+			keyValue:   bson.M{"email": "raw@b.c"},
+		},
+		{
+			name:       "keyValue from the message",
+			message:    `E11000 duplicate key error collection: db.users index: email_1 dup key: { email: "a@b.c" }`,
+			collection: "db.users",
+			indexName:  "email_1",
+			keyValue:   bson.M{"email": "a@b.c"},
+		},
+		{
+			name:       "compound key with dotted field",
+			message:    `E11000 duplicate key error collection: db.users index: tenant_1_profile.email_1 dup key: { tenant: "t1", profile.email: "a@b.c" }`,
+			collection: "db.users",
+			indexName:  "tenant_1_profile.email_1",
+			keyValue:   bson.M{"tenant": "t1", "profile.email": "a@b.c"},
+		},
+		{
+			name:       "index with the collection before 3.4",
+			message:    `E11000 duplicate key error index: db.users.$email_1 dup key: { : "a@b.c" }`,
+			collection: "db.users",
+			indexName:  "email_1",
+		},
+		{
+			name:       "values not ext json",
+			message:    `E11000 duplicate key error collection: db.users index: _id_ dup key: { _id: ObjectId('5f1d7f9e8b3c2a1d4e5f6a7b') }`,
+			collection: "db.users",
+			indexName:  "_id_",
+		},
+		{
+			name:    "unknown message",
+			message: "duplicate key",
+		},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			e := newDuplicateKeyError(2, eachCase.message, eachCase.raw)
+			if e.WriteIndex != 2 || e.Message != eachCase.message {
+				t.Fatalf("unexpected write index %d or message %s", e.WriteIndex, e.Message)
+			}
+			if e.Collection != eachCase.collection {
+				t.Fatalf("expect collection %s,got %s", eachCase.collection, e.Collection)
+			}
+			if e.IndexName != eachCase.indexName {
+				t.Fatalf("expect index %s,got %s", eachCase.indexName, e.IndexName)
+			}
+			if !reflect.DeepEqual(e.KeyPattern, eachCase.keyPattern) {
+				t.Fatalf("expect key pattern %v,got %v", eachCase.keyPattern, e.KeyPattern)
+			}
+			if !reflect.DeepEqual(e.KeyValue, eachCase.keyValue) {
+				t.Fatalf("expect key value %v,got %v", eachCase.keyValue, e.KeyValue)
+			}
+		})
+	}
+}
+
+func TestAsDuplicateKey(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		duplicate  bool
+		writeIndex int
+	}{
+		{
+			name: "write exception",
+			err: mongo.WriteException{WriteErrors: mongo.WriteErrors{
+				{Index: 1, Code: CodeDuplicateKey, Message: `E11000 duplicate key error collection: db.users index: email_1 dup key: { email: "a@b.c" }`},
+			}},
+			duplicate:  true,
+			writeIndex: 1,
+		},
+		{
+			name:       "command error",
+			err:        mongo.CommandError{Code: CodeDuplicateKeyOnCreateIndex, Message: "E11000 duplicate key error"},
+			duplicate:  true,
+			writeIndex: -1,
+		},
+		{
+			name:       "map reduce with E11000",
+			err:        mongo.CommandError{Code: CodeDuplicateKeyOnMapReduce, Message: "insert failed: E11000 duplicate key error"},
+			duplicate:  true,
+			writeIndex: -1,
+		},
+		{
+			name: "map reduce without E11000",
+			err:  mongo.CommandError{Code: CodeDuplicateKeyOnMapReduce, Message: "insert failed"},
+		},
+		{
+			name: "other code",
+			err:  mongo.CommandError{Code: CodeWriteConflict, Message: "write conflict"},
+		},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			e, ok := AsDuplicateKey(eachCase.err)
+			if ok != eachCase.duplicate || IsDuplicateKey(eachCase.err) != eachCase.duplicate {
+				t.Fatalf("expect duplicate %v,got %v", eachCase.duplicate, ok)
+			}
+			if ok && e.WriteIndex != eachCase.writeIndex {
+				t.Fatalf("expect write index %d,got %d", eachCase.writeIndex, e.WriteIndex)
+			}
+		})
+	}
+}
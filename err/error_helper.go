@@ -1,14 +1,67 @@
 package err
 
-import "go.mongodb.org/mongo-driver/mongo"
+import "errors"
 
+// the error is caused by a unique index,such as the insert of an existing key
+func IsDuplicateKey(err error) bool {
+	return errors.Is(Wrap(err), ErrDuplicateKey)
+}
+
+// the first duplicate key error within err,the write errors of InsertMany and BulkWrite are
+// listed by Error.DuplicateKeys
+func AsDuplicateKey(err error) (*DuplicateKeyError, bool) {
+	var duplicateKeyErr *DuplicateKeyError
+	ok := errors.As(Wrap(err), &duplicateKeyErr)
+	return duplicateKeyErr, ok
+}
+
+// Deprecated: use IsDuplicateKey
 func IsDuplicateKeyError(err error) bool {
-	// TODO: maybe there is (or will be) a better way of checking duplicate key error
-	// this one is based on https://github.com/mongodb/mongo-go-driver/blob/master/mongo/integration/collection_test.go#L54-L65
-	we, ok := err.(mongo.WriteException)
-	if !ok {
-		return false
+	return IsDuplicateKey(err)
+}
+
+// the document is not found,such as FindOne without a match
+func IsNotFound(err error) bool {
+	return errors.Is(Wrap(err), ErrNotFound)
+}
+
+// the operation timed out,such as the deadline of the context,maxTimeMS or the server selection
+func IsTimeout(err error) bool {
+	return errors.Is(Wrap(err), ErrTimeout)
+}
+
+func IsNetwork(err error) bool {
+	return errors.Is(Wrap(err), ErrNetwork)
+}
+
+// the failure is temporary,such as a network error,a primary step down or a write conflict
+func IsTransient(err error) bool {
+	return errors.Is(Wrap(err), ErrTransient)
+}
+
+// the operation may succeed when it is run again,it is transient or labeled as retryable by
+// the server
+func IsRetryable(err error) bool {
+	wrapped := Wrap(err)
+	var e *Error
+	if errors.As(wrapped, &e) && e.HasLabel(labelRetryableWriteError) {
+		return true
 	}
+	return errors.Is(wrapped, ErrTransient)
+}
+
+// the write conflicts with a concurrent transaction
+func IsWriteConflict(err error) bool {
+	return errors.Is(Wrap(err), ErrWriteConflict)
+}
+
+func IsDocumentValidationFailure(err error) bool {
+	return errors.Is(Wrap(err), ErrDocumentValidation)
+}
 
-	return len(we.WriteErrors) > 0 && we.WriteErrors[0].Code == 11000
+// the first document validation failure within err
+func AsDocumentValidationFailure(err error) (*DocumentValidationError, bool) {
+	var validationErr *DocumentValidationError
+	ok := errors.As(Wrap(err), &validationErr)
+	return validationErr, ok
 }
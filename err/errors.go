@@ -0,0 +1,209 @@
+package err
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// kinds of the errors,match them with errors.Is
+var (
+	ErrDuplicateKey       = errors.New("duplicate key")
+	ErrNotFound           = errors.New("document not found")
+	ErrTimeout            = errors.New("timeout")
+	ErrNetwork            = errors.New("network error")
+	ErrTransient          = errors.New("transient error")
+	ErrWriteConflict      = errors.New("write conflict")
+	ErrDocumentValidation = errors.New("document validation failure")
+)
+
+// server error codes
+const (
+	CodeHostUnreachable                 = 6
+	CodeHostNotFound                    = 7
+	CodeNetworkTimeout                  = 89
+	CodeShutdownInProgress              = 91
+	CodeWriteConflict                   = 112
+	CodeDocumentValidationFailure       = 121
+	CodeReadConcernMajorityNotAvailable = 134
+	CodePrimarySteppedDown              = 189
+	CodeExceededTimeLimit               = 262
+	CodeSocketException                 = 9001
+	CodeNotWritablePrimary              = 10107
+	CodeDuplicateKey                    = 11000
+	CodeDuplicateKeyOnUpdate            = 11001
+	CodeInterruptedAtShutdown           = 11600
+	CodeInterruptedDueToReplStateChange = 11602
+	CodeDuplicateKeyOnCreateIndex       = 12582
+	CodeNotPrimaryNoSecondaryOk         = 13435
+	CodeNotPrimaryOrSecondary           = 13436
+	CodeDuplicateKeyOnMapReduce         = 16460
+	labelTransientTransactionError      = "TransientTransactionError"
+	labelRetryableWriteError            = "RetryableWriteError"
+	labelUnknownTransactionCommitResult = "UnknownTransactionCommitResult"
+)
+
+// the failure is temporary,the operation may succeed when it is run again
+var _transientCodes = map[int]bool{
+	CodeHostUnreachable:                 true,
+	CodeHostNotFound:                    true,
+	CodeNetworkTimeout:                  true,
+	CodeShutdownInProgress:              true,
+	CodeWriteConflict:                   true,
+	CodeReadConcernMajorityNotAvailable: true,
+	CodePrimarySteppedDown:              true,
+	CodeExceededTimeLimit:               true,
+	CodeSocketException:                 true,
+	CodeNotWritablePrimary:              true,
+	CodeInterruptedAtShutdown:           true,
+	CodeInterruptedDueToReplStateChange: true,
+	CodeNotPrimaryNoSecondaryOk:         true,
+	CodeNotPrimaryOrSecondary:           true,
+}
+
+// Error is the classified error returned by the repository methods,it unwraps to the driver
+// error,the details and the kinds,so errors.Is(err, ErrDuplicateKey) and
+// errors.As(err, &mongo.WriteException{}) both work
+type Error struct {
+	// the first server error code,0 if the error does not come from the server
+	Code   int
+	Labels []string
+	// one for each duplicate key write error
+	DuplicateKeys []*DuplicateKeyError
+	// one for each document failing the validation
+	ValidationFailures []*DocumentValidationError
+
+	Err   error
+	kinds []error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error {
+	errList := make([]error, 0, 1+len(e.DuplicateKeys)+len(e.ValidationFailures)+len(e.kinds))
+	errList = append(errList, e.Err)
+	for _, eachDuplicateKey := range e.DuplicateKeys {
+		errList = append(errList, eachDuplicateKey)
+	}
+	for _, eachFailure := range e.ValidationFailures {
+		errList = append(errList, eachFailure)
+	}
+	return append(errList, e.kinds...)
+}
+
+// the error has the label
+func (e *Error) HasLabel(label string) bool {
+	for _, eachLabel := range e.Labels {
+		if eachLabel == label {
+			return true
+		}
+	}
+	return false
+}
+
+// classify err,nil if err is nil.
+// err is returned as is if it is already classified
+func Wrap(err error) error {
+	if err == nil {
+		return nil
+	}
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+	return classify(err)
+}
+
+func classify(err error) *Error {
+	e := &Error{Err: err}
+	codeList := e.collect(err)
+	if len(codeList) > 0 {
+		e.Code = codeList[0]
+	}
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		e.kinds = append(e.kinds, ErrNotFound)
+	}
+	if mongo.IsTimeout(err) || errors.Is(err, context.DeadlineExceeded) {
+		e.kinds = append(e.kinds, ErrTimeout)
+	}
+	network := mongo.IsNetworkError(err)
+	if network {
+		e.kinds = append(e.kinds, ErrNetwork)
+	}
+	transient := network || e.HasLabel(labelTransientTransactionError) || e.HasLabel(labelUnknownTransactionCommitResult)
+	for _, eachCode := range codeList {
+		if eachCode == CodeWriteConflict && !containsError(e.kinds, ErrWriteConflict) {
+			e.kinds = append(e.kinds, ErrWriteConflict)
+		}
+		if _transientCodes[eachCode] {
+			transient = true
+		}
+	}
+	if transient {
+		e.kinds = append(e.kinds, ErrTransient)
+	}
+	return e
+}
+
+// collect the codes,labels and details of the server errors within err
+func (e *Error) collect(err error) []int {
+	codeList := make([]int, 0)
+
+	var commandErr mongo.CommandError
+	if errors.As(err, &commandErr) {
+		codeList = append(codeList, int(commandErr.Code))
+		e.Labels = append(e.Labels, commandErr.Labels...)
+		if isDuplicateKeyCode(int(commandErr.Code), commandErr.Message) {
+			e.DuplicateKeys = append(e.DuplicateKeys, newDuplicateKeyError(-1, commandErr.Message, commandErr.Raw))
+		}
+		if commandErr.Code == CodeDocumentValidationFailure {
+			e.ValidationFailures = append(e.ValidationFailures, newDocumentValidationError(-1, commandErr.Message, lookupDocument(commandErr.Raw, "errInfo")))
+		}
+	}
+
+	var writeException mongo.WriteException
+	if errors.As(err, &writeException) {
+		e.Labels = append(e.Labels, writeException.Labels...)
+		for _, eachWriteError := range writeException.WriteErrors {
+			codeList = append(codeList, e.collectWriteError(eachWriteError))
+		}
+		if writeException.WriteConcernError != nil {
+			codeList = append(codeList, writeException.WriteConcernError.Code)
+		}
+	}
+
+	var bulkWriteException mongo.BulkWriteException
+	if errors.As(err, &bulkWriteException) {
+		e.Labels = append(e.Labels, bulkWriteException.Labels...)
+		for _, eachWriteError := range bulkWriteException.WriteErrors {
+			codeList = append(codeList, e.collectWriteError(eachWriteError.WriteError))
+		}
+		if bulkWriteException.WriteConcernError != nil {
+			codeList = append(codeList, bulkWriteException.WriteConcernError.Code)
+		}
+	}
+	return codeList
+}
+
+func (e *Error) collectWriteError(writeError mongo.WriteError) int {
+	if isDuplicateKeyCode(writeError.Code, writeError.Message) {
+		e.DuplicateKeys = append(e.DuplicateKeys, newDuplicateKeyError(writeError.Index, writeError.Message, writeError.Raw))
+	}
+	if writeError.Code == CodeDocumentValidationFailure {
+		e.ValidationFailures = append(e.ValidationFailures, newDocumentValidationError(writeError.Index, writeError.Message, toDocument(writeError.Details)))
+	}
+	return writeError.Code
+}
+
+func containsError(errList []error, target error) bool {
+	for _, eachErr := range errList {
+		if eachErr == target {
+			return true
+		}
+	}
+	return false
+}
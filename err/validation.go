@@ -0,0 +1,41 @@
+package err
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// a document rejected by the $jsonSchema or the validator of the collection
+type DocumentValidationError struct {
+	// position of the write within InsertMany or BulkWrite,-1 if the operation has only one write
+	WriteIndex int
+	// _id of the failing document
+	DocumentID interface{}
+	// the unsatisfied rules reported by the server since 5.0,such as
+	// {operatorName: "$jsonSchema", schemaRulesNotSatisfied: [...]}
+	Details bson.M
+	Message string
+}
+
+func (e *DocumentValidationError) Error() string {
+	return e.Message
+}
+
+func (e *DocumentValidationError) Unwrap() error {
+	return ErrDocumentValidation
+}
+
+// errInfo is {failingDocumentId: ..., details: {...}}
+func newDocumentValidationError(writeIndex int, message string, errInfo bson.M) *DocumentValidationError {
+	e := &DocumentValidationError{
+		WriteIndex: writeIndex,
+		Message:    message,
+	}
+	if errInfo == nil {
+		return e
+	}
+	e.DocumentID = errInfo["failingDocumentId"]
+	if details, ok := errInfo["details"].(bson.M); ok {
+		e.Details = details
+	}
+	return e
+}
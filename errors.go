@@ -1,8 +1,99 @@
 package mongodbr
 
-import "errors"
+import (
+	"errors"
+	"strings"
+)
 
 var (
 	ErrInvalidType = errors.New("invalid type")
 	ErrNoCursor    = errors.New("no cursor")
 )
+
+// stable code of the errors created by mongodbr,it never changes with the message
+type ErrorCode string
+
+const (
+	ErrorCodeNilClient               ErrorCode = "MONGODBR_NIL_CLIENT"
+	ErrorCodeConnectFailed           ErrorCode = "MONGODBR_CONNECT_FAILED"
+	ErrorCodePingFailed              ErrorCode = "MONGODBR_PING_FAILED"
+	ErrorCodeNilCollectionGetter     ErrorCode = "MONGODBR_NIL_COLLECTION_GETTER"
+	ErrorCodeNilCollection           ErrorCode = "MONGODBR_NIL_COLLECTION"
+	ErrorCodeEmptyCollectionName     ErrorCode = "MONGODBR_EMPTY_COLLECTION_NAME"
+	ErrorCodeEmptyDatabaseName       ErrorCode = "MONGODBR_EMPTY_DATABASE_NAME"
+	ErrorCodeClientNotRegistered     ErrorCode = "MONGODBR_CLIENT_NOT_REGISTERED"
+	ErrorCodeReadClientNotRegistered ErrorCode = "MONGODBR_READ_CLIENT_NOT_REGISTERED"
+	ErrorCodeNilFilter               ErrorCode = "MONGODBR_NIL_FILTER"
+	ErrorCodeNilItem                 ErrorCode = "MONGODBR_NIL_ITEM"
+)
+
+// match them with errors.Is,such as errors.Is(err, ErrNilFilter)
+var (
+	ErrNilClient               = &Error{Code: ErrorCodeNilClient}
+	ErrConnectFailed           = &Error{Code: ErrorCodeConnectFailed}
+	ErrPingFailed              = &Error{Code: ErrorCodePingFailed}
+	ErrNilCollectionGetter     = &Error{Code: ErrorCodeNilCollectionGetter}
+	ErrNilCollection           = &Error{Code: ErrorCodeNilCollection}
+	ErrEmptyCollectionName     = &Error{Code: ErrorCodeEmptyCollectionName}
+	ErrEmptyDatabaseName       = &Error{Code: ErrorCodeEmptyDatabaseName}
+	ErrReadClientNotRegistered = &Error{Code: ErrorCodeReadClientNotRegistered}
+	ErrNilFilter               = &Error{Code: ErrorCodeNilFilter}
+	ErrNilItem                 = &Error{Code: ErrorCodeNilItem}
+)
+
+// Error is an error created by mongodbr,its message comes from the message catalog
+type Error struct {
+	Code ErrorCode
+	// values of the placeholders of the message,such as {"collection": "users"}
+	Params map[string]string
+	// the cause,such as the error of the driver
+	Err error
+}
+
+func newError(code ErrorCode, cause error, keyValues ...string) *Error {
+	e := &Error{Code: code, Err: cause}
+	if len(keyValues) > 0 {
+		e.Params = make(map[string]string, len(keyValues)/2)
+		for i := 0; i+1 < len(keyValues); i += 2 {
+			e.Params[keyValues[i]] = keyValues[i+1]
+		}
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	message := e.Message()
+	if e.Err == nil {
+		return message
+	}
+	return message + ": " + e.Err.Error()
+}
+
+// the message of the code from the message catalog without the cause
+func (e *Error) Message() string {
+	template, ok := getMessageCatalog().Message(e.Code)
+	if !ok {
+		template, ok = DefaultMessageCatalog.Message(e.Code)
+	}
+	if !ok {
+		return string(e.Code)
+	}
+	if len(e.Params) <= 0 {
+		return template
+	}
+	oldNewList := make([]string, 0, 2*len(e.Params))
+	for eachKey, eachValue := range e.Params {
+		oldNewList = append(oldNewList, "{"+eachKey+"}", eachValue)
+	}
+	return strings.NewReplacer(oldNewList...).Replace(template)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// errors with the same code are the same
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
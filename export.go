@@ -0,0 +1,251 @@
+package mongodbr
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type DataFormat string
+
+const (
+	// one extended json document per line
+	DataFormatJSONL DataFormat = "jsonl"
+	// comma separated values with a header row
+	DataFormatCSV DataFormat = "csv"
+)
+
+// a csv column mapped to the dotted path of the document,such as {Header: "City", Path: "address.city"}
+type CSVColumn struct {
+	Header string
+	Path   string
+	// convert the cell on import,such as CSVParseInt.the cell is imported as a string if nil,
+	// the empty cells are skipped
+	Parse func(cell string) (interface{}, error)
+}
+
+type exportOptions struct {
+	format      DataFormat
+	canonical   bool
+	columnList  []CSVColumn
+	findOptions []FindOption
+}
+
+type ExportOption func(*exportOptions)
+
+// default is DataFormatJSONL
+func ExportWithFormat(format DataFormat) ExportOption {
+	return func(o *exportOptions) {
+		o.format = format
+	}
+}
+
+// write canonical extended json which keeps the bson types,default is relaxed extended json
+func ExportWithCanonical() ExportOption {
+	return func(o *exportOptions) {
+		o.canonical = true
+	}
+}
+
+// the csv columns,default is the top level fields of the first document
+func ExportWithColumns(columnList ...CSVColumn) ExportOption {
+	return func(o *exportOptions) {
+		o.columnList = append(o.columnList, columnList...)
+	}
+}
+
+// sort,limit or project the documents exported by Export
+func ExportWithFindOptions(opts ...FindOption) ExportOption {
+	return func(o *exportOptions) {
+		o.findOptions = append(o.findOptions, opts...)
+	}
+}
+
+// write the documents matching filter to w,return the number of the written documents.
+// ctx bounds the whole export,such as the context of a http request
+func (r *MongoCol) Export(ctx context.Context, w io.Writer, filter interface{}, opts ...ExportOption) (int64, error) {
+	o := newExportOptions(opts)
+	result := r.WithContext(ctx).FindByFilter(filter, o.findOptions...)
+	if err := result.GetError(); err != nil {
+		return 0, err
+	}
+	return exportCursor(ctx, result.GetCursor(), w, o)
+}
+
+// write the results of the aggregation pipeline to w
+func (r *MongoCol) ExportPipeline(ctx context.Context, w io.Writer, pipeline interface{}, opts ...ExportOption) (int64, error) {
+	o := newExportOptions(opts)
+	var cur *mongo.Cursor
+	err := r.WithContext(ctx).executeWithIdempotency("aggregate", pipeline, func() bool { return isIdempotentPipeline(pipeline) }, func(op *operation) (err error) {
+		cur, err = op.collection.Aggregate(op.ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return exportCursor(ctx, cur, w, o)
+}
+
+func newExportOptions(opts []ExportOption) *exportOptions {
+	o := &exportOptions{
+		format: DataFormatJSONL,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	return o
+}
+
+func exportCursor(ctx context.Context, cur *mongo.Cursor, w io.Writer, o *exportOptions) (count int64, err error) {
+	if cur == nil {
+		return 0, ErrNoCursor
+	}
+	defer cur.Close(ctx)
+
+	switch o.format {
+	case DataFormatJSONL:
+		count, err = exportJSONL(ctx, cur, w, o.canonical)
+	case DataFormatCSV:
+		count, err = exportCSV(ctx, cur, w, o.columnList)
+	default:
+		return 0, fmt.Errorf("unsupported export format %q", o.format)
+	}
+	if err != nil {
+		return count, err
+	}
+	return count, cur.Err()
+}
+
+func exportJSONL(ctx context.Context, cur *mongo.Cursor, w io.Writer, canonical bool) (int64, error) {
+	writer := bufio.NewWriter(w)
+	count := int64(0)
+	for cur.Next(ctx) {
+		line, err := bson.MarshalExtJSON(cur.Current, canonical, false)
+		if err != nil {
+			return count, err
+		}
+		if _, err := writer.Write(line); err != nil {
+			return count, err
+		}
+		if err := writer.WriteByte('\n'); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, writer.Flush()
+}
+
+func exportCSV(ctx context.Context, cur *mongo.Cursor, w io.Writer, columnList []CSVColumn) (int64, error) {
+	writer := csv.NewWriter(w)
+	count := int64(0)
+	for cur.Next(ctx) {
+		if count == 0 {
+			if len(columnList) <= 0 {
+				columnList = csvColumnsOf(cur.Current)
+			}
+			headerList := make([]string, 0, len(columnList))
+			for _, eachColumn := range columnList {
+				headerList = append(headerList, eachColumn.Header)
+			}
+			if err := writer.Write(headerList); err != nil {
+				return count, err
+			}
+		}
+		row := make([]string, 0, len(columnList))
+		for _, eachColumn := range columnList {
+			value, err := cur.Current.LookupErr(strings.Split(eachColumn.Path, ".")...)
+			if err != nil {
+				row = append(row, "")
+				continue
+			}
+			row = append(row, formatCSVCell(value))
+		}
+		if err := writer.Write(row); err != nil {
+			return count, err
+		}
+		count++
+	}
+	writer.Flush()
+	return count, writer.Error()
+}
+
+// a column for each top level field
+func csvColumnsOf(document bson.Raw) []CSVColumn {
+	elementList, err := document.Elements()
+	if err != nil {
+		return nil
+	}
+	columnList := make([]CSVColumn, 0, len(elementList))
+	for _, eachElement := range elementList {
+		columnList = append(columnList, CSVColumn{Header: eachElement.Key(), Path: eachElement.Key()})
+	}
+	return columnList
+}
+
+// plain text of the scalar values,relaxed extended json of the documents and arrays
+func formatCSVCell(value bson.RawValue) string {
+	switch value.Type {
+	case bsontype.Null, bsontype.Undefined:
+		return ""
+	case bsontype.String:
+		return value.StringValue()
+	case bsontype.ObjectID:
+		return value.ObjectID().Hex()
+	case bsontype.Boolean:
+		return strconv.FormatBool(value.Boolean())
+	case bsontype.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10)
+	case bsontype.Int64:
+		return strconv.FormatInt(value.Int64(), 10)
+	case bsontype.Double:
+		return strconv.FormatFloat(value.Double(), 'f', -1, 64)
+	case bsontype.Decimal128:
+		return value.Decimal128().String()
+	case bsontype.DateTime:
+		return value.Time().UTC().Format(time.RFC3339Nano)
+	}
+	data, err := bson.MarshalExtJSON(bson.D{{Key: "v", Value: value}}, false, false)
+	if err != nil {
+		return value.String()
+	}
+	//strip {"v": and }
+	text := strings.TrimSpace(string(data))
+	text = strings.TrimPrefix(text, `{"v":`)
+	return strings.TrimSuffix(text, "}")
+}
+
+// #region csv parsers
+
+func CSVParseInt(cell string) (interface{}, error) {
+	return strconv.ParseInt(strings.TrimSpace(cell), 10, 64)
+}
+
+func CSVParseFloat(cell string) (interface{}, error) {
+	return strconv.ParseFloat(strings.TrimSpace(cell), 64)
+}
+
+func CSVParseBool(cell string) (interface{}, error) {
+	return strconv.ParseBool(strings.TrimSpace(cell))
+}
+
+// RFC3339 time,such as the exported dates
+func CSVParseTime(cell string) (interface{}, error) {
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(cell))
+}
+
+func CSVParseObjectId(cell string) (interface{}, error) {
+	return primitive.ObjectIDFromHex(strings.TrimSpace(cell))
+}
+
+// #endregion
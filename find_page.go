@@ -0,0 +1,222 @@
+package mongodbr
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// page size used when it is not positive,default is 20
+	DefaultPageSize int64 = 20
+)
+
+// a page of the documents matching a filter
+type PageResult struct {
+	// created by the createItemFunc of the repository
+	Items []interface{} `json:"items"`
+	// number of the matching documents,-1 if PageWithoutCount is set
+	Total     int64 `json:"total"`
+	PageIndex int64 `json:"pageIndex"`
+	PageSize  int64 `json:"pageSize"`
+	// -1 if PageWithoutCount is set
+	TotalPages int64 `json:"totalPages"`
+	HasNext    bool  `json:"hasNext"`
+
+	rawList       []bson.Raw
+	configuration *Configuration
+	collection    *mongo.Collection
+}
+
+// decode the items into the slice pointed by val,such as *[]Product
+func (p *PageResult) Decode(val interface{}) error {
+	if len(p.rawList) <= 0 {
+		return nil
+	}
+	return p.findResult().All(val)
+}
+
+// findResult over the raw items,it decodes them as FindByFilter does
+func (p *PageResult) findResult() *findResult {
+	documentList := make([]interface{}, 0, len(p.rawList))
+	for _, eachRaw := range p.rawList {
+		documentList = append(documentList, eachRaw)
+	}
+	cur, err := mongo.NewCursorFromDocuments(documentList, nil, nil)
+	return &findResult{
+		configuration: p.configuration,
+		collection:    p.collection,
+		cur:           cur,
+		err:           err,
+	}
+}
+
+type pageOptions struct {
+	sort           interface{}
+	projection     interface{}
+	withoutCount   bool
+	estimatedCount bool
+}
+
+type PageOption func(*pageOptions)
+
+// default is the default sort of the repository
+func PageWithSort(sort bson.D) PageOption {
+	return func(o *pageOptions) {
+		if len(sort) > 0 {
+			o.sort = sort
+		}
+	}
+}
+
+func PageWithProjection(projection interface{}) PageOption {
+	return func(o *pageOptions) {
+		o.projection = projection
+	}
+}
+
+// do not count the matching documents,HasNext is still set by reading one more document
+func PageWithoutCount() PageOption {
+	return func(o *pageOptions) {
+		o.withoutCount = true
+	}
+}
+
+// use the estimated number of the documents of the collection as Total instead of counting the
+// matching documents,it ignores the filter and is meant for the huge collections listed without one
+func PageWithEstimatedCount() PageOption {
+	return func(o *pageOptions) {
+		o.estimatedCount = true
+	}
+}
+
+// find the page of the documents matching filter and count them in one $facet aggregation,
+// pageIndex starts from 1.
+// the page is returned in one document of the aggregation,so it must be less than 16MB
+func (r *MongoCol) FindPage(filter interface{}, pageIndex int64, pageSize int64, opts ...PageOption) (*PageResult, error) {
+	o := &pageOptions{}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	if o.sort == nil && r.configuration.setDefaultSort != nil {
+		findOptions := options.Find()
+		r.configuration.setDefaultSort(findOptions)
+		o.sort = findOptions.Sort
+	}
+	if pageIndex < 1 {
+		pageIndex = 1
+	}
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	if filter == nil {
+		filter = bson.D{}
+	}
+	r.recordQueryShape("find", filter, o.sort)
+
+	result := &PageResult{
+		Total:         -1,
+		PageIndex:     pageIndex,
+		PageSize:      pageSize,
+		TotalPages:    -1,
+		configuration: r.configuration,
+		collection:    r.collection,
+	}
+	countInFacet := o.countInFacet()
+	pipeline := o.pipeline(filter, pageIndex, pageSize)
+
+	//the pipeline only reads
+	err := r.executeWithIdempotency("aggregate", filter, func() bool { return true }, func(op *operation) error {
+		cur, err := op.collection.Aggregate(op.ctx, pipeline)
+		if err != nil {
+			return err
+		}
+		defer cur.Close(op.ctx)
+		if countInFacet {
+			facet := struct {
+				Items []bson.Raw `bson:"items"`
+				Total []struct {
+					Count int64 `bson:"count"`
+				} `bson:"total"`
+			}{}
+			if cur.Next(op.ctx) {
+				if err := cur.Decode(&facet); err != nil {
+					return err
+				}
+			}
+			result.rawList = facet.Items
+			result.Total = 0
+			if len(facet.Total) > 0 {
+				result.Total = facet.Total[0].Count
+			}
+		} else {
+			for cur.Next(op.ctx) {
+				result.rawList = append(result.rawList, append(bson.Raw(nil), cur.Current...))
+			}
+		}
+		op.returned = int64(len(result.rawList))
+		return cur.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if countInFacet {
+		result.HasNext = pageIndex*pageSize < result.Total
+	} else if int64(len(result.rawList)) > pageSize {
+		result.HasNext = true
+		result.rawList = result.rawList[:pageSize]
+	}
+	if o.estimatedCount {
+		if result.Total, err = r.CountAll(); err != nil {
+			return nil, err
+		}
+	}
+	if result.Total >= 0 {
+		result.TotalPages = (result.Total + pageSize - 1) / pageSize
+	}
+	if len(result.rawList) > 0 {
+		if result.Items, err = result.findResult().ToAll(); err != nil {
+			return nil, err
+		}
+	}
+	if result.Items == nil {
+		result.Items = make([]interface{}, 0)
+	}
+	return result, nil
+}
+
+// count the documents by $facet in the same aggregation
+func (o *pageOptions) countInFacet() bool {
+	return !o.withoutCount && !o.estimatedCount
+}
+
+// $match,$sort and $facet of the page and the count,or the page with one more document if the
+// documents are not counted.
+// $sort is before $facet so that it can use an index,the sub pipelines of $facet cannot
+func (o *pageOptions) pipeline(filter interface{}, pageIndex int64, pageSize int64) mongo.Pipeline {
+	countInFacet := o.countInFacet()
+	limit := pageSize
+	if !countInFacet {
+		//one more document tells whether there is a next page
+		limit++
+	}
+	pipeline := mongo.Pipeline{bson.D{{Key: "$match", Value: filter}}}
+	if o.sort != nil {
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: o.sort}})
+	}
+	itemStageList := mongo.Pipeline{
+		bson.D{{Key: "$skip", Value: pageSize * (pageIndex - 1)}},
+		bson.D{{Key: "$limit", Value: limit}},
+	}
+	if o.projection != nil {
+		itemStageList = append(itemStageList, bson.D{{Key: "$project", Value: o.projection}})
+	}
+	if !countInFacet {
+		return append(pipeline, itemStageList...)
+	}
+	return append(pipeline, bson.D{{Key: "$facet", Value: bson.D{
+		{Key: "items", Value: itemStageList},
+		{Key: "total", Value: bson.A{bson.D{{Key: "$count", Value: "count"}}}},
+	}}})
+}
@@ -0,0 +1,51 @@
+package mongodbr
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestPageOptionsPipeline(t *testing.T) {
+	sort := bson.D{{Key: "name", Value: 1}}
+	testCases := []struct {
+		name      string
+		opts      []PageOption
+		stageList []string
+		limit     int64
+	}{
+		{"count", []PageOption{PageWithSort(sort)}, []string{"$match", "$sort", "$facet"}, 10},
+		{"count without sort", nil, []string{"$match", "$facet"}, 10},
+		{"without count", []PageOption{PageWithSort(sort), PageWithoutCount()}, []string{"$match", "$sort", "$skip", "$limit"}, 11},
+		{"estimated count", []PageOption{PageWithSort(sort), PageWithEstimatedCount()}, []string{"$match", "$sort", "$skip", "$limit"}, 11},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			o := &pageOptions{}
+			for _, eachOpt := range eachCase.opts {
+				eachOpt(o)
+			}
+			pipeline := o.pipeline(bson.D{}, 3, 10)
+			if len(pipeline) != len(eachCase.stageList) {
+				t.Fatalf("expect %v,got %v", eachCase.stageList, pipeline)
+			}
+			for i, eachStage := range pipeline {
+				if eachStage[0].Key != eachCase.stageList[i] {
+					t.Fatalf("expect %v,got %v", eachCase.stageList, pipeline)
+				}
+			}
+			itemStageList := []bson.D(pipeline[len(pipeline)-2:])
+			if o.countInFacet() {
+				facet := pipeline[len(pipeline)-1][0].Value.(bson.D)
+				itemStageList = facet[0].Value.(mongo.Pipeline)
+			}
+			if skip := itemStageList[0]; skip[0].Key != "$skip" || skip[0].Value != int64(20) {
+				t.Errorf("expect $skip 20,got %v", skip)
+			}
+			if limit := itemStageList[1]; limit[0].Key != "$limit" || limit[0].Value != eachCase.limit {
+				t.Errorf("expect $limit %d,got %v", eachCase.limit, limit)
+			}
+		})
+	}
+}
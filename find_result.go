@@ -4,6 +4,8 @@ import (
 	"errors"
 
 	"go.mongodb.org/mongo-driver/mongo"
+
+	mongodbrerr "github.com/shanluzhineng/mongodbr/err"
 )
 
 type IFindResult interface {
@@ -22,6 +24,7 @@ type findResult struct {
 	cur           *mongo.Cursor
 	err           error
 	configuration *Configuration
+	collection    *mongo.Collection
 }
 
 // #IFindResult members
@@ -31,7 +34,14 @@ func (r *findResult) One(val interface{}) (err error) {
 		return r.err
 	}
 	if r.cur == nil {
-		return r.res.Decode(val)
+		if r.configuration.schemaUpgrader == nil {
+			return mongodbrerr.Wrap(r.res.Decode(val))
+		}
+		raw, err := r.res.DecodeBytes()
+		if err != nil {
+			return err
+		}
+		return r.decodeRaw(raw, val)
 	}
 
 	//没有设置参数，使用默认的
@@ -39,9 +49,9 @@ func (r *findResult) One(val interface{}) (err error) {
 	defer cancel()
 
 	if !r.cur.TryNext(ctx) {
-		return mongo.ErrNoDocuments
+		return mongodbrerr.Wrap(mongo.ErrNoDocuments)
 	}
-	return r.cur.Decode(val)
+	return r.decodeCursor(val)
 }
 
 func (r *findResult) ToOne() (interface{}, error) {
@@ -63,6 +73,9 @@ func (r *findResult) All(val interface{}) (err error) {
 	if r.cur == nil {
 		return ErrNoCursor
 	}
+	if r.configuration.schemaUpgrader != nil {
+		return r.decodeAllUpgraded(ctx, val)
+	}
 	if !r.cur.TryNext(ctx) {
 		return ctx.Err()
 	}
@@ -84,7 +97,7 @@ func (r *findResult) ToAll() ([]interface{}, error) {
 	var result []interface{}
 	for r.cur.Next(ctx) {
 		o := r.configuration.safeCreateItem()
-		if err := r.cur.Decode(o); err != nil {
+		if err := r.decodeCursor(o); err != nil {
 			return nil, err
 		}
 		result = append(result, o)
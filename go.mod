@@ -1,8 +1,11 @@
 module github.com/shanluzhineng/mongodbr
 
-go 1.20
+go 1.21
 
-require go.mongodb.org/mongo-driver v1.11.0
+require (
+	go.mongodb.org/mongo-driver v1.11.0
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/golang/snappy v0.0.4 // indirect
@@ -0,0 +1,399 @@
+package mongodbr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type HealthState string
+
+const (
+	HealthStateUnknown HealthState = "unknown"
+	// the primary is reachable and the replication lag is below the threshold
+	HealthStateUp HealthState = "up"
+	// the primary is reachable but the replication lag exceeds the threshold or a member is unhealthy
+	HealthStateDegraded HealthState = "degraded"
+	// the database or its primary cannot be reached
+	HealthStateDown HealthState = "down"
+
+	TopologyStandalone = "standalone"
+	TopologyReplicaSet = "replicaSet"
+	TopologySharded    = "sharded"
+
+	// replSetGetStatus is not supported by standalone servers and mongos
+	errorCodeNoReplicationEnabled = 76
+	errorCodeCommandNotFound      = 59
+)
+
+// state of a replica set member
+type MemberHealth struct {
+	Name    string `json:"name"`
+	State   string `json:"state"`
+	Healthy bool   `json:"healthy"`
+	// how far the member is behind the primary,only for secondaries
+	ReplicationLag time.Duration `json:"replicationLag,omitempty"`
+}
+
+// health of a client
+type ClientHealth struct {
+	Key               string         `json:"key"`
+	State             HealthState    `json:"state"`
+	Topology          string         `json:"topology,omitempty"`
+	SetName           string         `json:"setName,omitempty"`
+	PrimaryReachable  bool           `json:"primaryReachable"`
+	PingLatency       time.Duration  `json:"pingLatency"`
+	MaxReplicationLag time.Duration  `json:"maxReplicationLag,omitempty"`
+	Members           []MemberHealth `json:"members,omitempty"`
+	Error             string         `json:"error,omitempty"`
+	CheckedAt         time.Time      `json:"checkedAt"`
+}
+
+type HealthReport struct {
+	State     HealthState    `json:"state"`
+	Clients   []ClientHealth `json:"clients"`
+	CheckedAt time.Time      `json:"checkedAt"`
+}
+
+// the state of a client changed between two checks
+type HealthStateChange struct {
+	Key    string
+	From   HealthState
+	To     HealthState
+	Health ClientHealth
+}
+
+type healthCheckerOptions struct {
+	interval          time.Duration
+	timeout           time.Duration
+	maxReplicationLag time.Duration
+	onStateChange     []func(HealthStateChange)
+}
+
+type HealthCheckerOption func(*healthCheckerOptions)
+
+// interval between two checks,default is 10s
+func HealthCheckerWithInterval(d time.Duration) HealthCheckerOption {
+	return func(o *healthCheckerOptions) {
+		if d > 0 {
+			o.interval = d
+		}
+	}
+}
+
+// timeout of checking a client,default is 2s
+func HealthCheckerWithTimeout(d time.Duration) HealthCheckerOption {
+	return func(o *healthCheckerOptions) {
+		if d > 0 {
+			o.timeout = d
+		}
+	}
+}
+
+// the client is degraded when a secondary is behind the primary more than d,default is 30s
+func HealthCheckerWithMaxReplicationLag(d time.Duration) HealthCheckerOption {
+	return func(o *healthCheckerOptions) {
+		if d > 0 {
+			o.maxReplicationLag = d
+		}
+	}
+}
+
+// call fn when the state of a client changes,it is called in the goroutine of the checker
+func HealthCheckerWithStateChange(fn func(HealthStateChange)) HealthCheckerOption {
+	return func(o *healthCheckerOptions) {
+		if fn != nil {
+			o.onStateChange = append(o.onStateChange, fn)
+		}
+	}
+}
+
+// HealthChecker periodically checks DefaultClient and the clients of DefaultClientRegistry,the
+// clients registered but not used yet are connected by the check
+type HealthChecker struct {
+	options *healthCheckerOptions
+	//keys of the checked clients and the check of a client,replaced by the tests
+	clientKeys  func() []string
+	checkClient func(ctx context.Context, key string) ClientHealth
+
+	mu     sync.RWMutex
+	report *HealthReport
+	states map[string]HealthState
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewHealthChecker(opts ...HealthCheckerOption) *HealthChecker {
+	o := &healthCheckerOptions{
+		interval:          10 * time.Second,
+		timeout:           2 * time.Second,
+		maxReplicationLag: 30 * time.Second,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	h := &HealthChecker{
+		options:    o,
+		clientKeys: registeredClientKeys,
+		states:     make(map[string]HealthState),
+	}
+	h.checkClient = h.checkRegisteredClient
+	return h
+}
+
+// check the clients in the background until Stop is called
+func (h *HealthChecker) Start() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+	h.done = make(chan struct{})
+	go h.run(ctx, h.done)
+}
+
+// stop the background checks and wait for the running check
+func (h *HealthChecker) Stop() {
+	h.mu.Lock()
+	cancel, done := h.cancel, h.done
+	h.cancel, h.done = nil, nil
+	h.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+func (h *HealthChecker) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(h.options.interval)
+	defer ticker.Stop()
+	for {
+		h.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check every client now and return the report
+func (h *HealthChecker) Check(ctx context.Context) *HealthReport {
+	keyList := h.clientKeys()
+	healthList := make([]ClientHealth, len(keyList))
+	wg := sync.WaitGroup{}
+	for i, eachKey := range keyList {
+		wg.Add(1)
+		go func(i int, key string) {
+			defer wg.Done()
+			healthList[i] = h.checkClient(ctx, key)
+		}(i, eachKey)
+	}
+	wg.Wait()
+
+	report := &HealthReport{
+		State:     HealthStateUp,
+		Clients:   healthList,
+		CheckedAt: time.Now(),
+	}
+	for _, eachHealth := range healthList {
+		if eachHealth.State == HealthStateDown {
+			report.State = HealthStateDown
+			break
+		}
+		if eachHealth.State == HealthStateDegraded {
+			report.State = HealthStateDegraded
+		}
+	}
+
+	changeList := make([]HealthStateChange, 0)
+	h.mu.Lock()
+	h.report = report
+	for _, eachHealth := range healthList {
+		from, ok := h.states[eachHealth.Key]
+		if !ok {
+			from = HealthStateUnknown
+		}
+		if from != eachHealth.State {
+			changeList = append(changeList, HealthStateChange{
+				Key:    eachHealth.Key,
+				From:   from,
+				To:     eachHealth.State,
+				Health: eachHealth,
+			})
+		}
+		h.states[eachHealth.Key] = eachHealth.State
+	}
+	h.mu.Unlock()
+
+	for _, eachChange := range changeList {
+		for _, eachFn := range h.options.onStateChange {
+			eachFn(eachChange)
+		}
+	}
+	return report
+}
+
+// the report of the last check,nil if not checked yet
+func (h *HealthChecker) Report() *HealthReport {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.report
+}
+
+// the client of key is down if it cannot be connected
+func (h *HealthChecker) checkRegisteredClient(ctx context.Context, key string) ClientHealth {
+	ctx, cancel := context.WithTimeout(ctx, h.options.timeout)
+	defer cancel()
+
+	health := ClientHealth{
+		Key:       key,
+		State:     HealthStateDown,
+		CheckedAt: time.Now(),
+	}
+	client, err := registeredClient(key)
+	if err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	startTime := time.Now()
+	if err := client.Ping(ctx, readpref.Nearest()); err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.PingLatency = time.Since(startTime)
+
+	admin := client.Database("admin")
+	hello := bson.M{}
+	if err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}},
+		options.RunCmd().SetReadPreference(readpref.Nearest())).Decode(&hello); err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.Topology = TopologyStandalone
+	if hello["msg"] == "isdbgrid" {
+		health.Topology = TopologySharded
+	} else if setName, ok := hello["setName"].(string); ok {
+		health.Topology = TopologyReplicaSet
+		health.SetName = setName
+	}
+
+	if err := client.Ping(ctx, readpref.Primary()); err != nil {
+		health.Error = err.Error()
+		return health
+	}
+	health.PrimaryReachable = true
+	health.State = HealthStateUp
+	if health.Topology != TopologyReplicaSet {
+		return health
+	}
+
+	members, err := replicaSetMembers(ctx, admin)
+	if err != nil {
+		//the status needs the clusterMonitor role,the members are not reported without it
+		health.Error = err.Error()
+		return health
+	}
+	health.Members = members
+	for _, eachMember := range members {
+		if eachMember.ReplicationLag > health.MaxReplicationLag {
+			health.MaxReplicationLag = eachMember.ReplicationLag
+		}
+		if !eachMember.Healthy {
+			health.State = HealthStateDegraded
+		}
+	}
+	if health.MaxReplicationLag > h.options.maxReplicationLag {
+		health.State = HealthStateDegraded
+	}
+	return health
+}
+
+// state and replication lag of the members from replSetGetStatus
+func replicaSetMembers(ctx context.Context, admin *mongo.Database) ([]MemberHealth, error) {
+	status := struct {
+		Members []struct {
+			Name       string    `bson:"name"`
+			Health     float64   `bson:"health"`
+			StateStr   string    `bson:"stateStr"`
+			OptimeDate time.Time `bson:"optimeDate"`
+		} `bson:"members"`
+	}{}
+	err := admin.RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&status)
+	if err != nil {
+		var commandErr mongo.CommandError
+		if errors.As(err, &commandErr) && (commandErr.Code == errorCodeNoReplicationEnabled || commandErr.Code == errorCodeCommandNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var primaryOptime time.Time
+	for _, eachMember := range status.Members {
+		if eachMember.StateStr == "PRIMARY" {
+			primaryOptime = eachMember.OptimeDate
+		}
+	}
+	members := make([]MemberHealth, 0, len(status.Members))
+	for _, eachMember := range status.Members {
+		member := MemberHealth{
+			Name:    eachMember.Name,
+			State:   eachMember.StateStr,
+			Healthy: eachMember.Health > 0,
+		}
+		if eachMember.StateStr == "SECONDARY" && !primaryOptime.IsZero() && primaryOptime.After(eachMember.OptimeDate) {
+			member.ReplicationLag = primaryOptime.Sub(eachMember.OptimeDate)
+		}
+		members = append(members, member)
+	}
+	return members, nil
+}
+
+// #region handlers
+
+// http.Handler reporting whether the checker is running,the status is 503 if the last check is
+// older than three intervals
+func (h *HealthChecker) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		report := h.Report()
+		alive := report != nil && time.Since(report.CheckedAt) <= 3*h.options.interval
+		writeHealthReport(w, report, alive)
+	})
+}
+
+// http.Handler reporting whether every client can serve requests,the status is 503 if a client
+// is down or no check has been done
+func (h *HealthChecker) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		report := h.Report()
+		ready := report != nil && report.State != HealthStateDown
+		writeHealthReport(w, report, ready)
+	})
+}
+
+func writeHealthReport(w http.ResponseWriter, report *HealthReport, ok bool) {
+	if report == nil {
+		report = &HealthReport{State: HealthStateUnknown, Clients: []ClientHealth{}}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if ok {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// #endregion
@@ -0,0 +1,134 @@
+package mongodbr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// checker of the clients with the states in states
+func newTestHealthChecker(states map[string]HealthState, opts ...HealthCheckerOption) *HealthChecker {
+	h := NewHealthChecker(opts...)
+	h.clientKeys = func() []string {
+		keyList := make([]string, 0, len(states))
+		for eachKey := range states {
+			keyList = append(keyList, eachKey)
+		}
+		return keyList
+	}
+	h.checkClient = func(_ context.Context, key string) ClientHealth {
+		return ClientHealth{Key: key, State: states[key], CheckedAt: time.Now()}
+	}
+	return h
+}
+
+func TestHealthCheckerCheckState(t *testing.T) {
+	testCases := []struct {
+		name   string
+		states map[string]HealthState
+		expect HealthState
+	}{
+		{"no client", map[string]HealthState{}, HealthStateUp},
+		{"up", map[string]HealthState{"a": HealthStateUp, "b": HealthStateUp}, HealthStateUp},
+		{"degraded", map[string]HealthState{"a": HealthStateUp, "b": HealthStateDegraded}, HealthStateDegraded},
+		{"down", map[string]HealthState{"a": HealthStateDegraded, "b": HealthStateDown}, HealthStateDown},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			report := newTestHealthChecker(eachCase.states).Check(context.Background())
+			if report.State != eachCase.expect {
+				t.Fatalf("expect %s,got %s", eachCase.expect, report.State)
+			}
+			if len(report.Clients) != len(eachCase.states) {
+				t.Fatalf("expect %d clients,got %d", len(eachCase.states), len(report.Clients))
+			}
+		})
+	}
+}
+
+func TestHealthCheckerStateChange(t *testing.T) {
+	states := map[string]HealthState{"a": HealthStateUp}
+	changeList := make([]HealthStateChange, 0)
+	h := newTestHealthChecker(states, HealthCheckerWithStateChange(func(change HealthStateChange) {
+		change.Health = ClientHealth{}
+		changeList = append(changeList, change)
+	}))
+	ctx := context.Background()
+
+	h.Check(ctx)
+	h.Check(ctx)
+	states["a"] = HealthStateDown
+	h.Check(ctx)
+
+	expect := []HealthStateChange{
+		{Key: "a", From: HealthStateUnknown, To: HealthStateUp},
+		{Key: "a", From: HealthStateUp, To: HealthStateDown},
+	}
+	if !reflect.DeepEqual(changeList, expect) {
+		t.Fatalf("expect %v,got %v", expect, changeList)
+	}
+}
+
+func TestHealthCheckerHandlers(t *testing.T) {
+	testCases := []struct {
+		name      string
+		states    map[string]HealthState
+		check     bool
+		checkedAt time.Time
+		liveness  int
+		readiness int
+	}{
+		{"not checked", map[string]HealthState{"a": HealthStateUp}, false, time.Time{}, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+		{"up", map[string]HealthState{"a": HealthStateUp}, true, time.Time{}, http.StatusOK, http.StatusOK},
+		{"degraded", map[string]HealthState{"a": HealthStateDegraded}, true, time.Time{}, http.StatusOK, http.StatusOK},
+		{"down", map[string]HealthState{"a": HealthStateDown}, true, time.Time{}, http.StatusOK, http.StatusServiceUnavailable},
+		{"stale check", map[string]HealthState{"a": HealthStateUp}, true, time.Now().Add(-time.Hour), http.StatusServiceUnavailable, http.StatusOK},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			h := newTestHealthChecker(eachCase.states)
+			if eachCase.check {
+				report := h.Check(context.Background())
+				if !eachCase.checkedAt.IsZero() {
+					report.CheckedAt = eachCase.checkedAt
+				}
+			}
+			for _, each := range []struct {
+				handler http.Handler
+				expect  int
+			}{
+				{h.LivenessHandler(), eachCase.liveness},
+				{h.ReadinessHandler(), eachCase.readiness},
+			} {
+				recorder := httptest.NewRecorder()
+				each.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+				if recorder.Code != each.expect {
+					t.Errorf("expect status %d,got %d", each.expect, recorder.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestHealthCheckerRegisteredClientNotConnected(t *testing.T) {
+	ctx := context.Background()
+	if err := DefaultClientRegistry.Register("health-test", "mongodb://localhost:1"); err != nil {
+		t.Fatal(err)
+	}
+	defer DefaultClientRegistry.Remove(ctx, "health-test")
+
+	report := NewHealthChecker(HealthCheckerWithTimeout(100 * time.Millisecond)).Check(ctx)
+	for _, eachHealth := range report.Clients {
+		if eachHealth.Key != "health-test" {
+			continue
+		}
+		if eachHealth.State != HealthStateDown || len(eachHealth.Error) <= 0 {
+			t.Fatalf("expect the unreachable client down,got %s %s", eachHealth.State, eachHealth.Error)
+		}
+		return
+	}
+	t.Fatal("the registered client is not checked")
+}
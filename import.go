@@ -0,0 +1,282 @@
+package mongodbr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrImportFailed = errors.New("import failed")
+
+// max size of a bson document
+const maxImportLineSize = 16 * 1024 * 1024
+
+// a line which cannot be parsed or written
+type ImportLineError struct {
+	// line number starting from 1,the header of a csv is line 1
+	Line int64
+	Err  error
+}
+
+func (e *ImportLineError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err.Error())
+}
+
+func (e *ImportLineError) Unwrap() error {
+	return e.Err
+}
+
+type ImportResult struct {
+	// documents read from the reader
+	Documents  int64
+	Progress   BulkWriteProgress
+	LineErrors []*ImportLineError
+}
+
+type importOptions struct {
+	format            DataFormat
+	columnList        []CSVColumn
+	keyFieldList      []string
+	bulkWriterOptions []BulkWriterOption
+}
+
+type ImportOption func(*importOptions)
+
+// default is DataFormatJSONL,both canonical and relaxed extended json are accepted
+func ImportWithFormat(format DataFormat) ImportOption {
+	return func(o *importOptions) {
+		o.format = format
+	}
+}
+
+// map the csv headers to the dotted paths and parse the cells,the headers without a column are
+// imported as strings to the path of the header
+func ImportWithColumns(columnList ...CSVColumn) ImportOption {
+	return func(o *importOptions) {
+		o.columnList = append(o.columnList, columnList...)
+	}
+}
+
+// replace the document having the same key fields instead of inserting,the document is inserted
+// if not exists
+func ImportWithKeyFields(fieldList ...string) ImportOption {
+	return func(o *importOptions) {
+		o.keyFieldList = append(o.keyFieldList, fieldList...)
+	}
+}
+
+// batch size,concurrency and progress of the writes
+func ImportWithBulkWriterOptions(opts ...BulkWriterOption) ImportOption {
+	return func(o *importOptions) {
+		o.bulkWriterOptions = append(o.bulkWriterOptions, opts...)
+	}
+}
+
+// read the documents from rd and write them in batches by a BulkWriter.
+// the lines which cannot be parsed or written are reported by ImportResult.LineErrors and the
+// import goes on,the error wraps ErrImportFailed if any line fails
+func (r *MongoCol) Import(ctx context.Context, rd io.Reader, opts ...ImportOption) (*ImportResult, error) {
+	o := &importOptions{
+		format: DataFormatJSONL,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	var read func(ctx context.Context, rd io.Reader, fn importDocumentFunc) error
+	switch o.format {
+	case DataFormatJSONL:
+		read = readJSONL
+	case DataFormatCSV:
+		read = func(ctx context.Context, rd io.Reader, fn importDocumentFunc) error {
+			return readCSV(ctx, rd, o.columnList, fn)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported import format %q", o.format)
+	}
+
+	result := &ImportResult{
+		LineErrors: make([]*ImportLineError, 0),
+	}
+	writer := r.WithContext(ctx).NewBulkWriter(o.bulkWriterOptions...)
+	readErr := read(ctx, rd, func(line int64, document bson.D, err error) error {
+		if err != nil {
+			result.LineErrors = append(result.LineErrors, &ImportLineError{Line: line, Err: err})
+			return nil
+		}
+		model, err := o.writeModel(document)
+		if err != nil {
+			result.LineErrors = append(result.LineErrors, &ImportLineError{Line: line, Err: err})
+			return nil
+		}
+		result.Documents++
+		return writer.AddWithKey(line, model)
+	})
+	writeErr := writer.Close()
+	result.Progress = writer.Progress()
+
+	var batchErrList []error
+	var bulkWriterErr *BulkWriterError
+	if errors.As(writeErr, &bulkWriterErr) {
+		for _, eachItemErr := range bulkWriterErr.ItemErrors {
+			line, _ := eachItemErr.Key.(int64)
+			result.LineErrors = append(result.LineErrors, &ImportLineError{Line: line, Err: eachItemErr.Err})
+		}
+		batchErrList = bulkWriterErr.BatchErrors
+	} else if writeErr != nil {
+		batchErrList = []error{writeErr}
+	}
+
+	if readErr != nil {
+		return result, readErr
+	}
+	if len(batchErrList) > 0 {
+		return result, fmt.Errorf("%w: %w", ErrImportFailed, errors.Join(batchErrList...))
+	}
+	if len(result.LineErrors) > 0 {
+		return result, fmt.Errorf("%w: %d lines failed,first: %w", ErrImportFailed, len(result.LineErrors), result.LineErrors[0])
+	}
+	return result, nil
+}
+
+// insert the document or replace the document having the same key fields
+func (o *importOptions) writeModel(document bson.D) (mongo.WriteModel, error) {
+	if len(o.keyFieldList) <= 0 {
+		return mongo.NewInsertOneModel().SetDocument(document), nil
+	}
+	filter := make(bson.D, 0, len(o.keyFieldList))
+	for _, eachKeyField := range o.keyFieldList {
+		keyValue, ok := lookupBsonPath(document, eachKeyField)
+		if !ok {
+			return nil, fmt.Errorf("key field %s is missing", eachKeyField)
+		}
+		filter = append(filter, bson.E{Key: eachKeyField, Value: keyValue})
+	}
+	return mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(document).SetUpsert(true), nil
+}
+
+// called for each document read,err is the parse error of the line.
+// returning an error stops the reading
+type importDocumentFunc func(line int64, document bson.D, err error) error
+
+func readJSONL(ctx context.Context, rd io.Reader, fn importDocumentFunc) error {
+	scanner := bufio.NewScanner(rd)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxImportLineSize)
+	line := int64(0)
+	for scanner.Scan() {
+		line++
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) <= 0 {
+			continue
+		}
+		document := bson.D{}
+		err := bson.UnmarshalExtJSON(data, false, &document)
+		if err := fn(line, document, err); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func readCSV(ctx context.Context, rd io.Reader, columnList []CSVColumn, fn importDocumentFunc) error {
+	reader := csv.NewReader(rd)
+	reader.ReuseRecord = true
+	headerList, err := reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	columnMap := make(map[string]CSVColumn, len(columnList))
+	for _, eachColumn := range columnList {
+		columnMap[eachColumn.Header] = eachColumn
+	}
+	rowColumnList := make([]CSVColumn, 0, len(headerList))
+	for _, eachHeader := range headerList {
+		column, ok := columnMap[eachHeader]
+		if !ok {
+			column = CSVColumn{Header: eachHeader, Path: eachHeader}
+		}
+		rowColumnList = append(rowColumnList, column)
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return err
+			}
+			if err := fn(int64(parseErr.StartLine), nil, err); err != nil {
+				return err
+			}
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		document, err := csvRecordToDocument(record, rowColumnList)
+		if err := fn(int64(line), document, err); err != nil {
+			return err
+		}
+	}
+}
+
+func csvRecordToDocument(record []string, columnList []CSVColumn) (bson.D, error) {
+	document := bson.D{}
+	for i, eachCell := range record {
+		if i >= len(columnList) || len(columnList[i].Path) <= 0 {
+			continue
+		}
+		if len(strings.TrimSpace(eachCell)) <= 0 {
+			continue
+		}
+		column := columnList[i]
+		var value interface{} = eachCell
+		if column.Parse != nil {
+			parsed, err := column.Parse(eachCell)
+			if err != nil {
+				return nil, fmt.Errorf("column %s: %w", column.Header, err)
+			}
+			value = parsed
+		}
+		document = setBsonPath(document, column.Path, value)
+	}
+	return document, nil
+}
+
+// set the value of the dotted path,the embedded documents are created if not exist
+func setBsonPath(document bson.D, path string, value interface{}) bson.D {
+	head, rest, nested := strings.Cut(path, ".")
+	for i := range document {
+		if document[i].Key != head {
+			continue
+		}
+		if !nested {
+			document[i].Value = value
+			return document
+		}
+		subDocument, _ := document[i].Value.(bson.D)
+		document[i].Value = setBsonPath(subDocument, rest, value)
+		return document
+	}
+	if !nested {
+		return append(document, bson.E{Key: head, Value: value})
+	}
+	return append(document, bson.E{Key: head, Value: setBsonPath(bson.D{}, rest, value)})
+}
@@ -0,0 +1,106 @@
+package mongodbr
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSetBsonPath(t *testing.T) {
+	testCases := []struct {
+		name     string
+		document bson.D
+		path     string
+		value    interface{}
+		expect   bson.D
+	}{
+		{"top level", bson.D{}, "name", "a", bson.D{{Key: "name", Value: "a"}}},
+		{"replace top level", bson.D{{Key: "name", Value: "a"}}, "name", "b", bson.D{{Key: "name", Value: "b"}}},
+		{"create embedded", bson.D{}, "address.city", "x", bson.D{{Key: "address", Value: bson.D{{Key: "city", Value: "x"}}}}},
+		{
+			"append to embedded",
+			bson.D{{Key: "address", Value: bson.D{{Key: "city", Value: "x"}}}},
+			"address.zip", "1",
+			bson.D{{Key: "address", Value: bson.D{{Key: "city", Value: "x"}, {Key: "zip", Value: "1"}}}},
+		},
+		{
+			"replace a value by embedded",
+			bson.D{{Key: "address", Value: "x"}},
+			"address.city", "y",
+			bson.D{{Key: "address", Value: bson.D{{Key: "city", Value: "y"}}}},
+		},
+		{
+			"deep path keeps order",
+			bson.D{{Key: "name", Value: "a"}},
+			"a.b.c", 1,
+			bson.D{{Key: "name", Value: "a"}, {Key: "a", Value: bson.D{{Key: "b", Value: bson.D{{Key: "c", Value: 1}}}}}},
+		},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			document := setBsonPath(eachCase.document, eachCase.path, eachCase.value)
+			if !reflect.DeepEqual(document, eachCase.expect) {
+				t.Fatalf("expect %v,got %v", eachCase.expect, document)
+			}
+		})
+	}
+}
+
+func TestCSVRecordToDocument(t *testing.T) {
+	columnList := []CSVColumn{
+		{Header: "name", Path: "name"},
+		{Header: "city", Path: "address.city"},
+		{Header: "zip", Path: "address.zip"},
+		{Header: "age", Path: "age", Parse: CSVParseInt},
+		{Header: "ignored"},
+	}
+	testCases := []struct {
+		name    string
+		record  []string
+		expect  bson.D
+		wantErr bool
+	}{
+		{
+			name:   "dotted paths",
+			record: []string{"a", "x", "1", "30", "y"},
+			expect: bson.D{
+				{Key: "name", Value: "a"},
+				{Key: "address", Value: bson.D{{Key: "city", Value: "x"}, {Key: "zip", Value: "1"}}},
+				{Key: "age", Value: int64(30)},
+			},
+		},
+		{
+			name:   "empty cells are skipped",
+			record: []string{"a", " ", "", ""},
+			expect: bson.D{{Key: "name", Value: "a"}},
+		},
+		{
+			name:   "more cells than columns",
+			record: []string{"a", "", "", "", "", "extra"},
+			expect: bson.D{{Key: "name", Value: "a"}},
+		},
+		{
+			name:    "parse error",
+			record:  []string{"a", "", "", "thirty"},
+			wantErr: true,
+		},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			document, err := csvRecordToDocument(eachCase.record, columnList)
+			if eachCase.wantErr {
+				if err == nil {
+					t.Fatalf("expect an error,got %v", document)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(document, eachCase.expect) {
+				t.Fatalf("expect %v,got %v", eachCase.expect, document)
+			}
+		})
+	}
+}
@@ -1,13 +1,54 @@
 package mongodbr
 
 import (
+	"fmt"
+	"strings"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// index key type
+type IndexType string
+
+const (
+	// ordinal ascending/descending key
+	IndexTypeOrdinal IndexType = ""
+	// text index key
+	IndexTypeText IndexType = "text"
+	// geospatial index key on a sphere
+	IndexType2DSphere IndexType = "2dsphere"
+	// hashed index key
+	IndexTypeHashed IndexType = "hashed"
+	// wildcard index key,the field name is $** or path.$**
+	IndexTypeWildcard IndexType = "wildcard"
+
+	wildcardFieldName = "$**"
 )
 
 // index model
 type EntityIndexDefine struct {
 	FieldList []IndexFieldDefine
+
+	//index name,use the driver generated name if empty
+	Name   string
+	Unique bool
+	Sparse bool
+	//ttl index,documents expire after the specified number of seconds
+	ExpireAfterSeconds *int32
+	//only index the documents that match the filter
+	PartialFilterExpression interface{}
+	Collation               *options.Collation
+	//hidden from the query planner
+	Hidden bool
+
+	//weight of the text fields,default is 1
+	Weights map[string]int32
+	//language of the text index
+	DefaultLanguage string
+	//include or exclude paths of a wildcard index on $**
+	WildcardProjection map[string]int32
 }
 
 func NewEntityIndexDefine() *EntityIndexDefine {
@@ -24,29 +65,215 @@ func (d *EntityIndexDefine) AddField(fieldName string, isAsc bool) *EntityIndexD
 	return d
 }
 
+// append a text key
+func (d *EntityIndexDefine) AddTextField(fieldName string) *EntityIndexDefine {
+	d.FieldList = append(d.FieldList, IndexFieldDefine{
+		FieldName: fieldName,
+		IndexType: IndexTypeText,
+	})
+	return d
+}
+
+// append a text key with weight
+func (d *EntityIndexDefine) AddWeightedTextField(fieldName string, weight int32) *EntityIndexDefine {
+	d.AddTextField(fieldName)
+	if d.Weights == nil {
+		d.Weights = make(map[string]int32)
+	}
+	d.Weights[fieldName] = weight
+	return d
+}
+
+// append a 2dsphere key
+func (d *EntityIndexDefine) Add2DSphereField(fieldName string) *EntityIndexDefine {
+	d.FieldList = append(d.FieldList, IndexFieldDefine{
+		FieldName: fieldName,
+		IndexType: IndexType2DSphere,
+	})
+	return d
+}
+
+// append a hashed key
+func (d *EntityIndexDefine) AddHashedField(fieldName string) *EntityIndexDefine {
+	d.FieldList = append(d.FieldList, IndexFieldDefine{
+		FieldName: fieldName,
+		IndexType: IndexTypeHashed,
+	})
+	return d
+}
+
+// append a wildcard key,index all fields if path is empty
+func (d *EntityIndexDefine) AddWildcardField(path string) *EntityIndexDefine {
+	fieldName := wildcardFieldName
+	if len(path) > 0 {
+		fieldName = path + "." + wildcardFieldName
+	}
+	d.FieldList = append(d.FieldList, IndexFieldDefine{
+		FieldName: fieldName,
+		IsAsc:     true,
+		IndexType: IndexTypeWildcard,
+	})
+	return d
+}
+
+func (d *EntityIndexDefine) WithName(name string) *EntityIndexDefine {
+	d.Name = name
+	return d
+}
+
+func (d *EntityIndexDefine) WithUnique(unique bool) *EntityIndexDefine {
+	d.Unique = unique
+	return d
+}
+
+func (d *EntityIndexDefine) WithSparse(sparse bool) *EntityIndexDefine {
+	d.Sparse = sparse
+	return d
+}
+
+func (d *EntityIndexDefine) WithExpireAfterSeconds(seconds int32) *EntityIndexDefine {
+	d.ExpireAfterSeconds = &seconds
+	return d
+}
+
+// partial index filter,usually built with builder.NewFilterBuilder().ToValue()
+func (d *EntityIndexDefine) WithPartialFilterExpression(filter interface{}) *EntityIndexDefine {
+	d.PartialFilterExpression = filter
+	return d
+}
+
+func (d *EntityIndexDefine) WithCollation(collation *options.Collation) *EntityIndexDefine {
+	d.Collation = collation
+	return d
+}
+
+func (d *EntityIndexDefine) WithHidden(hidden bool) *EntityIndexDefine {
+	d.Hidden = hidden
+	return d
+}
+
+func (d *EntityIndexDefine) WithDefaultLanguage(language string) *EntityIndexDefine {
+	d.DefaultLanguage = language
+	return d
+}
+
+// include(1) or exclude(0) a path of a $** wildcard index
+func (d *EntityIndexDefine) WithWildcardProjection(path string, include bool) *EntityIndexDefine {
+	if d.WildcardProjection == nil {
+		d.WildcardProjection = make(map[string]int32)
+	}
+	if include {
+		d.WildcardProjection[path] = 1
+	} else {
+		d.WildcardProjection[path] = 0
+	}
+	return d
+}
+
 type IndexFieldDefine struct {
 	FieldName string
 	IsAsc     bool
+	IndexType IndexType
+}
+
+func (f IndexFieldDefine) keyValue() interface{} {
+	switch f.IndexType {
+	case IndexTypeOrdinal, IndexTypeWildcard:
+		return isAscToIndexValue(f.IsAsc)
+	}
+	return string(f.IndexType)
 }
 
+// convert the define to mongo.IndexModel,nil if the define is invalid,BuildIndexModel returns
+// the reason
 func (d *EntityIndexDefine) ToIndexModel() *mongo.IndexModel {
-	if len(d.FieldList) <= 0 {
+	model, err := d.BuildIndexModel()
+	if err != nil {
 		return nil
 	}
+	return model
+}
+
+// validate the define and convert it to mongo.IndexModel
+func (d *EntityIndexDefine) BuildIndexModel() (*mongo.IndexModel, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return d.indexModel(), nil
+}
+
+func (d *EntityIndexDefine) indexModel() *mongo.IndexModel {
 	keys := bson.D{}
 	for _, eachFieldDefine := range d.FieldList {
 		keys = append(keys, bson.E{
 			Key:   eachFieldDefine.FieldName,
-			Value: isAscToIndexValue(eachFieldDefine.IsAsc),
+			Value: eachFieldDefine.keyValue(),
 		})
 	}
 
 	indexModel := &mongo.IndexModel{
 		Keys: keys,
 	}
+	indexOptions := options.Index()
+	hasOptions := false
+	if len(d.Name) > 0 {
+		indexOptions.SetName(d.Name)
+		hasOptions = true
+	}
+	if d.Unique {
+		indexOptions.SetUnique(true)
+		hasOptions = true
+	}
+	if d.Sparse {
+		indexOptions.SetSparse(true)
+		hasOptions = true
+	}
+	if d.ExpireAfterSeconds != nil {
+		indexOptions.SetExpireAfterSeconds(*d.ExpireAfterSeconds)
+		hasOptions = true
+	}
+	if d.PartialFilterExpression != nil {
+		indexOptions.SetPartialFilterExpression(d.PartialFilterExpression)
+		hasOptions = true
+	}
+	if d.Collation != nil {
+		indexOptions.SetCollation(d.Collation)
+		hasOptions = true
+	}
+	if d.Hidden {
+		indexOptions.SetHidden(true)
+		hasOptions = true
+	}
+	if len(d.Weights) > 0 {
+		indexOptions.SetWeights(d.Weights)
+		hasOptions = true
+	}
+	if len(d.DefaultLanguage) > 0 {
+		indexOptions.SetDefaultLanguage(d.DefaultLanguage)
+		hasOptions = true
+	}
+	if len(d.WildcardProjection) > 0 {
+		indexOptions.SetWildcardProjection(d.WildcardProjection)
+		hasOptions = true
+	}
+	if hasOptions {
+		indexModel.Options = indexOptions
+	}
 	return indexModel
 }
 
+// get the index name,same as the name generated by the driver if Name is empty
+func (d *EntityIndexDefine) GetName() string {
+	if len(d.Name) > 0 {
+		return d.Name
+	}
+	nameList := make([]string, 0, len(d.FieldList))
+	for _, eachFieldDefine := range d.FieldList {
+		nameList = append(nameList, fmt.Sprintf("%s_%v", eachFieldDefine.FieldName, eachFieldDefine.keyValue()))
+	}
+	return strings.Join(nameList, "_")
+}
+
 func isAscToIndexValue(isAsc bool) int32 {
 	if isAsc {
 		return 1
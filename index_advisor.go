@@ -0,0 +1,534 @@
+package mongodbr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// operators that select a single value of the field
+	_equalityOperators = map[string]bool{
+		"$eq":        true,
+		"$in":        true,
+		"$elemMatch": true,
+	}
+)
+
+// the fields used by a query,values are stripped
+type QueryShape struct {
+	Namespace      string
+	EqualityFields []string
+	RangeFields    []string
+	SortFields     []IndexFieldDefine
+	//operations that issued the shape,such as find,findOne,count,aggregate
+	Operations []string
+	Count      int64
+}
+
+func (s *QueryShape) key() string {
+	sortList := make([]string, 0, len(s.SortFields))
+	for _, eachField := range s.SortFields {
+		sortList = append(sortList, fmt.Sprintf("%s:%d", eachField.FieldName, isAscToIndexValue(eachField.IsAsc)))
+	}
+	return fmt.Sprintf("%s|%s|%s|%s", s.Namespace, strings.Join(s.EqualityFields, ","), strings.Join(s.RangeFields, ","), strings.Join(sortList, ","))
+}
+
+func (s *QueryShape) isEmpty() bool {
+	return len(s.EqualityFields) <= 0 && len(s.RangeFields) <= 0 && len(s.SortFields) <= 0
+}
+
+func (s *QueryShape) String() string {
+	return fmt.Sprintf("%s eq:[%s] range:[%s] sort:[%s]", s.Namespace, strings.Join(s.EqualityFields, ","), strings.Join(s.RangeFields, ","), indexFieldListString(s.SortFields))
+}
+
+// index suggested by the equality-sort-range rule
+func (s *QueryShape) SuggestIndex() *EntityIndexDefine {
+	define := NewEntityIndexDefine()
+	used := make(map[string]bool)
+	for _, eachField := range s.EqualityFields {
+		define.AddField(eachField, true)
+		used[eachField] = true
+	}
+	for _, eachField := range s.SortFields {
+		if used[eachField.FieldName] {
+			continue
+		}
+		define.AddField(eachField.FieldName, eachField.IsAsc)
+		used[eachField.FieldName] = true
+	}
+	for _, eachField := range s.RangeFields {
+		if used[eachField] {
+			continue
+		}
+		define.AddField(eachField, true)
+		used[eachField] = true
+	}
+	return define
+}
+
+// IndexAdvisor records the query shapes issued through the repositories and compares them
+// with the existing indexes. it marshals every filter,so only enable it in development
+type IndexAdvisor struct {
+	mu          sync.Mutex
+	shapes      map[string]*QueryShape
+	collections map[string]*mongo.Collection
+}
+
+func NewIndexAdvisor() *IndexAdvisor {
+	return &IndexAdvisor{
+		shapes:      make(map[string]*QueryShape),
+		collections: make(map[string]*mongo.Collection),
+	}
+}
+
+// record the query shapes of the repository with advisor
+func WithIndexAdvisor(advisor *IndexAdvisor) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.indexAdvisor = advisor
+	}
+}
+
+// record a query issued on collection
+func (a *IndexAdvisor) Record(collection *mongo.Collection, operation string, filter interface{}, sortValue interface{}) {
+	if collection == nil {
+		return
+	}
+	shape := &QueryShape{
+		Namespace: collection.Database().Name() + "." + collection.Name(),
+	}
+	if filterDoc, ok := toBsonD(filter); ok {
+		collectFilterShape(shape, filterDoc)
+	}
+	if sortDoc, ok := toBsonD(sortValue); ok {
+		shape.SortFields = sortShape(sortDoc)
+	}
+	sort.Strings(shape.EqualityFields)
+	sort.Strings(shape.RangeFields)
+	a.add(collection, operation, shape)
+}
+
+// record the leading $match and $sort stages of an aggregate pipeline
+func (a *IndexAdvisor) RecordPipeline(collection *mongo.Collection, pipeline interface{}) {
+	stageList, ok := toStageList(pipeline)
+	if !ok {
+		return
+	}
+	var (
+		filter    bson.D
+		sortStage bson.D
+	)
+	for _, eachStage := range stageList {
+		if len(eachStage) != 1 {
+			break
+		}
+		stageValue, _ := toBsonD(eachStage[0].Value)
+		if eachStage[0].Key == "$match" {
+			filter = append(filter, stageValue...)
+			continue
+		}
+		if eachStage[0].Key == "$sort" {
+			sortStage = stageValue
+		}
+		break
+	}
+	a.Record(collection, "aggregate", filter, sortStage)
+}
+
+func (a *IndexAdvisor) add(collection *mongo.Collection, operation string, shape *QueryShape) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.collections[shape.Namespace] = collection
+	key := shape.key()
+	existing, ok := a.shapes[key]
+	if !ok {
+		existing = shape
+		a.shapes[key] = existing
+	}
+	existing.Count++
+	for _, eachOperation := range existing.Operations {
+		if eachOperation == operation {
+			return
+		}
+	}
+	existing.Operations = append(existing.Operations, operation)
+}
+
+// the recorded query shapes
+func (a *IndexAdvisor) Shapes() []QueryShape {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	result := make([]QueryShape, 0, len(a.shapes))
+	for _, eachShape := range a.shapes {
+		result = append(result, *eachShape)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].key() < result[j].key()
+	})
+	return result
+}
+
+// clear the recorded shapes
+func (a *IndexAdvisor) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.shapes = make(map[string]*QueryShape)
+	a.collections = make(map[string]*mongo.Collection)
+}
+
+// query shape without a supporting index
+type IndexSuggestion struct {
+	Shape      QueryShape
+	Suggestion *EntityIndexDefine
+}
+
+// index whose keys are a prefix of another index
+type RedundantIndex struct {
+	Name      string
+	CoveredBy string
+}
+
+// index that has not been used since the server started
+type UnusedIndex struct {
+	Name  string
+	Since time.Time
+}
+
+type CollectionIndexReport struct {
+	Namespace        string
+	Unsupported      []IndexSuggestion
+	RedundantIndexes []RedundantIndex
+	UnusedIndexes    []UnusedIndex
+}
+
+type IndexAdvisorReport struct {
+	Collections []CollectionIndexReport
+}
+
+func (r *IndexAdvisorReport) String() string {
+	builder := strings.Builder{}
+	for _, eachCollection := range r.Collections {
+		builder.WriteString(eachCollection.Namespace + "\n")
+		for _, eachSuggestion := range eachCollection.Unsupported {
+			builder.WriteString(fmt.Sprintf("  no index for %s,suggest {%s}\n", eachSuggestion.Shape.String(), indexFieldListString(eachSuggestion.Suggestion.FieldList)))
+		}
+		for _, eachRedundant := range eachCollection.RedundantIndexes {
+			builder.WriteString(fmt.Sprintf("  index %s is redundant,covered by %s\n", eachRedundant.Name, eachRedundant.CoveredBy))
+		}
+		for _, eachUnused := range eachCollection.UnusedIndexes {
+			builder.WriteString(fmt.Sprintf("  index %s is unused since %s\n", eachUnused.Name, eachUnused.Since.Format(time.RFC3339)))
+		}
+	}
+	return builder.String()
+}
+
+// compare the recorded shapes with the indexes of the collections
+func (a *IndexAdvisor) Report(ctx context.Context) (*IndexAdvisorReport, error) {
+	shapeList := a.Shapes()
+	a.mu.Lock()
+	collections := make(map[string]*mongo.Collection, len(a.collections))
+	for eachNamespace, eachCollection := range a.collections {
+		collections[eachNamespace] = eachCollection
+	}
+	a.mu.Unlock()
+
+	namespaceList := make([]string, 0, len(collections))
+	for eachNamespace := range collections {
+		namespaceList = append(namespaceList, eachNamespace)
+	}
+	sort.Strings(namespaceList)
+
+	report := &IndexAdvisorReport{}
+	for _, eachNamespace := range namespaceList {
+		collection := collections[eachNamespace]
+		indexList, err := NewMongoCol(collection).listIndexDocuments(ctx)
+		if err != nil {
+			return nil, err
+		}
+		collectionReport := CollectionIndexReport{
+			Namespace: eachNamespace,
+		}
+		keysList := make([]advisorIndex, 0, len(indexList))
+		for _, eachIndex := range indexList {
+			keysList = append(keysList, newAdvisorIndex(eachIndex))
+		}
+		for _, eachShape := range shapeList {
+			if eachShape.Namespace != eachNamespace || eachShape.isEmpty() {
+				continue
+			}
+			if isShapeSupported(&eachShape, keysList) {
+				continue
+			}
+			collectionReport.Unsupported = append(collectionReport.Unsupported, IndexSuggestion{
+				Shape:      eachShape,
+				Suggestion: eachShape.SuggestIndex(),
+			})
+		}
+		collectionReport.RedundantIndexes = findRedundantIndexes(keysList)
+		unusedList, err := findUnusedIndexes(ctx, collection)
+		if err != nil {
+			return nil, err
+		}
+		collectionReport.UnusedIndexes = unusedList
+		report.Collections = append(report.Collections, collectionReport)
+	}
+	return report, nil
+}
+
+// keys and options of an existing index relevant to the advisor
+type advisorIndex struct {
+	name      string
+	fieldList []IndexFieldDefine
+	//unique,partial,text,... indexes are never reported as redundant
+	special bool
+}
+
+func newAdvisorIndex(doc bson.D) advisorIndex {
+	index := advisorIndex{}
+	for _, eachElement := range doc {
+		switch eachElement.Key {
+		case "name":
+			index.name, _ = eachElement.Value.(string)
+		case "key":
+			keys, _ := eachElement.Value.(bson.D)
+			for _, eachKey := range keys {
+				direction, ok := normalizeBsonValue(eachKey.Value).(float64)
+				if !ok {
+					index.special = true
+				}
+				index.fieldList = append(index.fieldList, IndexFieldDefine{
+					FieldName: eachKey.Key,
+					IsAsc:     direction >= 0,
+				})
+			}
+		case "unique", "partialFilterExpression", "sparse", "expireAfterSeconds", "collation", "hidden":
+			if eachElement.Value != false {
+				index.special = true
+			}
+		}
+	}
+	return index
+}
+
+// an index supports the shape if its leading keys are the equality fields in any order,its
+// leading key is a range field of a query without equality,or it provides the sort of a query
+// without filter
+func isShapeSupported(shape *QueryShape, indexList []advisorIndex) bool {
+	equalityFields := make(map[string]bool)
+	for _, eachField := range shape.EqualityFields {
+		equalityFields[eachField] = true
+	}
+	rangeFields := make(map[string]bool)
+	for _, eachField := range shape.RangeFields {
+		rangeFields[eachField] = true
+	}
+	for _, eachIndex := range indexList {
+		if len(eachIndex.fieldList) <= 0 {
+			continue
+		}
+		leading := eachIndex.fieldList[0].FieldName
+		switch {
+		case len(equalityFields) > 0:
+			if isIndexPrefixOf(eachIndex.fieldList, equalityFields) {
+				return true
+			}
+		case len(rangeFields) > 0:
+			if rangeFields[leading] {
+				return true
+			}
+		case len(shape.SortFields) > 0:
+			if shape.SortFields[0].FieldName == leading {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// whether the first len(fields) keys of fieldList are fields
+func isIndexPrefixOf(fieldList []IndexFieldDefine, fields map[string]bool) bool {
+	if len(fieldList) < len(fields) {
+		return false
+	}
+	for _, eachField := range fieldList[:len(fields)] {
+		if !fields[eachField.FieldName] {
+			return false
+		}
+	}
+	return true
+}
+
+func findRedundantIndexes(indexList []advisorIndex) []RedundantIndex {
+	result := make([]RedundantIndex, 0)
+	for _, eachIndex := range indexList {
+		if eachIndex.special || eachIndex.name == _idIndexName {
+			continue
+		}
+		for _, eachOther := range indexList {
+			if eachOther.name == eachIndex.name || len(eachOther.fieldList) <= len(eachIndex.fieldList) {
+				continue
+			}
+			if isIndexFieldPrefix(eachIndex.fieldList, eachOther.fieldList) {
+				result = append(result, RedundantIndex{
+					Name:      eachIndex.name,
+					CoveredBy: eachOther.name,
+				})
+				break
+			}
+		}
+	}
+	return result
+}
+
+func isIndexFieldPrefix(prefix []IndexFieldDefine, fieldList []IndexFieldDefine) bool {
+	for i, eachField := range prefix {
+		if fieldList[i].FieldName != eachField.FieldName || fieldList[i].IsAsc != eachField.IsAsc {
+			return false
+		}
+	}
+	return true
+}
+
+func findUnusedIndexes(ctx context.Context, collection *mongo.Collection) ([]UnusedIndex, error) {
+	cur, err := collection.Aggregate(ctx, mongo.Pipeline{{{Key: "$indexStats", Value: bson.M{}}}})
+	if err != nil {
+		return nil, err
+	}
+	var statsList []struct {
+		Name     string `bson:"name"`
+		Accesses struct {
+			Ops   int64     `bson:"ops"`
+			Since time.Time `bson:"since"`
+		} `bson:"accesses"`
+	}
+	if err := cur.All(ctx, &statsList); err != nil {
+		return nil, err
+	}
+	result := make([]UnusedIndex, 0)
+	for _, eachStats := range statsList {
+		if eachStats.Name == _idIndexName || eachStats.Accesses.Ops > 0 {
+			continue
+		}
+		result = append(result, UnusedIndex{
+			Name:  eachStats.Name,
+			Since: eachStats.Accesses.Since,
+		})
+	}
+	return result, nil
+}
+
+func collectFilterShape(shape *QueryShape, filter bson.D) {
+	for _, eachElement := range filter {
+		if eachElement.Key == "$and" {
+			list, _ := eachElement.Value.(bson.A)
+			for _, eachItem := range list {
+				if itemDoc, ok := toBsonD(eachItem); ok {
+					collectFilterShape(shape, itemDoc)
+				}
+			}
+			continue
+		}
+		if strings.HasPrefix(eachElement.Key, "$") {
+			//$or,$expr,$text... cannot be described by a single shape
+			continue
+		}
+		condition, ok := eachElement.Value.(bson.D)
+		if !ok || len(condition) <= 0 || !strings.HasPrefix(condition[0].Key, "$") {
+			appendUnique(&shape.EqualityFields, eachElement.Key)
+			continue
+		}
+		isEquality := true
+		for _, eachCondition := range condition {
+			if !_equalityOperators[eachCondition.Key] {
+				isEquality = false
+			}
+		}
+		if isEquality {
+			appendUnique(&shape.EqualityFields, eachElement.Key)
+		} else {
+			appendUnique(&shape.RangeFields, eachElement.Key)
+		}
+	}
+}
+
+func sortShape(sortDoc bson.D) []IndexFieldDefine {
+	result := make([]IndexFieldDefine, 0, len(sortDoc))
+	for _, eachElement := range sortDoc {
+		direction, ok := normalizeBsonValue(eachElement.Value).(float64)
+		if !ok {
+			//{$meta:"textScore"}
+			continue
+		}
+		result = append(result, IndexFieldDefine{
+			FieldName: eachElement.Key,
+			IsAsc:     direction >= 0,
+		})
+	}
+	return result
+}
+
+// convert a document of any type into bson.D,embedded documents become bson.D as well
+func toBsonD(v interface{}) (bson.D, bool) {
+	if v == nil {
+		return nil, false
+	}
+	data, err := bson.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	doc := bson.D{}
+	if err := bson.Unmarshal(data, &doc); err != nil {
+		return nil, false
+	}
+	return doc, true
+}
+
+// convert a pipeline of any type into a list of stages
+func toStageList(pipeline interface{}) ([]bson.D, bool) {
+	if stageList, ok := pipeline.(mongo.Pipeline); ok {
+		return stageList, true
+	}
+	wrapper, ok := toBsonD(bson.M{"pipeline": pipeline})
+	if !ok || len(wrapper) <= 0 {
+		return nil, false
+	}
+	list, ok := wrapper[0].Value.(bson.A)
+	if !ok {
+		return nil, false
+	}
+	stageList := make([]bson.D, 0, len(list))
+	for _, eachItem := range list {
+		stage, ok := toBsonD(eachItem)
+		if !ok {
+			return nil, false
+		}
+		stageList = append(stageList, stage)
+	}
+	return stageList, true
+}
+
+func indexFieldListString(fieldList []IndexFieldDefine) string {
+	partList := make([]string, 0, len(fieldList))
+	for _, eachField := range fieldList {
+		partList = append(partList, fmt.Sprintf("%s:%v", eachField.FieldName, eachField.keyValue()))
+	}
+	return strings.Join(partList, ",")
+}
+
+func appendUnique(list *[]string, value string) {
+	for _, eachValue := range *list {
+		if eachValue == value {
+			return
+		}
+	}
+	*list = append(*list, value)
+}
@@ -0,0 +1,48 @@
+package mongodbr
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestIsShapeSupported(t *testing.T) {
+	newIndex := func(keys bson.D) advisorIndex {
+		return newAdvisorIndex(bson.D{{Key: "name", Value: "test"}, {Key: "key", Value: keys}})
+	}
+	aIndex := newIndex(bson.D{{Key: "a", Value: int32(1)}})
+	bIndex := newIndex(bson.D{{Key: "b", Value: int32(1)}})
+	abIndex := newIndex(bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(-1)}})
+	baIndex := newIndex(bson.D{{Key: "b", Value: int32(1)}, {Key: "a", Value: int32(1)}})
+	acbIndex := newIndex(bson.D{{Key: "a", Value: int32(1)}, {Key: "c", Value: int32(1)}, {Key: "b", Value: int32(1)}})
+	abcIndex := newIndex(bson.D{{Key: "a", Value: int32(1)}, {Key: "b", Value: int32(1)}, {Key: "c", Value: int32(1)}})
+	testCases := []struct {
+		name      string
+		shape     QueryShape
+		indexList []advisorIndex
+		expect    bool
+	}{
+		{"no index", QueryShape{EqualityFields: []string{"a"}}, nil, false},
+		{"single equality", QueryShape{EqualityFields: []string{"a"}}, []advisorIndex{aIndex}, true},
+		{"equality on the second key only", QueryShape{EqualityFields: []string{"b"}}, []advisorIndex{abIndex}, false},
+		{"index on one of the equality fields", QueryShape{EqualityFields: []string{"a", "b"}}, []advisorIndex{bIndex}, false},
+		{"index shorter than the equality fields", QueryShape{EqualityFields: []string{"a", "b"}}, []advisorIndex{aIndex}, false},
+		{"equality prefix", QueryShape{EqualityFields: []string{"a", "b"}}, []advisorIndex{abIndex}, true},
+		{"equality prefix in another order", QueryShape{EqualityFields: []string{"a", "b"}}, []advisorIndex{baIndex}, true},
+		{"equality prefix with more keys", QueryShape{EqualityFields: []string{"a", "b"}}, []advisorIndex{abcIndex}, true},
+		{"equality fields not a prefix", QueryShape{EqualityFields: []string{"a", "b"}}, []advisorIndex{acbIndex}, false},
+		{"any of the indexes", QueryShape{EqualityFields: []string{"a", "b"}}, []advisorIndex{bIndex, acbIndex, baIndex}, true},
+		{"equality and range", QueryShape{EqualityFields: []string{"a"}, RangeFields: []string{"b"}}, []advisorIndex{abIndex}, true},
+		{"range on the leading key", QueryShape{RangeFields: []string{"a"}}, []advisorIndex{abIndex}, true},
+		{"range on the second key", QueryShape{RangeFields: []string{"b"}}, []advisorIndex{abIndex}, false},
+		{"sort on the leading key", QueryShape{SortFields: []IndexFieldDefine{{FieldName: "b", IsAsc: true}}}, []advisorIndex{baIndex}, true},
+		{"sort on the second key", QueryShape{SortFields: []IndexFieldDefine{{FieldName: "b", IsAsc: true}}}, []advisorIndex{abIndex}, false},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			if supported := isShapeSupported(&eachCase.shape, eachCase.indexList); supported != eachCase.expect {
+				t.Fatalf("expect %v,got %v", eachCase.expect, supported)
+			}
+		})
+	}
+}
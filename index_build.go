@@ -0,0 +1,378 @@
+package mongodbr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	_indexRegistry = &indexRegistry{}
+)
+
+type indexRegistryEntry struct {
+	repository IRepository
+	defineList []*EntityIndexDefine
+}
+
+// index defines of the repositories,built by BuildRegisteredIndexes at startup
+type indexRegistry struct {
+	mu        sync.Mutex
+	entryList []*indexRegistryEntry
+}
+
+func (r *indexRegistry) add(repository IRepository, defineList []*EntityIndexDefine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, eachEntry := range r.entryList {
+		if eachEntry.repository == repository {
+			eachEntry.defineList = append(eachEntry.defineList, defineList...)
+			return
+		}
+	}
+	r.entryList = append(r.entryList, &indexRegistryEntry{
+		repository: repository,
+		defineList: defineList,
+	})
+}
+
+func (r *indexRegistry) snapshot() []indexRegistryEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]indexRegistryEntry, 0, len(r.entryList))
+	for _, eachEntry := range r.entryList {
+		result = append(result, indexRegistryEntry{
+			repository: eachEntry.repository,
+			defineList: append([]*EntityIndexDefine(nil), eachEntry.defineList...),
+		})
+	}
+	return result
+}
+
+// regist the index defines of repository,the indexes are built by BuildRegisteredIndexes
+func RegistIndexes(repository IRepository, defineList ...*EntityIndexDefine) {
+	if repository == nil || len(defineList) <= 0 {
+		return
+	}
+	_indexRegistry.add(repository, defineList)
+}
+
+// progress of an index build
+type IndexBuildProgress struct {
+	Database   string
+	Collection string
+	IndexName  string
+	//message reported by the server,such as "Index Build: scanning collection"
+	Message string
+	Done    int64
+	Total   int64
+	Elapsed time.Duration
+
+	//the build of the index is finished,Err is set if it failed
+	Finished bool
+	Err      error
+}
+
+// failure of an index build
+type IndexBuildFailure struct {
+	Database   string
+	Collection string
+	IndexName  string
+	Err        error
+}
+
+// aggregated error of BuildRegisteredIndexes
+type IndexBuildError struct {
+	Failures []IndexBuildFailure
+}
+
+func (e *IndexBuildError) Error() string {
+	messageList := make([]string, 0, len(e.Failures))
+	for _, eachFailure := range e.Failures {
+		messageList = append(messageList, fmt.Sprintf("%s.%s.%s: %v", eachFailure.Database, eachFailure.Collection, eachFailure.IndexName, eachFailure.Err))
+	}
+	return fmt.Sprintf("failed to build %d indexes: %s", len(e.Failures), strings.Join(messageList, "; "))
+}
+
+func (e *IndexBuildError) Unwrap() []error {
+	errList := make([]error, 0, len(e.Failures))
+	for _, eachFailure := range e.Failures {
+		errList = append(errList, eachFailure.Err)
+	}
+	return errList
+}
+
+type indexBuildOptions struct {
+	concurrency  int
+	pollInterval time.Duration
+	onProgress   func(IndexBuildProgress)
+}
+
+type IndexBuildOption func(*indexBuildOptions)
+
+// max number of collections whose indexes are built at the same time,default is 4
+func IndexBuildWithConcurrency(concurrency int) IndexBuildOption {
+	return func(o *indexBuildOptions) {
+		if concurrency > 0 {
+			o.concurrency = concurrency
+		}
+	}
+}
+
+// interval of polling currentOp for the progress,default is 5s
+func IndexBuildWithPollInterval(interval time.Duration) IndexBuildOption {
+	return func(o *indexBuildOptions) {
+		if interval > 0 {
+			o.pollInterval = interval
+		}
+	}
+}
+
+// receive the progress of the index builds
+func IndexBuildWithProgress(onProgress func(IndexBuildProgress)) IndexBuildOption {
+	return func(o *indexBuildOptions) {
+		o.onProgress = onProgress
+	}
+}
+
+// build the indexes of all registered repositories concurrently,return *IndexBuildError
+// listing every index that failed. existing indexes with the same definition are skipped
+func BuildRegisteredIndexes(ctx context.Context, opts ...IndexBuildOption) error {
+	o := &indexBuildOptions{
+		concurrency:  4,
+		pollInterval: 5 * time.Second,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	entryList := _indexRegistry.snapshot()
+	if len(entryList) <= 0 {
+		return nil
+	}
+
+	var (
+		mu       sync.Mutex
+		failures []IndexBuildFailure
+		wg       sync.WaitGroup
+		pollWg   sync.WaitGroup
+	)
+	report := func(progress IndexBuildProgress) {
+		if o.onProgress == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		o.onProgress(progress)
+	}
+
+	pollCtx, stopPoll := context.WithCancel(ctx)
+	defer stopPoll()
+	if o.onProgress != nil {
+		pollWg.Add(1)
+		go func() {
+			defer pollWg.Done()
+			pollIndexBuildProgress(pollCtx, entryList, o.pollInterval, report)
+		}()
+	}
+
+	sem := make(chan struct{}, o.concurrency)
+	for i := range entryList {
+		entry := entryList[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			entryFailures := buildRepositoryIndexes(ctx, entry, report)
+			if len(entryFailures) > 0 {
+				mu.Lock()
+				failures = append(failures, entryFailures...)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	//onProgress is not called after returning
+	stopPoll()
+	pollWg.Wait()
+
+	if len(failures) > 0 {
+		return &IndexBuildError{Failures: failures}
+	}
+	return nil
+}
+
+type indexEnsurer interface {
+	ensureIndexes(ctx context.Context, defineList []*EntityIndexDefine, opts ...EnsureIndexesOption) (*IndexPlan, error)
+}
+
+// the MongoCol of repository running within ctx,the index builds share its configuration such as
+// the retry policy,metrics and tracing.a repository not backed by a MongoCol uses the default
+// configuration
+func indexBuildMongoCol(ctx context.Context, repository IRepository) *MongoCol {
+	for {
+		switch r := repository.(type) {
+		case interface {
+			WithContext(ctx context.Context) *RepositoryBase
+		}:
+			return r.WithContext(ctx).MongoCol
+		case *CachedRepository:
+			repository = r.IRepository
+			continue
+		}
+		return NewMongoCol(repository.GetCollection()).WithContext(ctx)
+	}
+}
+
+func buildRepositoryIndexes(ctx context.Context, entry indexRegistryEntry, report func(IndexBuildProgress)) []IndexBuildFailure {
+	collection := entry.repository.GetCollection()
+	databaseName := collection.Database().Name()
+	newFailure := func(indexName string, err error) IndexBuildFailure {
+		report(IndexBuildProgress{
+			Database:   databaseName,
+			Collection: collection.Name(),
+			IndexName:  indexName,
+			Finished:   true,
+			Err:        err,
+		})
+		return IndexBuildFailure{
+			Database:   databaseName,
+			Collection: collection.Name(),
+			IndexName:  indexName,
+			Err:        err,
+		}
+	}
+
+	var (
+		plan *IndexPlan
+		err  error
+	)
+	if ensurer, ok := entry.repository.(indexEnsurer); ok {
+		plan, err = ensurer.ensureIndexes(ctx, entry.defineList, EnsureIndexesWithDryRun())
+	} else {
+		plan, err = entry.repository.EnsureIndexes(entry.defineList, EnsureIndexesWithDryRun())
+	}
+	if err != nil {
+		failures := make([]IndexBuildFailure, 0, len(entry.defineList))
+		for _, eachDefine := range entry.defineList {
+			failures = append(failures, newFailure(eachDefine.GetName(), err))
+		}
+		return failures
+	}
+
+	mongoCol := indexBuildMongoCol(ctx, entry.repository)
+	failures := make([]IndexBuildFailure, 0)
+	for _, eachConflict := range plan.Conflicts {
+		failures = append(failures, newFailure(eachConflict.Name, fmt.Errorf("%w: %s", ErrIndexConflict, eachConflict.Reason)))
+	}
+	for _, eachDefine := range plan.Create {
+		startTime := time.Now()
+		model, err := eachDefine.BuildIndexModel()
+		if err == nil {
+			_, err = mongoCol.CreateIndex(*model)
+		}
+		if err != nil {
+			failures = append(failures, newFailure(eachDefine.GetName(), err))
+			continue
+		}
+		report(IndexBuildProgress{
+			Database:   databaseName,
+			Collection: collection.Name(),
+			IndexName:  eachDefine.GetName(),
+			Elapsed:    time.Since(startTime),
+			Finished:   true,
+		})
+	}
+	return failures
+}
+
+// poll currentOp of every client until ctx is done,report the index builds on the registered collections
+func pollIndexBuildProgress(ctx context.Context, entryList []indexRegistryEntry, interval time.Duration, report func(IndexBuildProgress)) {
+	namespaceByClient := make(map[*mongo.Client][]string)
+	for _, eachEntry := range entryList {
+		collection := eachEntry.repository.GetCollection()
+		client := collection.Database().Client()
+		namespaceByClient[client] = append(namespaceByClient[client], collection.Database().Name()+"."+collection.Name())
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for eachClient, eachNamespaceList := range namespaceByClient {
+			progressList, err := currentIndexBuilds(ctx, eachClient, eachNamespaceList)
+			if err != nil {
+				//currentOp requires the inprog privilege,stop polling the client if not allowed
+				delete(namespaceByClient, eachClient)
+				continue
+			}
+			for _, eachProgress := range progressList {
+				report(eachProgress)
+			}
+		}
+	}
+}
+
+func currentIndexBuilds(ctx context.Context, client *mongo.Client, namespaceList []string) ([]IndexBuildProgress, error) {
+	command := bson.D{
+		{Key: "currentOp", Value: true},
+		{Key: "ns", Value: bson.M{"$in": namespaceList}},
+		{Key: "$or", Value: bson.A{
+			bson.M{"command.createIndexes": bson.M{"$exists": true}},
+			bson.M{"msg": bson.M{"$regex": "^Index Build"}},
+		}},
+	}
+	var result struct {
+		InProg []struct {
+			Namespace        string `bson:"ns"`
+			Message          string `bson:"msg"`
+			MicrosecsRunning int64  `bson:"microsecs_running"`
+			Progress         struct {
+				Done  int64 `bson:"done"`
+				Total int64 `bson:"total"`
+			} `bson:"progress"`
+			Command struct {
+				Indexes []struct {
+					Name string `bson:"name"`
+				} `bson:"indexes"`
+			} `bson:"command"`
+		} `bson:"inprog"`
+	}
+	if err := client.Database("admin").RunCommand(ctx, command).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	progressList := make([]IndexBuildProgress, 0, len(result.InProg))
+	for _, eachOp := range result.InProg {
+		databaseName, collectionName, _ := strings.Cut(eachOp.Namespace, ".")
+		progress := IndexBuildProgress{
+			Database:   databaseName,
+			Collection: collectionName,
+			Message:    eachOp.Message,
+			Done:       eachOp.Progress.Done,
+			Total:      eachOp.Progress.Total,
+			Elapsed:    time.Duration(eachOp.MicrosecsRunning) * time.Microsecond,
+		}
+		if len(eachOp.Command.Indexes) <= 0 {
+			progressList = append(progressList, progress)
+			continue
+		}
+		for _, eachIndex := range eachOp.Command.Indexes {
+			progress.IndexName = eachIndex.Name
+			progressList = append(progressList, progress)
+		}
+	}
+	return progressList, nil
+}
@@ -0,0 +1,125 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// a repository whose plan is given,the indexes are created by its RepositoryBase
+type indexBuildTestRepository struct {
+	IRepository
+	repository *RepositoryBase
+	plan       *IndexPlan
+}
+
+func (r *indexBuildTestRepository) GetCollection() *mongo.Collection {
+	return r.repository.GetCollection()
+}
+
+func (r *indexBuildTestRepository) WithContext(ctx context.Context) *RepositoryBase {
+	return r.repository.WithContext(ctx)
+}
+
+func (r *indexBuildTestRepository) ensureIndexes(context.Context, []*EntityIndexDefine, ...EnsureIndexesOption) (*IndexPlan, error) {
+	return r.plan, nil
+}
+
+// record the names of the spans
+type indexBuildTestTracer struct {
+	mu       sync.Mutex
+	nameList []string
+}
+
+func (t *indexBuildTestTracer) Start(ctx context.Context, spanName string, attrs ...TraceAttribute) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nameList = append(t.nameList, spanName)
+	return ctx, nil
+}
+
+func TestIndexRegistry(t *testing.T) {
+	client, err := mongo.NewClient(options.Client().ApplyURI("mongodb://localhost:27017"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &indexBuildTestRepository{repository: &RepositoryBase{MongoCol: NewMongoCol(client.Database("shop").Collection("a"))}}
+	b := &indexBuildTestRepository{repository: &RepositoryBase{MongoCol: NewMongoCol(client.Database("shop").Collection("b"))}}
+	nameIndex := NewEntityIndexDefine().AddField("name", true)
+	skuIndex := NewEntityIndexDefine().AddField("sku", true)
+	codeIndex := NewEntityIndexDefine().AddField("code", true)
+
+	registry := &indexRegistry{}
+	registry.add(a, []*EntityIndexDefine{nameIndex})
+	registry.add(b, []*EntityIndexDefine{codeIndex})
+	registry.add(a, []*EntityIndexDefine{skuIndex})
+
+	entryList := registry.snapshot()
+	if len(entryList) != 2 || entryList[0].repository != a || entryList[1].repository != b {
+		t.Fatalf("expect one entry by repository in the order of registration,got %v", entryList)
+	}
+	if !reflect.DeepEqual(entryList[0].defineList, []*EntityIndexDefine{nameIndex, skuIndex}) {
+		t.Fatalf("expect the defines of a merged,got %v", entryList[0].defineList)
+	}
+	entryList[0].defineList[0] = codeIndex
+	if registry.snapshot()[0].defineList[0] != nameIndex {
+		t.Fatal("the snapshot must not share the define list with the registry")
+	}
+}
+
+func TestBuildRepositoryIndexes(t *testing.T) {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:1/?serverSelectionTimeoutMS=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	tracer := &indexBuildTestTracer{}
+	repository, err := NewRepositoryBase(func() *mongo.Collection {
+		return client.Database("shop").Collection("products")
+	}, WithTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := indexRegistryEntry{
+		repository: &indexBuildTestRepository{
+			repository: repository,
+			plan: &IndexPlan{
+				Conflicts: []IndexConflict{{Name: "sku_1", Reason: "unique differs"}},
+				Create: []*EntityIndexDefine{
+					NewEntityIndexDefine().WithName("invalid"),
+					NewEntityIndexDefine().AddField("name", true),
+				},
+			},
+		},
+	}
+	var progressList []IndexBuildProgress
+	failures := buildRepositoryIndexes(context.Background(), entry, func(progress IndexBuildProgress) {
+		progressList = append(progressList, progress)
+	})
+
+	nameList := make([]string, 0, len(failures))
+	for _, eachFailure := range failures {
+		nameList = append(nameList, eachFailure.IndexName)
+		if eachFailure.Database != "shop" || eachFailure.Collection != "products" {
+			t.Fatalf("expect the failure on shop.products,got %s.%s", eachFailure.Database, eachFailure.Collection)
+		}
+	}
+	if !reflect.DeepEqual(nameList, []string{"sku_1", "invalid", "name_1"}) {
+		t.Fatalf("expect the conflict and the failed creates,got %v", nameList)
+	}
+	if !errors.Is(failures[0].Err, ErrIndexConflict) || !errors.Is(failures[1].Err, ErrInvalidIndexDefine) {
+		t.Fatalf("expect a conflict and an invalid define,got %v", failures)
+	}
+	if len(progressList) != 3 || !progressList[2].Finished || progressList[2].Err == nil {
+		t.Fatalf("expect every failure reported as finished,got %v", progressList)
+	}
+	//the invalid define is not sent,the valid one is created by the repository
+	if !reflect.DeepEqual(tracer.nameList, []string{"createIndexes products"}) {
+		t.Fatalf("expect the index created through the repository,got %v", tracer.nameList)
+	}
+}
@@ -0,0 +1,435 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	_idIndexName = "_id_"
+)
+
+var (
+	ErrIndexConflict = errors.New("index conflict")
+
+	// options that must match between the desired and the existing index,
+	// absent or false in the desired define means the existing index must not have them
+	_strictIndexOptionKeys = []string{"unique", "sparse", "expireAfterSeconds", "partialFilterExpression", "hidden", "collation"}
+)
+
+// the result of comparing the desired index defines with the existing indexes
+type IndexPlan struct {
+	//indexes that will be created
+	Create []*EntityIndexDefine
+	//name of the indexes that already match
+	Unchanged []string
+	//indexes whose existing definition differs from the desired one
+	Conflicts []IndexConflict
+	//name of the unmanaged indexes that will be dropped
+	Drop []string
+	//name of the existing indexes that are not declared
+	Unmanaged []string
+
+	//whether the plan has been executed
+	Applied bool
+}
+
+// whether the plan has no changes
+func (p *IndexPlan) IsEmpty() bool {
+	return len(p.Create) <= 0 && len(p.Drop) <= 0 && len(p.Conflicts) <= 0
+}
+
+func (p *IndexPlan) String() string {
+	builder := strings.Builder{}
+	for _, eachDefine := range p.Create {
+		builder.WriteString(fmt.Sprintf("+ create %s\n", eachDefine.GetName()))
+	}
+	for _, eachName := range p.Drop {
+		builder.WriteString(fmt.Sprintf("- drop %s\n", eachName))
+	}
+	for _, eachConflict := range p.Conflicts {
+		builder.WriteString(fmt.Sprintf("! conflict %s: %s\n", eachConflict.Name, eachConflict.Reason))
+	}
+	for _, eachName := range p.Unchanged {
+		builder.WriteString(fmt.Sprintf("= unchanged %s\n", eachName))
+	}
+	return builder.String()
+}
+
+// existing index that conflicts with a desired define
+type IndexConflict struct {
+	Name     string
+	Desired  *EntityIndexDefine
+	Existing bson.M
+	Reason   string
+}
+
+type ensureIndexesOptions struct {
+	dryRun        bool
+	dropUnmanaged bool
+}
+
+type EnsureIndexesOption func(*ensureIndexesOptions)
+
+// only compute the plan,do not change anything
+func EnsureIndexesWithDryRun() EnsureIndexesOption {
+	return func(o *ensureIndexesOptions) {
+		o.dryRun = true
+	}
+}
+
+// drop the existing indexes that are not declared
+func EnsureIndexesWithDropUnmanaged() EnsureIndexesOption {
+	return func(o *ensureIndexesOptions) {
+		o.dropUnmanaged = true
+	}
+}
+
+// compare the defines with the existing indexes,create the missing ones and optionally drop
+// the unmanaged ones. nothing is changed if there are conflicts,the plan is returned with ErrIndexConflict
+func (r *MongoCol) EnsureIndexes(defineList []*EntityIndexDefine, opts ...EnsureIndexesOption) (*IndexPlan, error) {
+	var plan *IndexPlan
+	err := r.execute("ensureIndexes", nil, func(op *operation) (err error) {
+		plan, err = r.ensureIndexes(op.ctx, defineList, opts...)
+		return err
+	})
+	return plan, err
+}
+
+func (r *MongoCol) ensureIndexes(ctx context.Context, defineList []*EntityIndexDefine, opts ...EnsureIndexesOption) (*IndexPlan, error) {
+	defineList = removeNilIndexDefines(defineList)
+	o := &ensureIndexesOptions{}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	for _, eachDefine := range defineList {
+		if err := eachDefine.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	existingList, err := r.listIndexDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan := buildIndexPlan(defineList, existingList, o)
+	if o.dryRun {
+		return plan, nil
+	}
+	if len(plan.Conflicts) > 0 {
+		nameList := make([]string, 0, len(plan.Conflicts))
+		for _, eachConflict := range plan.Conflicts {
+			nameList = append(nameList, eachConflict.Name)
+		}
+		return plan, fmt.Errorf("%w,col:%s,indexes:%s", ErrIndexConflict, r.collection.Name(), strings.Join(nameList, ","))
+	}
+	for _, eachName := range plan.Drop {
+		if _, err := r.collection.Indexes().DropOne(ctx, eachName); err != nil {
+			return plan, err
+		}
+	}
+	if len(plan.Create) > 0 {
+		modelList := make([]mongo.IndexModel, 0, len(plan.Create))
+		for _, eachDefine := range plan.Create {
+			model := eachDefine.ToIndexModel()
+			if model == nil {
+				continue
+			}
+			modelList = append(modelList, *model)
+		}
+		if _, err := r.collection.Indexes().CreateMany(ctx, modelList); err != nil {
+			return plan, err
+		}
+	}
+	plan.Applied = true
+	return plan, nil
+}
+
+// list the indexes with the key order preserved
+func (r *MongoCol) listIndexDocuments(ctx context.Context) ([]bson.D, error) {
+	cur, err := r.collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	indexes := make([]bson.D, 0)
+	if err := cur.All(ctx, &indexes); err != nil {
+		return nil, err
+	}
+	return indexes, nil
+}
+
+func buildIndexPlan(defineList []*EntityIndexDefine, existingList []bson.D, o *ensureIndexesOptions) *IndexPlan {
+	plan := &IndexPlan{}
+	existingSpecList := make([]*indexSpec, 0, len(existingList))
+	for _, eachExisting := range existingList {
+		spec := newIndexSpecFromDocument(eachExisting)
+		if spec.name == _idIndexName {
+			continue
+		}
+		existingSpecList = append(existingSpecList, spec)
+	}
+	matched := make(map[string]bool)
+	for _, eachDefine := range defineList {
+		desired := eachDefine.indexSpec()
+		existing := findIndexSpec(existingSpecList, func(s *indexSpec) bool {
+			return s.name == desired.name
+		})
+		if existing != nil {
+			matched[existing.name] = true
+			if reason := desired.diff(existing); len(reason) > 0 {
+				plan.Conflicts = append(plan.Conflicts, IndexConflict{
+					Name:     desired.name,
+					Desired:  eachDefine,
+					Existing: existing.document,
+					Reason:   reason,
+				})
+				continue
+			}
+			plan.Unchanged = append(plan.Unchanged, desired.name)
+			continue
+		}
+		existing = findIndexSpec(existingSpecList, func(s *indexSpec) bool {
+			return s.keys == desired.keys
+		})
+		if existing != nil {
+			matched[existing.name] = true
+			plan.Conflicts = append(plan.Conflicts, IndexConflict{
+				Name:     desired.name,
+				Desired:  eachDefine,
+				Existing: existing.document,
+				Reason:   fmt.Sprintf("index with the same keys already exists as %s", existing.name),
+			})
+			continue
+		}
+		plan.Create = append(plan.Create, eachDefine)
+	}
+	for _, eachExisting := range existingSpecList {
+		if matched[eachExisting.name] {
+			continue
+		}
+		plan.Unmanaged = append(plan.Unmanaged, eachExisting.name)
+		if o.dropUnmanaged {
+			plan.Drop = append(plan.Drop, eachExisting.name)
+		}
+	}
+	return plan
+}
+
+func removeNilIndexDefines(defineList []*EntityIndexDefine) []*EntityIndexDefine {
+	result := make([]*EntityIndexDefine, 0, len(defineList))
+	for _, eachDefine := range defineList {
+		if eachDefine != nil {
+			result = append(result, eachDefine)
+		}
+	}
+	return result
+}
+
+func findIndexSpec(specList []*indexSpec, match func(*indexSpec) bool) *indexSpec {
+	for _, eachSpec := range specList {
+		if match(eachSpec) {
+			return eachSpec
+		}
+	}
+	return nil
+}
+
+// normalized index definition used for comparison
+type indexSpec struct {
+	name    string
+	keys    string
+	options map[string]interface{}
+
+	document bson.M
+}
+
+func newIndexSpecFromDocument(doc bson.D) *indexSpec {
+	spec := &indexSpec{
+		options:  make(map[string]interface{}),
+		document: make(bson.M),
+	}
+	for _, eachElement := range doc {
+		spec.document[eachElement.Key] = eachElement.Value
+		switch eachElement.Key {
+		case "name":
+			spec.name, _ = eachElement.Value.(string)
+		case "key":
+			keys, _ := eachElement.Value.(bson.D)
+			spec.keys = indexKeysString(keys)
+		case "v", "ns":
+		default:
+			spec.options[eachElement.Key] = normalizeBsonValue(eachElement.Value)
+		}
+	}
+	return spec
+}
+
+func (d *EntityIndexDefine) indexSpec() *indexSpec {
+	spec := &indexSpec{
+		name:    d.GetName(),
+		options: make(map[string]interface{}),
+	}
+	keys := bson.D{}
+	weights := bson.M{}
+	hasText := false
+	for _, eachField := range d.FieldList {
+		if eachField.IndexType == IndexTypeText {
+			weights[eachField.FieldName] = int32(1)
+			if weight, ok := d.Weights[eachField.FieldName]; ok {
+				weights[eachField.FieldName] = weight
+			}
+			if !hasText {
+				//the server stores all text keys as _fts/_ftsx
+				keys = append(keys, bson.E{Key: "_fts", Value: "text"}, bson.E{Key: "_ftsx", Value: 1})
+				hasText = true
+			}
+			continue
+		}
+		keys = append(keys, bson.E{Key: eachField.FieldName, Value: eachField.keyValue()})
+	}
+	spec.keys = indexKeysString(keys)
+	if hasText {
+		spec.options["weights"] = normalizeBsonValue(weights)
+	}
+	if d.Unique {
+		spec.options["unique"] = true
+	}
+	if d.Sparse {
+		spec.options["sparse"] = true
+	}
+	if d.ExpireAfterSeconds != nil {
+		spec.options["expireAfterSeconds"] = normalizeBsonValue(*d.ExpireAfterSeconds)
+	}
+	if d.PartialFilterExpression != nil {
+		spec.options["partialFilterExpression"] = normalizeBsonValue(d.PartialFilterExpression)
+	}
+	if d.Collation != nil {
+		collation := bson.D{}
+		if err := bson.Unmarshal(d.Collation.ToDocument(), &collation); err == nil {
+			spec.options["collation"] = normalizeBsonValue(collation)
+		}
+	}
+	if d.Hidden {
+		spec.options["hidden"] = true
+	}
+	if len(d.DefaultLanguage) > 0 {
+		spec.options["default_language"] = d.DefaultLanguage
+	}
+	if len(d.WildcardProjection) > 0 {
+		spec.options["wildcardProjection"] = normalizeBsonValue(d.WildcardProjection)
+	}
+	return spec
+}
+
+// describe the difference between the desired spec and the existing one,empty if they are the same
+func (s *indexSpec) diff(existing *indexSpec) string {
+	if s.keys != existing.keys {
+		return fmt.Sprintf("keys differ,desired:{%s},existing:{%s}", s.keys, existing.keys)
+	}
+	for eachKey, eachValue := range s.options {
+		existingValue, ok := existing.options[eachKey]
+		if !ok {
+			if eachValue == false {
+				continue
+			}
+			return fmt.Sprintf("%s is missing on the existing index", eachKey)
+		}
+		if !isIndexOptionEqual(eachKey, eachValue, existingValue) {
+			return fmt.Sprintf("%s differs,desired:%v,existing:%v", eachKey, eachValue, existingValue)
+		}
+	}
+	for _, eachKey := range _strictIndexOptionKeys {
+		if _, ok := s.options[eachKey]; ok {
+			continue
+		}
+		existingValue, ok := existing.options[eachKey]
+		if !ok || existingValue == false {
+			continue
+		}
+		return fmt.Sprintf("existing index has %s:%v which is not declared", eachKey, existingValue)
+	}
+	return ""
+}
+
+func indexKeysString(keys bson.D) string {
+	partList := make([]string, 0, len(keys))
+	for _, eachKey := range keys {
+		partList = append(partList, fmt.Sprintf("%s:%v", eachKey.Key, normalizeBsonValue(eachKey.Value)))
+	}
+	return strings.Join(partList, ",")
+}
+
+// convert the value into a comparable form,numbers become float64 and documents become maps
+func normalizeBsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case int:
+		return float64(value)
+	case int32:
+		return float64(value)
+	case int64:
+		return float64(value)
+	case float32:
+		return float64(value)
+	case bson.D:
+		result := make(map[string]interface{}, len(value))
+		for _, eachElement := range value {
+			result[eachElement.Key] = normalizeBsonValue(eachElement.Value)
+		}
+		return result
+	case map[string]int32:
+		result := make(map[string]interface{}, len(value))
+		for eachKey, eachValue := range value {
+			result[eachKey] = float64(eachValue)
+		}
+		return result
+	case map[string]interface{}:
+		return normalizeBsonValue(bson.M(value))
+	case bson.M:
+		result := make(map[string]interface{}, len(value))
+		for eachKey, eachValue := range value {
+			result[eachKey] = normalizeBsonValue(eachValue)
+		}
+		return result
+	case primitive.A:
+		result := make([]interface{}, 0, len(value))
+		for _, eachValue := range value {
+			result = append(result, normalizeBsonValue(eachValue))
+		}
+		return result
+	case []interface{}:
+		return normalizeBsonValue(primitive.A(value))
+	}
+	return v
+}
+
+func isIndexOptionEqual(key string, desired interface{}, existing interface{}) bool {
+	if key == "collation" {
+		return isBsonValueSubset(desired, existing)
+	}
+	return reflect.DeepEqual(desired, existing)
+}
+
+// whether every field of desired exists in existing with the same value,
+// the server fills in defaults for documents such as collation
+func isBsonValueSubset(desired interface{}, existing interface{}) bool {
+	desiredMap, ok := desired.(map[string]interface{})
+	if !ok {
+		return reflect.DeepEqual(desired, existing)
+	}
+	existingMap, ok := existing.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	for eachKey, eachValue := range desiredMap {
+		if !isBsonValueSubset(eachValue, existingMap[eachKey]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package mongodbr
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// an index document as returned by listIndexes
+func newIndexEnsureTestDocument(name string, keys bson.D, options ...bson.E) bson.D {
+	document := bson.D{
+		{Key: "v", Value: int32(2)},
+		{Key: "key", Value: keys},
+		{Key: "name", Value: name},
+	}
+	return append(document, options...)
+}
+
+func TestBuildIndexPlan(t *testing.T) {
+	idIndex := newIndexEnsureTestDocument("_id_", bson.D{{Key: "_id", Value: int32(1)}})
+	nameIndex := NewEntityIndexDefine().AddField("name", true)
+	emailIndex := NewEntityIndexDefine().AddField("email", true).WithUnique(true)
+	sessionIndex := NewEntityIndexDefine().AddField("createdAt", true).WithExpireAfterSeconds(3600)
+	testCases := []struct {
+		name          string
+		defineList    []*EntityIndexDefine
+		existingList  []bson.D
+		dropUnmanaged bool
+		expect        IndexPlan
+		conflicts     []string
+	}{
+		{
+			name:         "create",
+			defineList:   []*EntityIndexDefine{nameIndex, emailIndex},
+			existingList: []bson.D{idIndex},
+			expect:       IndexPlan{Create: []*EntityIndexDefine{nameIndex, emailIndex}},
+		},
+		{
+			name:       "unchanged",
+			defineList: []*EntityIndexDefine{nameIndex, emailIndex, sessionIndex},
+			existingList: []bson.D{
+				idIndex,
+				newIndexEnsureTestDocument("name_1", bson.D{{Key: "name", Value: int32(1)}}),
+				newIndexEnsureTestDocument("email_1", bson.D{{Key: "email", Value: int32(1)}}, bson.E{Key: "unique", Value: true}),
+				//the server may return the ttl as a double
+				newIndexEnsureTestDocument("createdAt_1", bson.D{{Key: "createdAt", Value: int32(1)}}, bson.E{Key: "expireAfterSeconds", Value: float64(3600)}),
+			},
+			expect: IndexPlan{Unchanged: []string{"name_1", "email_1", "createdAt_1"}},
+		},
+		{
+			name:       "option differs",
+			defineList: []*EntityIndexDefine{emailIndex, sessionIndex},
+			existingList: []bson.D{
+				newIndexEnsureTestDocument("email_1", bson.D{{Key: "email", Value: int32(1)}}),
+				newIndexEnsureTestDocument("createdAt_1", bson.D{{Key: "createdAt", Value: int32(1)}}, bson.E{Key: "expireAfterSeconds", Value: int32(60)}),
+			},
+			conflicts: []string{"email_1", "createdAt_1"},
+		},
+		{
+			name:       "undeclared sparse",
+			defineList: []*EntityIndexDefine{nameIndex},
+			existingList: []bson.D{
+				newIndexEnsureTestDocument("name_1", bson.D{{Key: "name", Value: int32(1)}}, bson.E{Key: "sparse", Value: true}),
+			},
+			conflicts: []string{"name_1"},
+		},
+		{
+			name:       "same keys with another name",
+			defineList: []*EntityIndexDefine{nameIndex},
+			existingList: []bson.D{
+				newIndexEnsureTestDocument("by_name", bson.D{{Key: "name", Value: int32(1)}}),
+			},
+			conflicts: []string{"name_1"},
+		},
+		{
+			name:       "unmanaged",
+			defineList: []*EntityIndexDefine{nameIndex},
+			existingList: []bson.D{
+				idIndex,
+				newIndexEnsureTestDocument("legacy_1", bson.D{{Key: "legacy", Value: int32(1)}}),
+			},
+			expect: IndexPlan{Create: []*EntityIndexDefine{nameIndex}, Unmanaged: []string{"legacy_1"}},
+		},
+		{
+			name:       "drop unmanaged",
+			defineList: []*EntityIndexDefine{nameIndex},
+			existingList: []bson.D{
+				idIndex,
+				newIndexEnsureTestDocument("name_1", bson.D{{Key: "name", Value: int32(1)}}),
+				newIndexEnsureTestDocument("legacy_1", bson.D{{Key: "legacy", Value: int32(1)}}),
+			},
+			dropUnmanaged: true,
+			expect:        IndexPlan{Unchanged: []string{"name_1"}, Unmanaged: []string{"legacy_1"}, Drop: []string{"legacy_1"}},
+		},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			plan := buildIndexPlan(eachCase.defineList, eachCase.existingList, &ensureIndexesOptions{dropUnmanaged: eachCase.dropUnmanaged})
+			conflicts := make([]string, 0)
+			for _, eachConflict := range plan.Conflicts {
+				conflicts = append(conflicts, eachConflict.Name)
+			}
+			if len(eachCase.conflicts) <= 0 {
+				eachCase.conflicts = []string{}
+			}
+			if !reflect.DeepEqual(conflicts, eachCase.conflicts) {
+				t.Fatalf("expect conflicts %v,got %v", eachCase.conflicts, plan.Conflicts)
+			}
+			plan.Conflicts = nil
+			if !reflect.DeepEqual(*plan, eachCase.expect) {
+				t.Fatalf("expect %+v,got %+v", eachCase.expect, *plan)
+			}
+		})
+	}
+}
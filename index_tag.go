@@ -0,0 +1,201 @@
+package mongodbr
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	indexDirective = "index"
+)
+
+// ParseIndexDefines parse index defines from the mongodbr tag of the struct fields.
+//
+// every index directive has the form index[:name][,option...],a field can declare
+// several indexes by separating directives with ';'. fields that use the same index
+// name are combined into one compound index.
+//
+//	unique      unique index
+//	sparse      sparse index
+//	desc        descending key,default is ascending
+//	text        text key
+//	2dsphere    2dsphere key
+//	hashed      hashed key
+//	hidden      hidden index
+//	order:n     position of the key in a compound index
+//	ttl:n       expireAfterSeconds of the index
+//
+//	type User struct {
+//		Email    string    `bson:"email" mongodbr:"index:email_uq,unique"`
+//		TenantId string    `bson:"tenantId" mongodbr:"index:tenant_name"`
+//		Name     string    `bson:"name" mongodbr:"index:tenant_name,order:2,desc"`
+//		ExpireAt time.Time `bson:"expireAt" mongodbr:"index,ttl:0"`
+//	}
+func ParseIndexDefines(v interface{}) ([]*EntityIndexDefine, error) {
+	t := reflect.TypeOf(v)
+	if t == nil || indirectType(t).Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot parse index defines from %T,a struct is required", v)
+	}
+	groupList := make([]*indexTagGroup, 0)
+	groupMap := make(map[string]*indexTagGroup)
+	var parseErr error
+	walkBsonFields(t, func(path string, field reflect.StructField) {
+		if parseErr != nil {
+			return
+		}
+		for _, eachDirective := range parseTagDirectives(field) {
+			if !isIndexDirective(eachDirective) {
+				continue
+			}
+			tagField, err := parseIndexDirective(path, eachDirective)
+			if err != nil {
+				parseErr = fmt.Errorf("invalid index tag on field %s: %w", field.Name, err)
+				return
+			}
+			groupKey := tagField.indexName
+			if len(groupKey) <= 0 {
+				//unnamed index is always a single field index
+				groupKey = "#" + path + "#" + strconv.Itoa(len(groupList))
+			}
+			group, ok := groupMap[groupKey]
+			if !ok {
+				group = &indexTagGroup{
+					define: NewEntityIndexDefine().WithName(tagField.indexName),
+				}
+				groupMap[groupKey] = group
+				groupList = append(groupList, group)
+			}
+			if err := group.merge(tagField); err != nil {
+				parseErr = fmt.Errorf("invalid index tag on field %s: %w", field.Name, err)
+				return
+			}
+		}
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	defineList := make([]*EntityIndexDefine, 0, len(groupList))
+	for _, eachGroup := range groupList {
+		defineList = append(defineList, eachGroup.toDefine())
+	}
+	return defineList, nil
+}
+
+// parse index defines,panic if the tags are invalid
+func MustParseIndexDefines(v interface{}) []*EntityIndexDefine {
+	defineList, err := ParseIndexDefines(v)
+	if err != nil {
+		panic(err)
+	}
+	return defineList
+}
+
+type indexTagField struct {
+	indexName string
+	field     IndexFieldDefine
+	order     int
+	seq       int
+
+	unique             bool
+	sparse             bool
+	hidden             bool
+	expireAfterSeconds *int32
+}
+
+type indexTagGroup struct {
+	define    *EntityIndexDefine
+	fieldList []indexTagField
+}
+
+func (g *indexTagGroup) merge(f indexTagField) error {
+	f.seq = len(g.fieldList)
+	g.fieldList = append(g.fieldList, f)
+	if f.unique {
+		g.define.Unique = true
+	}
+	if f.sparse {
+		g.define.Sparse = true
+	}
+	if f.hidden {
+		g.define.Hidden = true
+	}
+	if f.expireAfterSeconds != nil {
+		if g.define.ExpireAfterSeconds != nil && *g.define.ExpireAfterSeconds != *f.expireAfterSeconds {
+			return fmt.Errorf("index %s has different ttl values", f.indexName)
+		}
+		g.define.ExpireAfterSeconds = f.expireAfterSeconds
+	}
+	return nil
+}
+
+func (g *indexTagGroup) toDefine() *EntityIndexDefine {
+	sort.SliceStable(g.fieldList, func(i, j int) bool {
+		if g.fieldList[i].order != g.fieldList[j].order {
+			return g.fieldList[i].order < g.fieldList[j].order
+		}
+		return g.fieldList[i].seq < g.fieldList[j].seq
+	})
+	for _, eachField := range g.fieldList {
+		g.define.FieldList = append(g.define.FieldList, eachField.field)
+	}
+	return g.define
+}
+
+func isIndexDirective(directive string) bool {
+	head := strings.SplitN(directive, ",", 2)[0]
+	return head == indexDirective || strings.HasPrefix(head, indexDirective+":")
+}
+
+func parseIndexDirective(path string, directive string) (indexTagField, error) {
+	result := indexTagField{
+		field: IndexFieldDefine{
+			FieldName: path,
+			IsAsc:     true,
+		},
+	}
+	partList := strings.Split(directive, ",")
+	if name := strings.TrimPrefix(partList[0], indexDirective); len(name) > 0 {
+		result.indexName = strings.TrimPrefix(name, ":")
+	}
+	for _, eachPart := range partList[1:] {
+		key, value, _ := strings.Cut(strings.TrimSpace(eachPart), ":")
+		switch key {
+		case "unique":
+			result.unique = true
+		case "sparse":
+			result.sparse = true
+		case "desc":
+			result.field.IsAsc = false
+		case "asc":
+			result.field.IsAsc = true
+		case "text":
+			result.field.IndexType = IndexTypeText
+		case "2dsphere":
+			result.field.IndexType = IndexType2DSphere
+		case "hashed":
+			result.field.IndexType = IndexTypeHashed
+		case "hidden":
+			result.hidden = true
+		case "order":
+			order, err := strconv.Atoi(value)
+			if err != nil {
+				return result, fmt.Errorf("invalid order %q", value)
+			}
+			result.order = order
+		case "ttl":
+			seconds, err := strconv.ParseInt(value, 10, 32)
+			if err != nil {
+				return result, fmt.Errorf("invalid ttl %q", value)
+			}
+			ttl := int32(seconds)
+			result.expireAfterSeconds = &ttl
+		default:
+			return result, fmt.Errorf("unknown index option %q", key)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package mongodbr
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type indexTagTestUser struct {
+	Email    string    `bson:"email" mongodbr:"index:email_uq,unique"`
+	TenantId string    `bson:"tenantId" mongodbr:"index:tenant_name"`
+	Name     string    `bson:"name" mongodbr:"index:tenant_name,order:2,desc"`
+	Phone    string    `bson:"phone" mongodbr:"index,sparse;index:phone_hashed,hashed"`
+	ExpireAt time.Time `bson:"expireAt" mongodbr:"index,ttl:3600"`
+	Address  struct {
+		City string `bson:"city" mongodbr:"index"`
+	} `bson:"address"`
+	Ignored string `bson:"ignored"`
+}
+
+type indexTagTestConflictTTL struct {
+	A time.Time `bson:"a" mongodbr:"index:ab,ttl:60"`
+	B time.Time `bson:"b" mongodbr:"index:ab,ttl:120"`
+}
+
+type indexTagTestUnknownOption struct {
+	A string `bson:"a" mongodbr:"index,clustered"`
+}
+
+type indexTagTestInvalidTTL struct {
+	A time.Time `bson:"a" mongodbr:"index,ttl:day"`
+}
+
+func TestParseIndexDefines(t *testing.T) {
+	ttl := int32(3600)
+	userDefineList := []*EntityIndexDefine{
+		{Name: "email_uq", FieldList: []IndexFieldDefine{{FieldName: "email", IsAsc: true}}, Unique: true},
+		{Name: "tenant_name", FieldList: []IndexFieldDefine{{FieldName: "tenantId", IsAsc: true}, {FieldName: "name", IsAsc: false}}},
+		{FieldList: []IndexFieldDefine{{FieldName: "phone", IsAsc: true}}, Sparse: true},
+		{Name: "phone_hashed", FieldList: []IndexFieldDefine{{FieldName: "phone", IsAsc: true, IndexType: IndexTypeHashed}}},
+		{FieldList: []IndexFieldDefine{{FieldName: "expireAt", IsAsc: true}}, ExpireAfterSeconds: &ttl},
+		{FieldList: []IndexFieldDefine{{FieldName: "address.city", IsAsc: true}}},
+	}
+	testCases := []struct {
+		name    string
+		v       interface{}
+		expect  []*EntityIndexDefine
+		wantErr bool
+	}{
+		{"struct", indexTagTestUser{}, userDefineList, false},
+		{"pointer", &indexTagTestUser{}, userDefineList, false},
+		{"compound with different ttl", indexTagTestConflictTTL{}, nil, true},
+		{"unknown option", indexTagTestUnknownOption{}, nil, true},
+		{"invalid ttl", indexTagTestInvalidTTL{}, nil, true},
+		{"not a struct", "user", nil, true},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			defineList, err := ParseIndexDefines(eachCase.v)
+			if eachCase.wantErr {
+				if err == nil {
+					t.Fatalf("expect an error,got %v", defineList)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(defineList, eachCase.expect) {
+				t.Fatalf("expect %+v,got %+v", eachCase.expect, defineList)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package mongodbr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidIndexDefine = errors.New("invalid index define")
+
+	// operators supported by partialFilterExpression
+	_partialFilterOperators = map[string]bool{
+		"$eq":     true,
+		"$exists": true,
+		"$gt":     true,
+		"$gte":    true,
+		"$lt":     true,
+		"$lte":    true,
+		"$type":   true,
+		"$in":     true,
+	}
+	_partialFilterLogicalOperators = map[string]bool{
+		"$and": true,
+		"$or":  true,
+	}
+)
+
+// check the define against the restrictions of the server
+func (d *EntityIndexDefine) Validate() error {
+	if len(d.FieldList) <= 0 {
+		return d.invalid("at least one field is required")
+	}
+	fieldNames := make(map[string]bool)
+	countByType := make(map[IndexType]int)
+	for _, eachField := range d.FieldList {
+		if len(eachField.FieldName) <= 0 {
+			return d.invalid("field name cannot be empty")
+		}
+		if fieldNames[eachField.FieldName] {
+			return d.invalid(fmt.Sprintf("field %s is declared more than once", eachField.FieldName))
+		}
+		fieldNames[eachField.FieldName] = true
+		countByType[eachField.IndexType]++
+
+		switch eachField.IndexType {
+		case IndexTypeOrdinal, IndexTypeText, IndexType2DSphere, IndexTypeHashed:
+		case IndexTypeWildcard:
+			if eachField.FieldName != wildcardFieldName && !strings.HasSuffix(eachField.FieldName, "."+wildcardFieldName) {
+				return d.invalid(fmt.Sprintf("wildcard field %s must end with %s", eachField.FieldName, wildcardFieldName))
+			}
+		default:
+			return d.invalid(fmt.Sprintf("unknown index type %s", eachField.IndexType))
+		}
+	}
+
+	isCompound := len(d.FieldList) > 1
+	if countByType[IndexTypeHashed] > 1 {
+		return d.invalid("only one hashed field is allowed")
+	}
+	if countByType[IndexTypeHashed] > 0 && d.Unique {
+		return d.invalid("hashed index cannot be unique")
+	}
+	if countByType[IndexTypeWildcard] > 0 {
+		if isCompound {
+			return d.invalid("wildcard index cannot be compound")
+		}
+		if d.Unique || d.ExpireAfterSeconds != nil {
+			return d.invalid("wildcard index cannot be unique or ttl")
+		}
+	}
+	if len(d.WildcardProjection) > 0 {
+		if countByType[IndexTypeWildcard] <= 0 || d.FieldList[0].FieldName != wildcardFieldName {
+			return d.invalid("wildcardProjection is only valid for a $** index")
+		}
+		if err := d.validateWildcardProjection(); err != nil {
+			return err
+		}
+	}
+
+	hasText := countByType[IndexTypeText] > 0
+	if len(d.Weights) > 0 || len(d.DefaultLanguage) > 0 {
+		if !hasText {
+			return d.invalid("weights and default_language are only valid for a text index")
+		}
+	}
+	for eachFieldName, eachWeight := range d.Weights {
+		if eachFieldName != wildcardFieldName && !fieldNames[eachFieldName] {
+			return d.invalid(fmt.Sprintf("weight of %s is declared but the field is not a text key", eachFieldName))
+		}
+		if eachWeight < 1 || eachWeight > 99999 {
+			return d.invalid(fmt.Sprintf("weight of %s must be between 1 and 99999", eachFieldName))
+		}
+	}
+	if hasText && d.Collation != nil && d.Collation.Locale != "simple" {
+		return d.invalid("text index only supports simple collation")
+	}
+
+	if d.ExpireAfterSeconds != nil {
+		if isCompound {
+			return d.invalid("ttl index must be a single field index")
+		}
+		if d.FieldList[0].FieldName == "_id" {
+			return d.invalid("ttl index cannot be created on _id")
+		}
+		if *d.ExpireAfterSeconds < 0 {
+			return d.invalid("expireAfterSeconds cannot be negative")
+		}
+	}
+	if d.Hidden && d.GetName() == _idIndexName {
+		return d.invalid("_id index cannot be hidden")
+	}
+	if d.Collation != nil && len(d.Collation.Locale) <= 0 {
+		return d.invalid("collation locale is required")
+	}
+	if d.PartialFilterExpression != nil {
+		if d.Sparse {
+			return d.invalid("sparse cannot be combined with partialFilterExpression")
+		}
+		if err := d.validatePartialFilter(normalizeBsonValue(d.PartialFilterExpression), 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *EntityIndexDefine) validateWildcardProjection() error {
+	hasInclusion, hasExclusion := false, false
+	for eachPath, eachValue := range d.WildcardProjection {
+		if eachPath == "_id" {
+			continue
+		}
+		switch eachValue {
+		case 0:
+			hasExclusion = true
+		case 1:
+			hasInclusion = true
+		default:
+			return d.invalid(fmt.Sprintf("wildcardProjection value of %s must be 0 or 1", eachPath))
+		}
+	}
+	if hasInclusion && hasExclusion {
+		return d.invalid("wildcardProjection cannot mix inclusion and exclusion")
+	}
+	return nil
+}
+
+func (d *EntityIndexDefine) validatePartialFilter(filter interface{}, depth int) error {
+	filterMap, ok := filter.(map[string]interface{})
+	if !ok {
+		return d.invalid("partialFilterExpression must be a document")
+	}
+	for eachKey, eachValue := range filterMap {
+		if strings.HasPrefix(eachKey, "$") {
+			if !_partialFilterLogicalOperators[eachKey] || depth > 0 {
+				return d.invalid(fmt.Sprintf("operator %s is not supported in partialFilterExpression", eachKey))
+			}
+			list, ok := eachValue.([]interface{})
+			if !ok {
+				return d.invalid(fmt.Sprintf("%s requires an array", eachKey))
+			}
+			for _, eachItem := range list {
+				if err := d.validatePartialFilter(eachItem, depth+1); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		condition, ok := eachValue.(map[string]interface{})
+		if !ok {
+			//equality
+			continue
+		}
+		for eachOp, eachOpValue := range condition {
+			if !strings.HasPrefix(eachOp, "$") {
+				//equality with an embedded document
+				break
+			}
+			if !_partialFilterOperators[eachOp] {
+				return d.invalid(fmt.Sprintf("operator %s on %s is not supported in partialFilterExpression", eachOp, eachKey))
+			}
+			if eachOp == "$exists" && eachOpValue != true {
+				return d.invalid("only $exists:true is supported in partialFilterExpression")
+			}
+		}
+	}
+	return nil
+}
+
+// validate model as the define it is built from,the models with the key types or options not
+// described by EntityIndexDefine such as 2d are sent to the server as they are
+func validateIndexModel(model mongo.IndexModel) error {
+	define, ok := newEntityIndexDefineFromModel(model)
+	if !ok {
+		return nil
+	}
+	return define.Validate()
+}
+
+func newEntityIndexDefineFromModel(model mongo.IndexModel) (*EntityIndexDefine, bool) {
+	keys, ok := toBsonD(model.Keys)
+	if !ok {
+		return nil, false
+	}
+	define := NewEntityIndexDefine()
+	for _, eachKey := range keys {
+		field := IndexFieldDefine{FieldName: eachKey.Key}
+		switch value := normalizeBsonValue(eachKey.Value).(type) {
+		case float64:
+			field.IsAsc = value >= 0
+		case string:
+			field.IndexType = IndexType(value)
+			switch field.IndexType {
+			case IndexTypeText, IndexType2DSphere, IndexTypeHashed:
+			default:
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+		if eachKey.Key == wildcardFieldName || strings.HasSuffix(eachKey.Key, "."+wildcardFieldName) {
+			field.IndexType = IndexTypeWildcard
+		}
+		define.FieldList = append(define.FieldList, field)
+	}
+
+	o := model.Options
+	if o == nil {
+		return define, true
+	}
+	if o.Name != nil {
+		define.Name = *o.Name
+	}
+	define.Unique = o.Unique != nil && *o.Unique
+	define.Sparse = o.Sparse != nil && *o.Sparse
+	define.Hidden = o.Hidden != nil && *o.Hidden
+	define.ExpireAfterSeconds = o.ExpireAfterSeconds
+	define.PartialFilterExpression = o.PartialFilterExpression
+	define.Collation = o.Collation
+	if o.DefaultLanguage != nil {
+		define.DefaultLanguage = *o.DefaultLanguage
+	}
+	if o.Weights != nil {
+		if define.Weights, ok = toInt32Map(o.Weights); !ok {
+			return nil, false
+		}
+	}
+	if o.WildcardProjection != nil {
+		if define.WildcardProjection, ok = toInt32Map(o.WildcardProjection); !ok {
+			return nil, false
+		}
+	}
+	return define, true
+}
+
+// map of the numeric values of a document such as the weights
+func toInt32Map(v interface{}) (map[string]int32, bool) {
+	if m, ok := v.(map[string]int32); ok {
+		return m, true
+	}
+	document, ok := toBsonD(v)
+	if !ok {
+		return nil, false
+	}
+	m := make(map[string]int32, len(document))
+	for _, eachElement := range document {
+		switch value := normalizeBsonValue(eachElement.Value).(type) {
+		case float64:
+			m[eachElement.Key] = int32(value)
+		default:
+			return nil, false
+		}
+	}
+	return m, true
+}
+
+func (d *EntityIndexDefine) invalid(message string) error {
+	return fmt.Errorf("%w %s: %s", ErrInvalidIndexDefine, d.GetName(), message)
+}
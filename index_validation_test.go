@@ -0,0 +1,82 @@
+package mongodbr
+
+import (
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestEntityIndexDefineValidate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		define *EntityIndexDefine
+		valid  bool
+	}{
+		{"compound", NewEntityIndexDefine().AddField("a", true).AddField("b", false).WithUnique(true), true},
+		{"no field", NewEntityIndexDefine(), false},
+		{"duplicate field", NewEntityIndexDefine().AddField("a", true).AddField("a", false), false},
+		{"two hashed fields", NewEntityIndexDefine().AddHashedField("a").AddHashedField("b"), false},
+		{"unique hashed", NewEntityIndexDefine().AddHashedField("a").WithUnique(true), false},
+		{"wildcard", NewEntityIndexDefine().AddWildcardField("attributes"), true},
+		{"compound wildcard", NewEntityIndexDefine().AddWildcardField("").AddField("a", true), false},
+		{"wildcard projection", NewEntityIndexDefine().AddWildcardField("").WithWildcardProjection("a", true), true},
+		{"wildcard projection on a path", NewEntityIndexDefine().AddWildcardField("a").WithWildcardProjection("b", true), false},
+		{"weighted text", NewEntityIndexDefine().AddWeightedTextField("title", 10), true},
+		{"weight out of range", NewEntityIndexDefine().AddWeightedTextField("title", 100000), false},
+		{"language without text", NewEntityIndexDefine().AddField("a", true).WithDefaultLanguage("english"), false},
+		{"ttl", NewEntityIndexDefine().AddField("createdAt", true).WithExpireAfterSeconds(60), true},
+		{"compound ttl", NewEntityIndexDefine().AddField("a", true).AddField("b", true).WithExpireAfterSeconds(60), false},
+		{"ttl on _id", NewEntityIndexDefine().AddField("_id", true).WithExpireAfterSeconds(60), false},
+		{"negative ttl", NewEntityIndexDefine().AddField("a", true).WithExpireAfterSeconds(-1), false},
+		{"collation without locale", NewEntityIndexDefine().AddField("a", true).WithCollation(&options.Collation{}), false},
+		{"partial filter", NewEntityIndexDefine().AddField("a", true).WithPartialFilterExpression(bson.M{"a": bson.M{"$exists": true}}), true},
+		{"sparse partial filter", NewEntityIndexDefine().AddField("a", true).WithSparse(true).WithPartialFilterExpression(bson.M{"a": 1}), false},
+		{"partial filter $ne", NewEntityIndexDefine().AddField("a", true).WithPartialFilterExpression(bson.M{"a": bson.M{"$ne": 1}}), false},
+		{"partial filter $exists false", NewEntityIndexDefine().AddField("a", true).WithPartialFilterExpression(bson.M{"a": bson.M{"$exists": false}}), false},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			err := eachCase.define.Validate()
+			if eachCase.valid && err != nil {
+				t.Fatalf("expect valid,got %v", err)
+			}
+			if !eachCase.valid && !errors.Is(err, ErrInvalidIndexDefine) {
+				t.Fatalf("expect ErrInvalidIndexDefine,got %v", err)
+			}
+			if (eachCase.define.ToIndexModel() != nil) != eachCase.valid {
+				t.Fatal("ToIndexModel must return nil for an invalid define only")
+			}
+		})
+	}
+}
+
+func TestValidateIndexModel(t *testing.T) {
+	testCases := []struct {
+		name  string
+		model mongo.IndexModel
+		valid bool
+	}{
+		{"ordinal", mongo.IndexModel{Keys: bson.D{{Key: "a", Value: 1}, {Key: "b", Value: -1}}}, true},
+		{"ttl", mongo.IndexModel{Keys: bson.M{"a": 1}, Options: options.Index().SetExpireAfterSeconds(60)}, true},
+		{"compound ttl", mongo.IndexModel{Keys: bson.D{{Key: "a", Value: 1}, {Key: "b", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(60)}, false},
+		{"unique hashed", mongo.IndexModel{Keys: bson.D{{Key: "a", Value: "hashed"}}, Options: options.Index().SetUnique(true)}, false},
+		{"text weights", mongo.IndexModel{Keys: bson.D{{Key: "title", Value: "text"}}, Options: options.Index().SetWeights(bson.M{"title": 10})}, true},
+		{"weights without text", mongo.IndexModel{Keys: bson.D{{Key: "title", Value: 1}}, Options: options.Index().SetWeights(bson.M{"title": 10})}, false},
+		{"wildcard", mongo.IndexModel{Keys: bson.D{{Key: "$**", Value: 1}}, Options: options.Index().SetWildcardProjection(bson.D{{Key: "a", Value: 1}})}, true},
+		{"2d is not described", mongo.IndexModel{Keys: bson.D{{Key: "location", Value: "2d"}}, Options: options.Index().SetExpireAfterSeconds(-1)}, true},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			err := validateIndexModel(eachCase.model)
+			if eachCase.valid && err != nil {
+				t.Fatalf("expect valid,got %v", err)
+			}
+			if !eachCase.valid && !errors.Is(err, ErrInvalidIndexDefine) {
+				t.Fatalf("expect ErrInvalidIndexDefine,got %v", err)
+			}
+		})
+	}
+}
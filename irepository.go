@@ -14,6 +14,7 @@ type IRepository interface {
 	IEntityDelete
 	IEntityIndex
 	IEntityBulkWrite
+	IEntityWatch
 
 	// aggregate
 	Aggregate(pipeline interface{}, dataList interface{}, opts ...AggregateOption) (err error)
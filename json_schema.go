@@ -0,0 +1,417 @@
+package mongodbr
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	ValidationLevelOff      = "off"
+	ValidationLevelStrict   = "strict"
+	ValidationLevelModerate = "moderate"
+
+	ValidationActionError = "error"
+	ValidationActionWarn  = "warn"
+
+	// go-playground style validation tag
+	validateTagName = "validate"
+
+	emailPattern = `^[^@\s]+@[^@\s]+\.[^@\s]+$`
+)
+
+var (
+	_dateTimeType   = reflect.TypeOf(primitive.DateTime(0))
+	_decimal128Type = reflect.TypeOf(primitive.Decimal128{})
+	_binaryType     = reflect.TypeOf(primitive.Binary{})
+	_bsonMType      = reflect.TypeOf(bson.M{})
+	_bsonDType      = reflect.TypeOf(bson.D{})
+	_bsonAType      = reflect.TypeOf(bson.A{})
+	_bsonEType      = reflect.TypeOf(bson.E{})
+	_timestampType  = reflect.TypeOf(primitive.Timestamp{})
+	_regexType      = reflect.TypeOf(primitive.Regex{})
+	_javaScriptType = reflect.TypeOf(primitive.JavaScript(""))
+	_minKeyType     = reflect.TypeOf(primitive.MinKey{})
+	_maxKeyType     = reflect.TypeOf(primitive.MaxKey{})
+
+	_marshalerType      = reflect.TypeOf((*bson.Marshaler)(nil)).Elem()
+	_valueMarshalerType = reflect.TypeOf((*bson.ValueMarshaler)(nil)).Elem()
+)
+
+type jsonSchemaOptions struct {
+	validationLevel  string
+	validationAction string
+	strict           bool
+}
+
+type JSONSchemaOption func(*jsonSchemaOptions)
+
+// validationLevel of the collection,default is strict
+func JSONSchemaWithValidationLevel(level string) JSONSchemaOption {
+	return func(o *jsonSchemaOptions) {
+		o.validationLevel = level
+	}
+}
+
+// validationAction of the collection,default is error
+func JSONSchemaWithValidationAction(action string) JSONSchemaOption {
+	return func(o *jsonSchemaOptions) {
+		o.validationAction = action
+	}
+}
+
+// reject the fields that are not declared in the struct
+func JSONSchemaWithStrictProperties() JSONSchemaOption {
+	return func(o *jsonSchemaOptions) {
+		o.strict = true
+	}
+}
+
+// generate a $jsonSchema document from the struct type of v.
+//
+// fields follow the bson tags,inline structs are merged into the parent,pointers are nullable,
+// time.Time is date,primitive.ObjectID is objectId and the types implementing bson.Marshaler or
+// bson.ValueMarshaler accept any value. the validate tag adds constraints:
+// required,min,max,len,gt,gte,lt,lte,oneof and email
+func GenerateJSONSchema(v interface{}, opts ...JSONSchemaOption) (bson.M, error) {
+	o := &jsonSchemaOptions{}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	t := reflect.TypeOf(v)
+	if t == nil || indirectType(t).Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot generate json schema from %T,a struct is required", v)
+	}
+	generator := &jsonSchemaGenerator{
+		options:  o,
+		visiting: make(map[reflect.Type]bool),
+	}
+	return generator.objectSchema(indirectType(t))
+}
+
+// the validator document {$jsonSchema:...} of v
+func NewJSONSchemaValidator(v interface{}, opts ...JSONSchemaOption) (bson.M, error) {
+	schema, err := GenerateJSONSchema(v, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return bson.M{"$jsonSchema": schema}, nil
+}
+
+// apply the $jsonSchema generated from v to the collection,the collection is created if not exists
+func ApplyJSONSchema(ctx context.Context, db *mongo.Database, collectionName string, v interface{}, opts ...JSONSchemaOption) error {
+	o := &jsonSchemaOptions{
+		validationLevel:  ValidationLevelStrict,
+		validationAction: ValidationActionError,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	validator, err := NewJSONSchemaValidator(v, opts...)
+	if err != nil {
+		return err
+	}
+	nameList, err := db.ListCollectionNames(ctx, bson.M{"name": collectionName})
+	if err != nil {
+		return err
+	}
+	if len(nameList) <= 0 {
+		return db.CreateCollection(ctx, collectionName, options.CreateCollection().
+			SetValidator(validator).
+			SetValidationLevel(o.validationLevel).
+			SetValidationAction(o.validationAction))
+	}
+	return db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: collectionName},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: o.validationLevel},
+		{Key: "validationAction", Value: o.validationAction},
+	}).Err()
+}
+
+type jsonSchemaGenerator struct {
+	options  *jsonSchemaOptions
+	visiting map[reflect.Type]bool
+}
+
+func (g *jsonSchemaGenerator) objectSchema(t reflect.Type) (bson.M, error) {
+	schema := bson.M{"bsonType": "object"}
+	if g.visiting[t] {
+		//recursive type,the nested levels are not described
+		return schema, nil
+	}
+	g.visiting[t] = true
+	defer delete(g.visiting, t)
+
+	properties := bson.M{}
+	required := make([]string, 0)
+	if err := g.collectProperties(t, properties, &required); err != nil {
+		return nil, err
+	}
+	if len(properties) > 0 {
+		schema["properties"] = properties
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	if g.options.strict {
+		schema["additionalProperties"] = false
+	}
+	return schema, nil
+}
+
+func (g *jsonSchemaGenerator) collectProperties(t reflect.Type, properties bson.M, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := parseBsonFieldTag(field)
+		if tag.skip {
+			continue
+		}
+		fieldType := indirectType(field.Type)
+		if tag.inline && fieldType.Kind() == reflect.Struct {
+			if err := g.collectProperties(fieldType, properties, required); err != nil {
+				return err
+			}
+			continue
+		}
+		fieldSchema, err := g.typeSchema(field.Type)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		isRequired, err := applyValidateTag(fieldSchema, fieldType, field.Tag.Get(validateTagName))
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		properties[tag.name] = fieldSchema
+		if isRequired {
+			*required = append(*required, tag.name)
+		}
+	}
+	return nil
+}
+
+func (g *jsonSchemaGenerator) typeSchema(t reflect.Type) (bson.M, error) {
+	if t.Kind() == reflect.Ptr {
+		schema, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullableSchema(schema), nil
+	}
+
+	switch t {
+	case _timeType, _dateTimeType:
+		return bson.M{"bsonType": "date"}, nil
+	case _objectIdType:
+		return bson.M{"bsonType": "objectId"}, nil
+	case _decimal128Type:
+		return bson.M{"bsonType": "decimal"}, nil
+	case _binaryType:
+		return bson.M{"bsonType": "binData"}, nil
+	case _bsonMType, _bsonDType:
+		//nil is encoded as null
+		return nullableSchema(bson.M{"bsonType": "object"}), nil
+	case _bsonAType:
+		return nullableSchema(bson.M{"bsonType": "array"}), nil
+	case _bsonEType:
+		return bson.M{}, nil
+	case _timestampType:
+		return bson.M{"bsonType": "timestamp"}, nil
+	case _regexType:
+		return bson.M{"bsonType": "regex"}, nil
+	case _javaScriptType:
+		return bson.M{"bsonType": "javascript"}, nil
+	case _minKeyType:
+		return bson.M{"bsonType": "minKey"}, nil
+	case _maxKeyType:
+		return bson.M{"bsonType": "maxKey"}, nil
+	}
+	if isBsonMarshaler(t) {
+		//the encoded type is only known by the value
+		return bson.M{}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return bson.M{"bsonType": "string"}, nil
+	case reflect.Bool:
+		return bson.M{"bsonType": "bool"}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return bson.M{"bsonType": "int"}, nil
+	case reflect.Int64:
+		return bson.M{"bsonType": "long"}, nil
+	case reflect.Int, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		//encoded as int32 if the value fits,otherwise int64
+		return bson.M{"bsonType": bson.A{"int", "long"}}, nil
+	case reflect.Float32, reflect.Float64:
+		return bson.M{"bsonType": "double"}, nil
+	case reflect.Interface:
+		return bson.M{}, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return nullableSchema(bson.M{"bsonType": "binData"}), nil
+		}
+		schema, err := g.arraySchema(t)
+		if err != nil {
+			return nil, err
+		}
+		//nil slices are encoded as null
+		return nullableSchema(schema), nil
+	case reflect.Array:
+		return g.arraySchema(t)
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type %s", t.Key())
+		}
+		schema := bson.M{"bsonType": "object"}
+		valueSchema, err := g.typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		if len(valueSchema) > 0 {
+			schema["additionalProperties"] = valueSchema
+		}
+		return nullableSchema(schema), nil
+	case reflect.Struct:
+		return g.objectSchema(t)
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// t encodes itself by MarshalBSON or MarshalBSONValue
+func isBsonMarshaler(t reflect.Type) bool {
+	for _, eachType := range []reflect.Type{t, reflect.PtrTo(t)} {
+		if eachType.Implements(_marshalerType) || eachType.Implements(_valueMarshalerType) {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *jsonSchemaGenerator) arraySchema(t reflect.Type) (bson.M, error) {
+	schema := bson.M{"bsonType": "array"}
+	itemSchema, err := g.typeSchema(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	if len(itemSchema) > 0 {
+		schema["items"] = itemSchema
+	}
+	return schema, nil
+}
+
+// allow null in addition to the bsonType of schema
+func nullableSchema(schema bson.M) bson.M {
+	switch bsonType := schema["bsonType"].(type) {
+	case string:
+		schema["bsonType"] = bson.A{bsonType, "null"}
+	case bson.A:
+		for _, eachType := range bsonType {
+			if eachType == "null" {
+				return schema
+			}
+		}
+		schema["bsonType"] = append(bsonType, "null")
+	}
+	return schema
+}
+
+// add the constraints of the validate tag to schema,return whether the field is required
+func applyValidateTag(schema bson.M, t reflect.Type, tag string) (bool, error) {
+	if len(tag) <= 0 || tag == "-" {
+		return false, nil
+	}
+	isRequired := false
+	for _, eachRule := range strings.Split(tag, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(eachRule), "=")
+		switch name {
+		case "required":
+			isRequired = true
+		case "omitempty", "":
+		case "min", "max", "len", "gt", "gte", "lt", "lte":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return false, fmt.Errorf("invalid validate rule %s", eachRule)
+			}
+			applyRangeRule(schema, t, name, number)
+		case "oneof":
+			enumList := bson.A{}
+			for _, eachValue := range strings.Fields(value) {
+				enumList = append(enumList, enumValue(t, eachValue))
+			}
+			schema["enum"] = enumList
+		case "email":
+			schema["pattern"] = emailPattern
+		}
+	}
+	return isRequired, nil
+}
+
+func applyRangeRule(schema bson.M, t reflect.Type, rule string, value float64) {
+	minKey, maxKey := "minimum", "maximum"
+	switch t.Kind() {
+	case reflect.String:
+		minKey, maxKey = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array:
+		minKey, maxKey = "minItems", "maxItems"
+	case reflect.Map:
+		minKey, maxKey = "minProperties", "maxProperties"
+	}
+	isNumber := minKey == "minimum"
+	switch rule {
+	case "min", "gte":
+		schema[minKey] = schemaNumber(value, isNumber)
+	case "max", "lte":
+		schema[maxKey] = schemaNumber(value, isNumber)
+	case "len":
+		schema[minKey] = schemaNumber(value, isNumber)
+		schema[maxKey] = schemaNumber(value, isNumber)
+	case "gt":
+		if isNumber {
+			schema[minKey] = value
+			schema["exclusiveMinimum"] = true
+		} else {
+			schema[minKey] = int64(value) + 1
+		}
+	case "lt":
+		if isNumber {
+			schema[maxKey] = value
+			schema["exclusiveMaximum"] = true
+		} else {
+			schema[maxKey] = int64(value) - 1
+		}
+	}
+}
+
+// lengths must be integers,numbers keep their value
+func schemaNumber(value float64, isNumber bool) interface{} {
+	if isNumber {
+		return value
+	}
+	return int64(value)
+}
+
+func enumValue(t reflect.Type, value string) interface{} {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if number, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return number
+		}
+	case reflect.Float32, reflect.Float64:
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+	}
+	return value
+}
@@ -0,0 +1,171 @@
+package mongodbr
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// encoded as a string
+type jsonSchemaTestStatus int
+
+func (s jsonSchemaTestStatus) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(fmt.Sprintf("status-%d", s))
+}
+
+type jsonSchemaTestAddress struct {
+	City string `bson:"city" validate:"required"`
+}
+
+type jsonSchemaTestEntity struct {
+	Id         primitive.ObjectID     `bson:"_id"`
+	Name       string                 `bson:"name" validate:"required"`
+	Count      int                    `bson:"count"`
+	Price      float64                `bson:"price"`
+	Tags       []string               `bson:"tags"`
+	Created    time.Time              `bson:"created"`
+	Address    *jsonSchemaTestAddress `bson:"address"`
+	Attributes bson.D                 `bson:"attributes"`
+	Extra      bson.M                 `bson:"extra"`
+	List       bson.A                 `bson:"list"`
+	Element    bson.E                 `bson:"element"`
+	Timestamp  primitive.Timestamp    `bson:"timestamp"`
+	Pattern    primitive.Regex        `bson:"pattern"`
+	Script     primitive.JavaScript   `bson:"script"`
+	Min        primitive.MinKey       `bson:"min"`
+	Max        primitive.MaxKey       `bson:"max"`
+	Status     jsonSchemaTestStatus   `bson:"status"`
+}
+
+func TestGenerateJSONSchemaMatchesEncodedDocuments(t *testing.T) {
+	schema, err := GenerateJSONSchema(jsonSchemaTestEntity{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		name   string
+		entity jsonSchemaTestEntity
+	}{
+		{"zero values", jsonSchemaTestEntity{Name: "a"}},
+		{
+			"all set",
+			jsonSchemaTestEntity{
+				Id:         primitive.NewObjectID(),
+				Name:       "a",
+				Count:      1 << 40,
+				Price:      1.5,
+				Tags:       []string{"x"},
+				Created:    time.Now(),
+				Address:    &jsonSchemaTestAddress{City: "c"},
+				Attributes: bson.D{{Key: "color", Value: "red"}},
+				Extra:      bson.M{"size": 1},
+				List:       bson.A{1, "x"},
+				Element:    bson.E{Key: "k", Value: "v"},
+				Timestamp:  primitive.Timestamp{T: 1, I: 1},
+				Pattern:    primitive.Regex{Pattern: "^a", Options: "i"},
+				Script:     "function() {}",
+				Status:     2,
+			},
+		},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			raw, err := bson.Marshal(eachCase.entity)
+			if err != nil {
+				t.Fatal(err)
+			}
+			value := bson.RawValue{Type: bsontype.EmbeddedDocument, Value: raw}
+			if err := matchJSONSchema(schema, value, "$"); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// the $jsonSchema aliases of the bson types
+var _jsonSchemaTestBsonTypes = map[bsontype.Type]string{
+	bsontype.Double:           "double",
+	bsontype.String:           "string",
+	bsontype.EmbeddedDocument: "object",
+	bsontype.Array:            "array",
+	bsontype.Binary:           "binData",
+	bsontype.ObjectID:         "objectId",
+	bsontype.Boolean:          "bool",
+	bsontype.DateTime:         "date",
+	bsontype.Null:             "null",
+	bsontype.Regex:            "regex",
+	bsontype.JavaScript:       "javascript",
+	bsontype.Int32:            "int",
+	bsontype.Timestamp:        "timestamp",
+	bsontype.Int64:            "long",
+	bsontype.Decimal128:       "decimal",
+	bsontype.MinKey:           "minKey",
+	bsontype.MaxKey:           "maxKey",
+}
+
+// check value by bsonType,properties,required,items and additionalProperties of schema like the server
+func matchJSONSchema(schema bson.M, value bson.RawValue, path string) error {
+	typeName := _jsonSchemaTestBsonTypes[value.Type]
+	switch bsonType := schema["bsonType"].(type) {
+	case string:
+		if bsonType != typeName {
+			return fmt.Errorf("%s: expect %s,got %s", path, bsonType, typeName)
+		}
+	case bson.A:
+		matched := false
+		for _, eachType := range bsonType {
+			matched = matched || eachType == typeName
+		}
+		if !matched {
+			return fmt.Errorf("%s: expect one of %v,got %s", path, bsonType, typeName)
+		}
+	}
+
+	switch value.Type {
+	case bsontype.EmbeddedDocument:
+		document := value.Document()
+		if required, ok := schema["required"].([]string); ok {
+			for _, eachName := range required {
+				if _, err := document.LookupErr(eachName); err != nil {
+					return fmt.Errorf("%s.%s is required", path, eachName)
+				}
+			}
+		}
+		properties, _ := schema["properties"].(bson.M)
+		elementList, err := document.Elements()
+		if err != nil {
+			return err
+		}
+		for _, eachElement := range elementList {
+			propertySchema, ok := properties[eachElement.Key()].(bson.M)
+			if !ok {
+				propertySchema, ok = schema["additionalProperties"].(bson.M)
+			}
+			if !ok {
+				continue
+			}
+			if err := matchJSONSchema(propertySchema, eachElement.Value(), path+"."+eachElement.Key()); err != nil {
+				return err
+			}
+		}
+	case bsontype.Array:
+		itemSchema, ok := schema["items"].(bson.M)
+		if !ok {
+			return nil
+		}
+		valueList, err := value.Array().Values()
+		if err != nil {
+			return err
+		}
+		for i, eachValue := range valueList {
+			if err := matchJSONSchema(itemSchema, eachValue, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
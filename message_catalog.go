@@ -0,0 +1,69 @@
+package mongodbr
+
+import "sync"
+
+// MessageCatalog provides the message templates of the error codes,{name} in a template is
+// replaced by the param name of the error
+type MessageCatalog interface {
+	Message(code ErrorCode) (template string, ok bool)
+}
+
+// MessageCatalogMap is a MessageCatalog keeping the templates in a map
+type MessageCatalogMap map[ErrorCode]string
+
+func (m MessageCatalogMap) Message(code ErrorCode) (string, bool) {
+	template, ok := m[code]
+	return template, ok
+}
+
+var (
+	// english messages,used for the codes missing from the catalog set by SetMessageCatalog
+	DefaultMessageCatalog = MessageCatalogMap{
+		ErrorCodeNilClient:               "client is nil",
+		ErrorCodeConnectFailed:           "failed to connect the mongodb client {client}",
+		ErrorCodePingFailed:              "failed to ping mongodb",
+		ErrorCodeNilCollectionGetter:     "getDbCollection cannot be nil",
+		ErrorCodeNilCollection:           "collection cannot be nil",
+		ErrorCodeEmptyCollectionName:     "collection name cannot be empty",
+		ErrorCodeEmptyDatabaseName:       "database name cannot be empty",
+		ErrorCodeClientNotRegistered:     "cannot get the collection of client {client}",
+		ErrorCodeReadClientNotRegistered: "read client {client} is not registered",
+		ErrorCodeNilFilter:               "cannot delete many {collection} documents,filter cannot be nil",
+		ErrorCodeNilItem:                 "item cannot be nil,collection: {collection}",
+	}
+
+	// chinese messages
+	ChineseMessageCatalog = MessageCatalogMap{
+		ErrorCodeNilClient:               "client不能为nil",
+		ErrorCodeConnectFailed:           "无法初始化mongodb client {client},在连接到mongodb时出现异常",
+		ErrorCodePingFailed:              "mongodb ping测试时出现异常",
+		ErrorCodeNilCollectionGetter:     "getDbCollection参数不能为nil",
+		ErrorCodeNilCollection:           "collection不能为nil",
+		ErrorCodeEmptyCollectionName:     "collectionName参数不能为nil",
+		ErrorCodeEmptyDatabaseName:       "database参数不能为nil",
+		ErrorCodeClientNotRegistered:     "无法获取client {client}的collection",
+		ErrorCodeReadClientNotRegistered: "用于读取的client {client}没有注册",
+		ErrorCodeNilFilter:               "无法删除多条{collection}记录,filter参数不能为null",
+		ErrorCodeNilItem:                 "item参数不能为nil,col:{collection}",
+	}
+
+	_messageCatalog      MessageCatalog = DefaultMessageCatalog
+	_messageCatalogMutex sync.RWMutex
+)
+
+// localize the messages of the errors,such as SetMessageCatalog(ChineseMessageCatalog).
+// nil restores DefaultMessageCatalog
+func SetMessageCatalog(catalog MessageCatalog) {
+	if catalog == nil {
+		catalog = DefaultMessageCatalog
+	}
+	_messageCatalogMutex.Lock()
+	defer _messageCatalogMutex.Unlock()
+	_messageCatalog = catalog
+}
+
+func getMessageCatalog() MessageCatalog {
+	_messageCatalogMutex.RLock()
+	defer _messageCatalogMutex.RUnlock()
+	return _messageCatalog
+}
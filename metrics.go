@@ -0,0 +1,485 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	OperationOutcomeSuccess  = "success"
+	OperationOutcomeNotFound = "not_found"
+	OperationOutcomeError    = "error"
+
+	CacheResultHit  = "hit"
+	CacheResultMiss = "miss"
+
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	// buckets of the operation latency histogram in seconds
+	DefaultLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+	// metrics used by the repositories and clients without their own metrics
+	DefaultMetrics = NewMetrics()
+)
+
+type collectionMetricKey struct {
+	database   string
+	collection string
+}
+
+type operationMetricKey struct {
+	collectionMetricKey
+	operation string
+}
+
+type outcomeMetricKey struct {
+	operationMetricKey
+	outcome string
+}
+
+type documentMetricKey struct {
+	collectionMetricKey
+	kind string
+}
+
+type cacheMetricKey struct {
+	collectionMetricKey
+	// id or filter
+	kind   string
+	result string
+}
+
+type poolMetricKey struct {
+	client  string
+	address string
+}
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func (h *histogram) observe(value float64) {
+	for i, eachBucket := range h.buckets {
+		if value <= eachBucket {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += value
+}
+
+type poolMetric struct {
+	open           int64
+	inUse          int64
+	created        int64
+	closed         int64
+	checkoutFailed int64
+	cleared        int64
+}
+
+// Metrics collects the operation metrics of the repositories and the pool metrics of the clients,
+// they are exposed in the prometheus text format by Handler
+type Metrics struct {
+	mu             sync.Mutex
+	latencyBuckets []float64
+	operations     map[outcomeMetricKey]uint64
+	latencies      map[operationMetricKey]*histogram
+	documents      map[documentMetricKey]uint64
+	cursorBatches  map[collectionMetricKey]uint64
+	cacheRequests  map[cacheMetricKey]uint64
+	pools          map[poolMetricKey]*poolMetric
+}
+
+type MetricsOption func(*Metrics)
+
+// buckets of the latency histogram in seconds,default is DefaultLatencyBuckets
+func MetricsWithLatencyBuckets(buckets ...float64) MetricsOption {
+	return func(m *Metrics) {
+		if len(buckets) > 0 {
+			m.latencyBuckets = append([]float64(nil), buckets...)
+			sort.Float64s(m.latencyBuckets)
+		}
+	}
+}
+
+func NewMetrics(opts ...MetricsOption) *Metrics {
+	m := &Metrics{
+		latencyBuckets: DefaultLatencyBuckets,
+		operations:     make(map[outcomeMetricKey]uint64),
+		latencies:      make(map[operationMetricKey]*histogram),
+		documents:      make(map[documentMetricKey]uint64),
+		cursorBatches:  make(map[collectionMetricKey]uint64),
+		cacheRequests:  make(map[cacheMetricKey]uint64),
+		pools:          make(map[poolMetricKey]*poolMetric),
+	}
+	for _, eachOpt := range opts {
+		eachOpt(m)
+	}
+	return m
+}
+
+// record the operation metrics of the repository into metrics instead of DefaultMetrics
+func WithMetrics(metrics *Metrics) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.metrics = metrics
+	}
+}
+
+func (c *Configuration) getMetrics() *Metrics {
+	if c.metrics != nil {
+		return c.metrics
+	}
+	return DefaultMetrics
+}
+
+// #region collect
+
+// record an operation of the repository
+func (m *Metrics) observeOperation(op *operation, duration time.Duration, err error) {
+	key := operationMetricKey{
+		collectionMetricKey: collectionMetricKey{
+			database:   op.collection.Database().Name(),
+			collection: op.collection.Name(),
+		},
+		operation: op.name,
+	}
+	outcome := OperationOutcomeSuccess
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		outcome = OperationOutcomeNotFound
+	} else if err != nil {
+		outcome = OperationOutcomeError
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.operations[outcomeMetricKey{operationMetricKey: key, outcome: outcome}]++
+	h, ok := m.latencies[key]
+	if !ok {
+		h = &histogram{
+			buckets: m.latencyBuckets,
+			counts:  make([]uint64, len(m.latencyBuckets)),
+		}
+		m.latencies[key] = h
+	}
+	h.observe(duration.Seconds())
+	for _, eachCount := range []struct {
+		kind  string
+		value int64
+	}{
+		{"returned", op.returned},
+		{"matched", op.matched},
+		{"modified", op.modified},
+		{"deleted", op.deleted},
+		{"inserted", op.inserted},
+		{"upserted", op.upserted},
+	} {
+		if eachCount.value > 0 {
+			m.documents[documentMetricKey{collectionMetricKey: key.collectionMetricKey, kind: eachCount.kind}] += uint64(eachCount.value)
+		}
+	}
+}
+
+// count the cursor batches of find,aggregate and getMore by the namespace of the reply
+func (m *Metrics) observeCommandSucceeded(e *event.CommandSucceededEvent) {
+	value, err := e.Reply.LookupErr("cursor", "ns")
+	if err != nil {
+		return
+	}
+	ns, ok := value.StringValueOK()
+	if !ok {
+		return
+	}
+	database, collection, _ := strings.Cut(ns, ".")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cursorBatches[collectionMetricKey{database: database, collection: collection}]++
+}
+
+// count a lookup of CachedRepository
+func (m *Metrics) observeCache(database string, collection string, kind string, hit bool) {
+	result := CacheResultMiss
+	if hit {
+		result = CacheResultHit
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cacheRequests[cacheMetricKey{
+		collectionMetricKey: collectionMetricKey{database: database, collection: collection},
+		kind:                kind,
+		result:              result,
+	}]++
+}
+
+func (m *Metrics) observePoolEvent(client string, e *event.PoolEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := poolMetricKey{client: client, address: e.Address}
+	pool, ok := m.pools[key]
+	if !ok {
+		pool = &poolMetric{}
+		m.pools[key] = pool
+	}
+	switch e.Type {
+	case event.ConnectionCreated:
+		pool.created++
+		pool.open++
+	case event.ConnectionClosed:
+		pool.closed++
+		pool.open--
+	case event.GetSucceeded:
+		pool.inUse++
+	case event.ConnectionReturned:
+		pool.inUse--
+	case event.GetFailed:
+		pool.checkoutFailed++
+	case event.PoolCleared:
+		pool.cleared++
+	}
+}
+
+// collect the pool metrics and the cursor batches of the client as key
+func (m *Metrics) enableClientMetrics(key string) func(*options.ClientOptions) {
+	commandMonitor := &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			m.observeCommandSucceeded(e)
+		},
+	}
+	poolMonitor := &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			m.observePoolEvent(key, e)
+		},
+	}
+	return func(co *options.ClientOptions) {
+		co.SetMonitor(chainCommandMonitor(co.Monitor, commandMonitor))
+		co.SetPoolMonitor(chainPoolMonitor(co.PoolMonitor, poolMonitor))
+	}
+}
+
+// call the pool monitors one after another,nil monitors are skipped
+func chainPoolMonitor(monitors ...*event.PoolMonitor) *event.PoolMonitor {
+	list := make([]*event.PoolMonitor, 0, len(monitors))
+	for _, eachMonitor := range monitors {
+		if eachMonitor != nil && eachMonitor.Event != nil {
+			list = append(list, eachMonitor)
+		}
+	}
+	if len(list) == 1 {
+		return list[0]
+	}
+	return &event.PoolMonitor{
+		Event: func(e *event.PoolEvent) {
+			for _, eachMonitor := range list {
+				eachMonitor.Event(e)
+			}
+		},
+	}
+}
+
+// #endregion
+
+// #region exposition
+
+// http.Handler writing the metrics in the prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", metricsContentType)
+		m.WriteTo(w)
+	})
+}
+
+// http.Handler of DefaultMetrics
+func MetricsHandler() http.Handler {
+	return DefaultMetrics.Handler()
+}
+
+// write the metrics in the prometheus text format
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	builder := &strings.Builder{}
+	m.writeOperations(builder)
+	m.writeLatencies(builder)
+	m.writeDocuments(builder)
+	m.writeCursorBatches(builder)
+	m.writeCacheRequests(builder)
+	m.writePools(builder)
+	m.mu.Unlock()
+
+	n, err := io.WriteString(w, builder.String())
+	return int64(n), err
+}
+
+func (m *Metrics) writeOperations(builder *strings.Builder) {
+	keyList := make([]outcomeMetricKey, 0, len(m.operations))
+	for eachKey := range m.operations {
+		keyList = append(keyList, eachKey)
+	}
+	sort.Slice(keyList, func(i, j int) bool {
+		return keyList[i].labels() < keyList[j].labels()
+	})
+	writeMetricHeader(builder, "mongodbr_operations_total", "counter", "Number of repository operations by outcome.")
+	for _, eachKey := range keyList {
+		writeSample(builder, "mongodbr_operations_total", eachKey.labels(), strconv.FormatUint(m.operations[eachKey], 10))
+	}
+}
+
+func (m *Metrics) writeLatencies(builder *strings.Builder) {
+	keyList := make([]operationMetricKey, 0, len(m.latencies))
+	for eachKey := range m.latencies {
+		keyList = append(keyList, eachKey)
+	}
+	sort.Slice(keyList, func(i, j int) bool {
+		return keyList[i].labels() < keyList[j].labels()
+	})
+	writeMetricHeader(builder, "mongodbr_operation_duration_seconds", "histogram", "Latency of repository operations in seconds.")
+	for _, eachKey := range keyList {
+		h := m.latencies[eachKey]
+		labels := eachKey.labels()
+		for i, eachBucket := range h.buckets {
+			writeSample(builder, "mongodbr_operation_duration_seconds_bucket",
+				labels+`,le="`+formatFloat(eachBucket)+`"`, strconv.FormatUint(h.counts[i], 10))
+		}
+		writeSample(builder, "mongodbr_operation_duration_seconds_bucket", labels+`,le="+Inf"`, strconv.FormatUint(h.count, 10))
+		writeSample(builder, "mongodbr_operation_duration_seconds_sum", labels, formatFloat(h.sum))
+		writeSample(builder, "mongodbr_operation_duration_seconds_count", labels, strconv.FormatUint(h.count, 10))
+	}
+}
+
+func (m *Metrics) writeDocuments(builder *strings.Builder) {
+	keyList := make([]documentMetricKey, 0, len(m.documents))
+	for eachKey := range m.documents {
+		keyList = append(keyList, eachKey)
+	}
+	sort.Slice(keyList, func(i, j int) bool {
+		return keyList[i].labels() < keyList[j].labels()
+	})
+	writeMetricHeader(builder, "mongodbr_documents_total", "counter", "Number of documents returned or modified by repository operations.")
+	for _, eachKey := range keyList {
+		writeSample(builder, "mongodbr_documents_total", eachKey.labels(), strconv.FormatUint(m.documents[eachKey], 10))
+	}
+}
+
+func (m *Metrics) writeCursorBatches(builder *strings.Builder) {
+	keyList := make([]collectionMetricKey, 0, len(m.cursorBatches))
+	for eachKey := range m.cursorBatches {
+		keyList = append(keyList, eachKey)
+	}
+	sort.Slice(keyList, func(i, j int) bool {
+		return keyList[i].labels() < keyList[j].labels()
+	})
+	writeMetricHeader(builder, "mongodbr_cursor_batches_total", "counter", "Number of cursor batches received.")
+	for _, eachKey := range keyList {
+		writeSample(builder, "mongodbr_cursor_batches_total", eachKey.labels(), strconv.FormatUint(m.cursorBatches[eachKey], 10))
+	}
+}
+
+func (m *Metrics) writeCacheRequests(builder *strings.Builder) {
+	keyList := make([]cacheMetricKey, 0, len(m.cacheRequests))
+	for eachKey := range m.cacheRequests {
+		keyList = append(keyList, eachKey)
+	}
+	sort.Slice(keyList, func(i, j int) bool {
+		return keyList[i].labels() < keyList[j].labels()
+	})
+	writeMetricHeader(builder, "mongodbr_cache_requests_total", "counter", "Number of cached repository lookups by result.")
+	for _, eachKey := range keyList {
+		writeSample(builder, "mongodbr_cache_requests_total", eachKey.labels(), strconv.FormatUint(m.cacheRequests[eachKey], 10))
+	}
+}
+
+func (m *Metrics) writePools(builder *strings.Builder) {
+	keyList := make([]poolMetricKey, 0, len(m.pools))
+	for eachKey := range m.pools {
+		keyList = append(keyList, eachKey)
+	}
+	sort.Slice(keyList, func(i, j int) bool {
+		return keyList[i].labels() < keyList[j].labels()
+	})
+	for _, eachMetric := range []struct {
+		name       string
+		metricType string
+		help       string
+		value      func(*poolMetric) int64
+	}{
+		{"mongodbr_pool_connections_open", "gauge", "Number of open connections in the pool.", func(p *poolMetric) int64 { return p.open }},
+		{"mongodbr_pool_connections_in_use", "gauge", "Number of connections checked out of the pool.", func(p *poolMetric) int64 { return p.inUse }},
+		{"mongodbr_pool_connections_created_total", "counter", "Number of connections created.", func(p *poolMetric) int64 { return p.created }},
+		{"mongodbr_pool_connections_closed_total", "counter", "Number of connections closed.", func(p *poolMetric) int64 { return p.closed }},
+		{"mongodbr_pool_checkout_failures_total", "counter", "Number of failed connection checkouts.", func(p *poolMetric) int64 { return p.checkoutFailed }},
+		{"mongodbr_pool_cleared_total", "counter", "Number of times the pool was cleared.", func(p *poolMetric) int64 { return p.cleared }},
+	} {
+		writeMetricHeader(builder, eachMetric.name, eachMetric.metricType, eachMetric.help)
+		for _, eachKey := range keyList {
+			writeSample(builder, eachMetric.name, eachKey.labels(), strconv.FormatInt(eachMetric.value(m.pools[eachKey]), 10))
+		}
+	}
+}
+
+func (k collectionMetricKey) labels() string {
+	return formatLabels("database", k.database, "collection", k.collection)
+}
+
+func (k operationMetricKey) labels() string {
+	return k.collectionMetricKey.labels() + "," + formatLabels("operation", k.operation)
+}
+
+func (k outcomeMetricKey) labels() string {
+	return k.operationMetricKey.labels() + "," + formatLabels("outcome", k.outcome)
+}
+
+func (k documentMetricKey) labels() string {
+	return k.collectionMetricKey.labels() + "," + formatLabels("kind", k.kind)
+}
+
+func (k cacheMetricKey) labels() string {
+	return k.collectionMetricKey.labels() + "," + formatLabels("kind", k.kind, "result", k.result)
+}
+
+func (k poolMetricKey) labels() string {
+	return formatLabels("client", k.client, "address", k.address)
+}
+
+func writeMetricHeader(builder *strings.Builder, name string, metricType string, help string) {
+	fmt.Fprintf(builder, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+func writeSample(builder *strings.Builder, name string, labels string, value string) {
+	fmt.Fprintf(builder, "%s{%s} %s\n", name, labels, value)
+}
+
+// name="value" pairs separated by ','
+func formatLabels(nameValues ...string) string {
+	partList := make([]string, 0, len(nameValues)/2)
+	for i := 0; i+1 < len(nameValues); i += 2 {
+		partList = append(partList, nameValues[i]+`="`+escapeLabelValue(nameValues[i+1])+`"`)
+	}
+	return strings.Join(partList, ",")
+}
+
+var _labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return _labelValueReplacer.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// #endregion
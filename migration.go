@@ -0,0 +1,504 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	mongodbrerr "github.com/shanluzhineng/mongodbr/err"
+)
+
+const (
+	MigrationCollectionName = "_migrations"
+
+	migrationLockId = "lock"
+
+	MigrationDirectionUp   = "up"
+	MigrationDirectionDown = "down"
+)
+
+var (
+	ErrMigrationLocked       = errors.New("migration lock is held by another instance")
+	ErrMigrationLockLost     = errors.New("migration lock lost")
+	ErrMigrationIrreversible = errors.New("migration has no down function")
+	ErrDuplicateMigration    = errors.New("duplicate migration version")
+	ErrMigrationNotFound     = errors.New("migration not found")
+	ErrMigrationNotRecorded  = errors.New("migration ran but not recorded")
+
+	_defaultMigrations = &migrationList{}
+)
+
+// change of data or schema,ctx carries the session of the migration
+type MigrationFunc func(ctx mongo.SessionContext, db *mongo.Database) error
+
+type Migration struct {
+	Version     int64
+	Description string
+	Up          MigrationFunc
+	Down        MigrationFunc
+}
+
+// applied state of a migration
+type MigrationStatus struct {
+	Version     int64
+	Description string
+	Applied     bool
+	AppliedAt   *time.Time
+	//applied on the database but not registered
+	Missing bool
+}
+
+// migration executed or planned by Migrate
+type MigrationStep struct {
+	Version     int64
+	Description string
+	Direction   string
+	Duration    time.Duration
+}
+
+type migrationRecord struct {
+	Version     int64     `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"appliedAt"`
+}
+
+type migrationList struct {
+	mu   sync.Mutex
+	list []*Migration
+}
+
+func (l *migrationList) add(migrationList ...*Migration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, eachMigration := range migrationList {
+		if eachMigration == nil {
+			continue
+		}
+		if eachMigration.Up == nil {
+			return fmt.Errorf("migration %d has no up function", eachMigration.Version)
+		}
+		for _, eachExisting := range l.list {
+			if eachExisting.Version == eachMigration.Version {
+				return fmt.Errorf("%w: %d", ErrDuplicateMigration, eachMigration.Version)
+			}
+		}
+		l.list = append(l.list, eachMigration)
+	}
+	sort.Slice(l.list, func(i, j int) bool {
+		return l.list[i].Version < l.list[j].Version
+	})
+	return nil
+}
+
+func (l *migrationList) snapshot() []*Migration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return append([]*Migration(nil), l.list...)
+}
+
+// regist migrations to the default list used by RunMigrations
+func RegistMigration(migrationList ...*Migration) error {
+	return _defaultMigrations.add(migrationList...)
+}
+
+// methods of mongo.Collection used to keep the applied records and the lock
+type migrationCollection interface {
+	Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error)
+	InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error)
+	UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error)
+	DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error)
+}
+
+type Migrator struct {
+	db         *mongo.Database
+	collection migrationCollection
+	migrations *migrationList
+
+	owner          string
+	leaseDuration  time.Duration
+	useTransaction bool
+}
+
+type MigratorOption func(*Migrator)
+
+// lease duration of the migration lock,the lock is renewed while migrating. default is 1 minute
+func MigratorWithLeaseDuration(leaseDuration time.Duration) MigratorOption {
+	return func(m *Migrator) {
+		if leaseDuration > 0 {
+			m.leaseDuration = leaseDuration
+		}
+	}
+}
+
+// run every migration in a transaction together with its state,requires a replica set
+func MigratorWithTransaction() MigratorOption {
+	return func(m *Migrator) {
+		m.useTransaction = true
+	}
+}
+
+// identity of the instance holding the lock,default is hostname-pid-objectid
+func MigratorWithOwner(owner string) MigratorOption {
+	return func(m *Migrator) {
+		if len(owner) > 0 {
+			m.owner = owner
+		}
+	}
+}
+
+func NewMigrator(db *mongo.Database, opts ...MigratorOption) *Migrator {
+	if db == nil {
+		panic(errors.New("db cannot be nil"))
+	}
+	hostname, _ := os.Hostname()
+	m := &Migrator{
+		db:            db,
+		collection:    db.Collection(MigrationCollectionName),
+		migrations:    &migrationList{},
+		owner:         fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), primitive.NewObjectID().Hex()),
+		leaseDuration: time.Minute,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(m)
+	}
+	return m
+}
+
+// new Migrator on the database of the client registed with key,use DefaultClient if key is empty
+func NewMigratorByKey(key string, databaseName string, opts ...MigratorOption) (*Migrator, error) {
+	var db *mongo.Database
+	if len(key) <= 0 {
+		db = GetDatabase(databaseName)
+	} else {
+		db = GetDatabaseByKey(key, databaseName)
+	}
+	if db == nil {
+		return nil, fmt.Errorf("cannot get database %s of client %s", databaseName, key)
+	}
+	return NewMigrator(db, opts...), nil
+}
+
+// regist migrations to the migrator
+func (m *Migrator) Register(migrationList ...*Migration) error {
+	return m.migrations.add(migrationList...)
+}
+
+// registered and applied migrations ordered by version
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	appliedList, err := m.appliedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	appliedMap := make(map[int64]migrationRecord, len(appliedList))
+	for _, eachRecord := range appliedList {
+		appliedMap[eachRecord.Version] = eachRecord
+	}
+
+	result := make([]MigrationStatus, 0)
+	for _, eachMigration := range m.migrations.snapshot() {
+		status := MigrationStatus{
+			Version:     eachMigration.Version,
+			Description: eachMigration.Description,
+		}
+		if record, ok := appliedMap[eachMigration.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.Applied = true
+			status.AppliedAt = &appliedAt
+			delete(appliedMap, eachMigration.Version)
+		}
+		result = append(result, status)
+	}
+	for _, eachRecord := range appliedMap {
+		appliedAt := eachRecord.AppliedAt
+		result = append(result, MigrationStatus{
+			Version:     eachRecord.Version,
+			Description: eachRecord.Description,
+			Applied:     true,
+			AppliedAt:   &appliedAt,
+			Missing:     true,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
+
+// the highest applied version,0 if nothing is applied
+func (m *Migrator) CurrentVersion(ctx context.Context) (int64, error) {
+	appliedList, err := m.appliedRecords(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(appliedList) <= 0 {
+		return 0, nil
+	}
+	return appliedList[len(appliedList)-1].Version, nil
+}
+
+type migrateOptions struct {
+	targetVersion *int64
+	dryRun        bool
+}
+
+type MigrateOption func(*migrateOptions)
+
+// migrate up or down to version,default is the latest registered version
+func MigrateWithTargetVersion(version int64) MigrateOption {
+	return func(o *migrateOptions) {
+		o.targetVersion = &version
+	}
+}
+
+// only return the steps,do not execute them
+func MigrateWithDryRun() MigrateOption {
+	return func(o *migrateOptions) {
+		o.dryRun = true
+	}
+}
+
+// apply the pending migrations up to the target version,or roll back the applied ones above it.
+// only one instance can migrate at the same time,ErrMigrationLocked is returned for the others
+func (m *Migrator) Migrate(ctx context.Context, opts ...MigrateOption) ([]MigrationStep, error) {
+	o := &migrateOptions{}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	if o.dryRun {
+		return m.plan(ctx, o)
+	}
+
+	if err := m.acquireLock(ctx); err != nil {
+		return nil, err
+	}
+	lockCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	lockLost := make(chan struct{})
+	go m.renewLock(lockCtx, cancel, lockLost)
+	defer func() {
+		//the lease expires by itself,a failed release only delays the next migration
+		if err := m.releaseLock(context.Background()); err != nil {
+			slog.Warn("mongodb migration lock release failed",
+				slog.String("owner", m.owner),
+				slog.Any("error", err))
+		}
+	}()
+
+	stepList, err := m.plan(lockCtx, o)
+	if err != nil {
+		return nil, err
+	}
+	executedList := make([]MigrationStep, 0, len(stepList))
+	for _, eachStep := range stepList {
+		startTime := time.Now()
+		if err := m.execute(lockCtx, eachStep); err != nil {
+			select {
+			case <-lockLost:
+				err = fmt.Errorf("%w: %v", ErrMigrationLockLost, err)
+			default:
+			}
+			return executedList, fmt.Errorf("migration %d %s failed: %w", eachStep.Version, eachStep.Direction, err)
+		}
+		eachStep.Duration = time.Since(startTime)
+		executedList = append(executedList, eachStep)
+	}
+	return executedList, nil
+}
+
+func (m *Migrator) plan(ctx context.Context, o *migrateOptions) ([]MigrationStep, error) {
+	migrationList := m.migrations.snapshot()
+	appliedList, err := m.appliedRecords(ctx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]migrationRecord, len(appliedList))
+	for _, eachRecord := range appliedList {
+		applied[eachRecord.Version] = eachRecord
+	}
+
+	var targetVersion int64
+	if o.targetVersion != nil {
+		targetVersion = *o.targetVersion
+	} else if len(migrationList) > 0 {
+		targetVersion = migrationList[len(migrationList)-1].Version
+	}
+
+	stepList := make([]MigrationStep, 0)
+	for _, eachMigration := range migrationList {
+		if eachMigration.Version > targetVersion {
+			break
+		}
+		if _, ok := applied[eachMigration.Version]; ok {
+			continue
+		}
+		stepList = append(stepList, MigrationStep{
+			Version:     eachMigration.Version,
+			Description: eachMigration.Description,
+			Direction:   MigrationDirectionUp,
+		})
+	}
+	for i := len(appliedList) - 1; i >= 0; i-- {
+		record := appliedList[i]
+		if record.Version <= targetVersion {
+			break
+		}
+		migration := findMigration(migrationList, record.Version)
+		if migration == nil {
+			return nil, fmt.Errorf("%w: cannot roll back %d", ErrMigrationNotFound, record.Version)
+		}
+		if migration.Down == nil {
+			return nil, fmt.Errorf("%w: %d", ErrMigrationIrreversible, record.Version)
+		}
+		stepList = append(stepList, MigrationStep{
+			Version:     record.Version,
+			Description: record.Description,
+			Direction:   MigrationDirectionDown,
+		})
+	}
+	return stepList, nil
+}
+
+func (m *Migrator) execute(ctx context.Context, step MigrationStep) error {
+	migration := findMigration(m.migrations.snapshot(), step.Version)
+	if migration == nil {
+		return ErrMigrationNotFound
+	}
+	fn := migration.Up
+	if step.Direction == MigrationDirectionDown {
+		fn = migration.Down
+	}
+
+	session, err := m.db.Client().StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	run := func(sc mongo.SessionContext) (interface{}, error) {
+		if err := fn(sc, m.db); err != nil {
+			return nil, err
+		}
+		var err error
+		if step.Direction == MigrationDirectionDown {
+			_, err = m.collection.DeleteOne(sc, bson.M{"_id": step.Version})
+		} else {
+			_, err = m.collection.InsertOne(sc, migrationRecord{
+				Version:     step.Version,
+				Description: step.Description,
+				AppliedAt:   time.Now(),
+			})
+		}
+		if err != nil && !m.useTransaction {
+			//the change of fn is kept,the record must be fixed by hand before migrating again
+			return nil, fmt.Errorf("%w: %v", ErrMigrationNotRecorded, err)
+		}
+		return nil, err
+	}
+	if m.useTransaction {
+		_, err = session.WithTransaction(ctx, run)
+		return err
+	}
+	_, err = run(mongo.NewSessionContext(ctx, session))
+	return err
+}
+
+func (m *Migrator) appliedRecords(ctx context.Context) ([]migrationRecord, error) {
+	cur, err := m.collection.Find(ctx,
+		bson.M{"_id": bson.M{"$ne": migrationLockId}},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	recordList := make([]migrationRecord, 0)
+	if err := cur.All(ctx, &recordList); err != nil {
+		return nil, err
+	}
+	return recordList, nil
+}
+
+// take the lease lock if it is free,expired or already owned
+func (m *Migrator) acquireLock(ctx context.Context) error {
+	now := time.Now()
+	_, err := m.collection.UpdateOne(ctx,
+		bson.M{
+			"_id": migrationLockId,
+			"$or": bson.A{
+				bson.M{"expiresAt": bson.M{"$lt": now}},
+				bson.M{"owner": m.owner},
+			},
+		},
+		bson.M{"$set": bson.M{
+			"owner":      m.owner,
+			"acquiredAt": now,
+			"expiresAt":  now.Add(m.leaseDuration),
+		}},
+		options.Update().SetUpsert(true))
+	if err != nil {
+		if mongodbrerr.IsDuplicateKey(err) {
+			return ErrMigrationLocked
+		}
+		return err
+	}
+	return nil
+}
+
+// extend the lease until ctx is done,cancel the migration if the lease cannot be extended
+func (m *Migrator) renewLock(ctx context.Context, cancel context.CancelFunc, lockLost chan struct{}) {
+	ticker := time.NewTicker(m.leaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		res, err := m.collection.UpdateOne(ctx,
+			bson.M{"_id": migrationLockId, "owner": m.owner},
+			bson.M{"$set": bson.M{"expiresAt": time.Now().Add(m.leaseDuration)}})
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil || res.MatchedCount <= 0 {
+			close(lockLost)
+			cancel()
+			return
+		}
+	}
+}
+
+func (m *Migrator) releaseLock(ctx context.Context) error {
+	_, err := m.collection.DeleteOne(ctx, bson.M{"_id": migrationLockId, "owner": m.owner})
+	return err
+}
+
+func findMigration(migrationList []*Migration, version int64) *Migration {
+	for _, eachMigration := range migrationList {
+		if eachMigration.Version == version {
+			return eachMigration
+		}
+	}
+	return nil
+}
+
+// run the migrations registed by RegistMigration on the database of the client registed with key,
+// use DefaultClient if key is empty
+func RunMigrations(ctx context.Context, key string, databaseName string, opts ...MigrateOption) ([]MigrationStep, error) {
+	migrator, err := NewMigratorByKey(key, databaseName)
+	if err != nil {
+		return nil, err
+	}
+	migrator.migrations = _defaultMigrations
+	return migrator.Migrate(ctx, opts...)
+}
@@ -0,0 +1,243 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// in memory _migrations collection,the lock filters are matched like the server does
+type migrationTestCollection struct {
+	mu        sync.Mutex
+	records   map[int64]migrationRecord
+	lock      bson.M
+	insertErr error
+}
+
+func newMigrationTestCollection(versionList ...int64) *migrationTestCollection {
+	c := &migrationTestCollection{records: make(map[int64]migrationRecord)}
+	for _, eachVersion := range versionList {
+		c.records[eachVersion] = migrationRecord{Version: eachVersion, AppliedAt: time.Now()}
+	}
+	return c
+}
+
+func (c *migrationTestCollection) Find(ctx context.Context, filter interface{}, opts ...*options.FindOptions) (*mongo.Cursor, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	versionList := make([]int64, 0, len(c.records))
+	for eachVersion := range c.records {
+		versionList = append(versionList, eachVersion)
+	}
+	sort.Slice(versionList, func(i, j int) bool { return versionList[i] < versionList[j] })
+	documentList := make([]interface{}, 0, len(versionList))
+	for _, eachVersion := range versionList {
+		documentList = append(documentList, c.records[eachVersion])
+	}
+	return mongo.NewCursorFromDocuments(documentList, nil, nil)
+}
+
+func (c *migrationTestCollection) InsertOne(ctx context.Context, document interface{}, opts ...*options.InsertOneOptions) (*mongo.InsertOneResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.insertErr != nil {
+		return nil, c.insertErr
+	}
+	record := document.(migrationRecord)
+	c.records[record.Version] = record
+	return &mongo.InsertOneResult{InsertedID: record.Version}, nil
+}
+
+func (c *migrationTestCollection) UpdateOne(ctx context.Context, filter interface{}, update interface{}, opts ...*options.UpdateOptions) (*mongo.UpdateResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	upsert := len(opts) > 0 && opts[0].Upsert != nil && *opts[0].Upsert
+	set := update.(bson.M)["$set"].(bson.M)
+	switch {
+	case c.lock == nil && upsert:
+		c.lock = bson.M{}
+	case c.lock == nil || !c.matchLock(filter.(bson.M)):
+		if upsert {
+			//the upsert inserts a second document with the _id of the lock
+			return nil, mongo.WriteException{WriteErrors: mongo.WriteErrors{{Code: 11000, Message: "duplicate key"}}}
+		}
+		return &mongo.UpdateResult{}, nil
+	}
+	for eachKey, eachValue := range set {
+		c.lock[eachKey] = eachValue
+	}
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+func (c *migrationTestCollection) matchLock(filter bson.M) bool {
+	if owner, ok := filter["owner"]; ok && c.lock["owner"] != owner {
+		return false
+	}
+	orList, ok := filter["$or"].(bson.A)
+	if !ok {
+		return true
+	}
+	for _, eachCondition := range orList {
+		condition := eachCondition.(bson.M)
+		if owner, ok := condition["owner"]; ok && c.lock["owner"] == owner {
+			return true
+		}
+		if expiresAt, ok := condition["expiresAt"].(bson.M); ok && c.lock["expiresAt"].(time.Time).Before(expiresAt["$lt"].(time.Time)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *migrationTestCollection) DeleteOne(ctx context.Context, filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	f := filter.(bson.M)
+	if f["_id"] == migrationLockId {
+		if c.lock == nil || c.lock["owner"] != f["owner"] {
+			return &mongo.DeleteResult{}, nil
+		}
+		c.lock = nil
+		return &mongo.DeleteResult{DeletedCount: 1}, nil
+	}
+	delete(c.records, f["_id"].(int64))
+	return &mongo.DeleteResult{DeletedCount: 1}, nil
+}
+
+func newTestMigrator(t *testing.T, collection *migrationTestCollection, owner string) *Migrator {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+	m := NewMigrator(client.Database("test"), MigratorWithOwner(owner))
+	m.collection = collection
+	return m
+}
+
+func newTestMigration(version int64, reversible bool, ranList *[]int64) *Migration {
+	fn := func(mongo.SessionContext, *mongo.Database) error {
+		*ranList = append(*ranList, version)
+		return nil
+	}
+	migration := &Migration{Version: version, Up: fn}
+	if reversible {
+		migration.Down = fn
+	}
+	return migration
+}
+
+func TestMigratorPlan(t *testing.T) {
+	version := func(v int64) *int64 { return &v }
+	testCases := []struct {
+		name          string
+		applied       []int64
+		targetVersion *int64
+		expect        []string
+		expectErr     error
+	}{
+		{"nothing applied", nil, nil, []string{"1 up", "2 up", "3 up"}, nil},
+		{"pending only", []int64{1}, nil, []string{"2 up", "3 up"}, nil},
+		{"gap is applied", []int64{1, 3}, nil, []string{"2 up"}, nil},
+		{"up to target", nil, version(2), []string{"1 up", "2 up"}, nil},
+		{"down in reverse order", []int64{1, 2, 3}, version(1), []string{"3 down", "2 down"}, nil},
+		{"down skips pending", []int64{1, 3}, version(1), []string{"3 down"}, nil},
+		{"irreversible", []int64{1, 2, 3}, version(0), nil, ErrMigrationIrreversible},
+		{"not registered", []int64{1, 2, 3, 4}, version(3), nil, ErrMigrationNotFound},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			m := newTestMigrator(t, newMigrationTestCollection(eachCase.applied...), "a")
+			ranList := make([]int64, 0)
+			//registered out of order
+			if err := m.Register(newTestMigration(3, true, &ranList), newTestMigration(1, false, &ranList), newTestMigration(2, true, &ranList)); err != nil {
+				t.Fatal(err)
+			}
+			stepList, err := m.plan(context.Background(), &migrateOptions{targetVersion: eachCase.targetVersion})
+			if eachCase.expectErr != nil {
+				if !errors.Is(err, eachCase.expectErr) {
+					t.Fatalf("expect %v,got %v", eachCase.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			result := make([]string, 0, len(stepList))
+			for _, eachStep := range stepList {
+				result = append(result, formatMigrationStep(eachStep))
+			}
+			if !reflect.DeepEqual(result, eachCase.expect) {
+				t.Fatalf("expect %v,got %v", eachCase.expect, result)
+			}
+		})
+	}
+}
+
+func formatMigrationStep(step MigrationStep) string {
+	return fmt.Sprintf("%d %s", step.Version, step.Direction)
+}
+
+func TestMigratorLockContention(t *testing.T) {
+	ctx := context.Background()
+	collection := newMigrationTestCollection()
+	a := newTestMigrator(t, collection, "a")
+	b := newTestMigrator(t, collection, "b")
+	ranList := make([]int64, 0)
+	b.Register(newTestMigration(1, true, &ranList))
+
+	if err := a.acquireLock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.acquireLock(ctx); err != nil {
+		t.Fatalf("the owner must take its lock again,got %v", err)
+	}
+	if err := b.acquireLock(ctx); !errors.Is(err, ErrMigrationLocked) {
+		t.Fatalf("expect ErrMigrationLocked,got %v", err)
+	}
+	if _, err := b.Migrate(ctx); !errors.Is(err, ErrMigrationLocked) || len(ranList) > 0 {
+		t.Fatalf("expect ErrMigrationLocked without running,got %v %v", err, ranList)
+	}
+	if err := b.releaseLock(ctx); err != nil || collection.lock["owner"] != "a" {
+		t.Fatalf("the lock of a must not be released by b,got %v", collection.lock)
+	}
+
+	//the lease of a expired
+	collection.lock["expiresAt"] = time.Now().Add(-time.Second)
+	stepList, err := b.Migrate(ctx)
+	if err != nil || len(stepList) != 1 || !reflect.DeepEqual(ranList, []int64{1}) {
+		t.Fatalf("expect b to take the expired lock and migrate,got %v %v", err, stepList)
+	}
+	if collection.lock != nil {
+		t.Fatalf("expect the lock released after Migrate,got %v", collection.lock)
+	}
+	if err := a.acquireLock(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigratorNotRecorded(t *testing.T) {
+	collection := newMigrationTestCollection()
+	collection.insertErr = errors.New("insert failed")
+	m := newTestMigrator(t, collection, "a")
+	ranList := make([]int64, 0)
+	m.Register(newTestMigration(1, true, &ranList))
+
+	_, err := m.Migrate(context.Background())
+	if !errors.Is(err, ErrMigrationNotRecorded) || len(ranList) != 1 {
+		t.Fatalf("expect ErrMigrationNotRecorded after running,got %v %v", err, ranList)
+	}
+}
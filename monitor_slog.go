@@ -0,0 +1,267 @@
+package mongodbr
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const redactedValue = "***"
+
+var (
+	// fields redacted from the logged commands by default,compared case-insensitively
+	DefaultRedactedFields = []string{"password", "pwd", "token", "accessToken", "refreshToken", "secret", "apiKey"}
+)
+
+type slogMonitorOptions struct {
+	logger         *slog.Logger
+	slowThreshold  time.Duration
+	redactedFields map[string]bool
+	logCommand     bool
+}
+
+type SlogMonitorOption func(*slogMonitorOptions)
+
+// logger used by the monitor,default is slog.Default()
+func SlogMonitorWithLogger(logger *slog.Logger) SlogMonitorOption {
+	return func(o *slogMonitorOptions) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// only log the commands taking at least d,failures are always logged. default is 100ms,0 logs every command
+func SlogMonitorWithSlowThreshold(d time.Duration) SlogMonitorOption {
+	return func(o *slogMonitorOptions) {
+		o.slowThreshold = d
+	}
+}
+
+// redact the fields from the logged commands at any depth,in addition to DefaultRedactedFields
+func SlogMonitorWithRedactedFields(fields ...string) SlogMonitorOption {
+	return func(o *slogMonitorOptions) {
+		for _, eachField := range fields {
+			o.redactedFields[strings.ToLower(eachField)] = true
+		}
+	}
+}
+
+// do not log the command document,only its name and collection
+func SlogMonitorWithoutCommand() SlogMonitorOption {
+	return func(o *slogMonitorOptions) {
+		o.logCommand = false
+	}
+}
+
+// enable a log/slog based command monitor which logs the slow and failed commands.
+// the monitor is chained with the monitor already set in the client options
+func EnableSlogMonitor(opts ...SlogMonitorOption) func(*options.ClientOptions) {
+	o := &slogMonitorOptions{
+		slowThreshold:  100 * time.Millisecond,
+		redactedFields: make(map[string]bool),
+		logCommand:     true,
+	}
+	for _, eachField := range DefaultRedactedFields {
+		o.redactedFields[strings.ToLower(eachField)] = true
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	if o.logger == nil {
+		o.logger = slog.Default()
+	}
+	return func(co *options.ClientOptions) {
+		co.SetMonitor(chainCommandMonitor(co.Monitor, newSlogMonitor(o).commandMonitor()))
+	}
+}
+
+// started command waiting for its succeeded or failed event
+type startedCommand struct {
+	databaseName   string
+	collectionName string
+	command        bson.Raw
+}
+
+type slogMonitor struct {
+	options *slogMonitorOptions
+	started sync.Map
+}
+
+func newSlogMonitor(o *slogMonitorOptions) *slogMonitor {
+	return &slogMonitor{options: o}
+}
+
+func (m *slogMonitor) commandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started:   m.onStarted,
+		Succeeded: m.onSucceeded,
+		Failed:    m.onFailed,
+	}
+}
+
+func (m *slogMonitor) onStarted(_ context.Context, e *event.CommandStartedEvent) {
+	command := &startedCommand{
+		databaseName:   e.DatabaseName,
+		collectionName: commandCollectionName(e.Command, e.CommandName),
+	}
+	if m.options.logCommand {
+		//the event is reused by the driver after the callback
+		command.command = append(bson.Raw(nil), e.Command...)
+	}
+	m.started.Store(e.RequestID, command)
+}
+
+func (m *slogMonitor) onSucceeded(ctx context.Context, e *event.CommandSucceededEvent) {
+	command := m.popStarted(e.RequestID)
+	duration := time.Duration(e.DurationNanos)
+	if duration < m.options.slowThreshold {
+		return
+	}
+	attrs := m.commandAttrs(&e.CommandFinishedEvent, command)
+	attrs = append(attrs, replyCountAttrs(e.Reply)...)
+	m.options.logger.LogAttrs(ctx, slog.LevelWarn, "mongodb slow command", attrs...)
+}
+
+func (m *slogMonitor) onFailed(ctx context.Context, e *event.CommandFailedEvent) {
+	command := m.popStarted(e.RequestID)
+	attrs := m.commandAttrs(&e.CommandFinishedEvent, command)
+	attrs = append(attrs, slog.String("failure", e.Failure))
+	m.options.logger.LogAttrs(ctx, slog.LevelError, "mongodb command failed", attrs...)
+}
+
+func (m *slogMonitor) popStarted(requestID int64) *startedCommand {
+	value, ok := m.started.LoadAndDelete(requestID)
+	if !ok {
+		return &startedCommand{}
+	}
+	return value.(*startedCommand)
+}
+
+func (m *slogMonitor) commandAttrs(e *event.CommandFinishedEvent, command *startedCommand) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.Int64("request_id", e.RequestID),
+		slog.String("command_name", e.CommandName),
+		slog.String("database", command.databaseName),
+		slog.String("collection", command.collectionName),
+		slog.Duration("duration", time.Duration(e.DurationNanos)),
+		slog.String("connection_id", e.ConnectionID),
+	}
+	if len(command.command) > 0 {
+		attrs = append(attrs, slog.String("command", m.redact(command.command)))
+	}
+	return attrs
+}
+
+// extended json of the command with the configured fields redacted
+func (m *slogMonitor) redact(command bson.Raw) string {
+	doc := bson.D{}
+	if err := bson.Unmarshal(command, &doc); err != nil {
+		return ""
+	}
+	data, err := bson.MarshalExtJSON(redactValue(doc, m.options.redactedFields), false, false)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func redactValue(value interface{}, fields map[string]bool) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		result := make(bson.D, 0, len(v))
+		for _, eachElement := range v {
+			if fields[strings.ToLower(eachElement.Key)] {
+				result = append(result, bson.E{Key: eachElement.Key, Value: redactedValue})
+				continue
+			}
+			result = append(result, bson.E{Key: eachElement.Key, Value: redactValue(eachElement.Value, fields)})
+		}
+		return result
+	case bson.A:
+		result := make(bson.A, 0, len(v))
+		for _, eachItem := range v {
+			result = append(result, redactValue(eachItem, fields))
+		}
+		return result
+	}
+	return value
+}
+
+// the collection is the value of the first element for the crud commands,such as {find:"users"}
+func commandCollectionName(command bson.Raw, commandName string) string {
+	value, err := command.LookupErr(commandName)
+	if err != nil {
+		return ""
+	}
+	name, _ := value.StringValueOK()
+	return name
+}
+
+// number of affected or returned documents in the reply
+func replyCountAttrs(reply bson.Raw) []slog.Attr {
+	attrs := make([]slog.Attr, 0, 2)
+	if value, err := reply.LookupErr("n"); err == nil {
+		if n, ok := value.AsInt64OK(); ok {
+			attrs = append(attrs, slog.Int64("n", n))
+		}
+	}
+	if value, err := reply.LookupErr("nModified"); err == nil {
+		if n, ok := value.AsInt64OK(); ok {
+			attrs = append(attrs, slog.Int64("n_modified", n))
+		}
+	}
+	for _, eachBatch := range []string{"firstBatch", "nextBatch"} {
+		value, err := reply.LookupErr("cursor", eachBatch)
+		if err != nil {
+			continue
+		}
+		if docList, ok := value.ArrayOK(); ok {
+			values, _ := docList.Values()
+			attrs = append(attrs, slog.Int("returned", len(values)))
+		}
+	}
+	return attrs
+}
+
+// call the monitors one after another,nil monitors are skipped
+func chainCommandMonitor(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	list := make([]*event.CommandMonitor, 0, len(monitors))
+	for _, eachMonitor := range monitors {
+		if eachMonitor != nil {
+			list = append(list, eachMonitor)
+		}
+	}
+	if len(list) == 1 {
+		return list[0]
+	}
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, eachMonitor := range list {
+				if eachMonitor.Started != nil {
+					eachMonitor.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, eachMonitor := range list {
+				if eachMonitor.Succeeded != nil {
+					eachMonitor.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, eachMonitor := range list {
+				if eachMonitor.Failed != nil {
+					eachMonitor.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
@@ -0,0 +1,179 @@
+package mongodbr
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	mongodbrerr "github.com/shanluzhineng/mongodbr/err"
+)
+
+// a repository operation,carries the context and the result counts of the operation
+type operation struct {
+	ctx        context.Context
+	name       string
+	collection *mongo.Collection
+	filter     interface{}
+	span       Span
+	metrics    *Metrics
+	startTime  time.Time
+
+	returned int64
+	matched  int64
+	modified int64
+	deleted  int64
+	inserted int64
+	upserted int64
+}
+
+// the copy of the MongoCol running its operations within ctx,the spans of the operations
+// become the children of the span carried by ctx
+func (r *MongoCol) WithContext(ctx context.Context) *MongoCol {
+	mongoCol := *r
+	mongoCol.ctx = ctx
+	return &mongoCol
+}
+
+// the copy of the repository running its operations within ctx
+func (r *RepositoryBase) WithContext(ctx context.Context) *RepositoryBase {
+	repository := *r
+	repository.MongoCol = r.MongoCol.WithContext(ctx)
+	return &repository
+}
+
+// create the context of an operation from the parent context of the MongoCol
+func (r *MongoCol) createContext() (context.Context, context.CancelFunc) {
+	if r.ctx == nil {
+		return CreateContext(r.configuration)
+	}
+	if r.configuration == nil || r.configuration.QueryTimeout <= 0 {
+		return context.WithCancel(r.ctx)
+	}
+	return context.WithTimeout(r.ctx, r.configuration.QueryTimeout)
+}
+
+// run fn as the operation name of the collection
+func (r *MongoCol) execute(name string, filter interface{}, fn func(op *operation) error) error {
+	return r.executeWithIdempotency(name, filter, isIdempotentOperation(name), fn)
+}
+
+// run fn as the operation name of the collection,it is retried by the retry policy only if idempotent
+// returns true
+func (r *MongoCol) executeWithIdempotency(name string, filter interface{}, idempotent func() bool, fn func(op *operation) error) error {
+	ctx, cancel := r.createContext()
+	defer cancel()
+
+	return r.executeWithRetry(ctx, name, filter, idempotent, fn)
+}
+
+// run fn as the operation name of the collection within ctx,the error is classified by mongodbrerr.Wrap
+func (r *MongoCol) executeWithContext(ctx context.Context, name string, filter interface{}, fn func(op *operation) error) error {
+	op := &operation{
+		ctx:        ctx,
+		name:       name,
+		collection: r.collectionFor(ctx, name),
+		filter:     filter,
+		metrics:    r.configuration.getMetrics(),
+		startTime:  time.Now(),
+	}
+	op.start(r.configuration.getTracer())
+	err := fn(op)
+	op.finish(err)
+	if err == nil && _writeOperations[name] {
+		markWritten(ctx)
+	}
+	return mongodbrerr.Wrap(err)
+}
+
+func (op *operation) start(tracer Tracer) {
+	if tracer == nil {
+		return
+	}
+	attrs := []TraceAttribute{
+		NewTraceAttribute(TraceAttributeDbSystem, traceAttributeDbSystemMongodb),
+		NewTraceAttribute(TraceAttributeDbName, op.collection.Database().Name()),
+		NewTraceAttribute(TraceAttributeCollection, op.collection.Name()),
+		NewTraceAttribute(TraceAttributeOperation, op.name),
+	}
+	if shape := FilterShape(op.filter); len(shape) > 0 {
+		attrs = append(attrs, NewTraceAttribute(TraceAttributeFilterShape, shape))
+	}
+	ctx, span := tracer.Start(op.ctx, op.name+" "+op.collection.Name(), attrs...)
+	if span == nil {
+		return
+	}
+	op.ctx = ContextWithSpan(ctx, span)
+	op.span = span
+}
+
+func (op *operation) finish(err error) {
+	op.metrics.observeOperation(op, time.Since(op.startTime), err)
+	if op.span == nil {
+		return
+	}
+	attrs := make([]TraceAttribute, 0, 6)
+	for _, eachCount := range []struct {
+		key   string
+		value int64
+	}{
+		{TraceAttributeReturned, op.returned},
+		{TraceAttributeMatched, op.matched},
+		{TraceAttributeModified, op.modified},
+		{TraceAttributeDeleted, op.deleted},
+		{TraceAttributeInserted, op.inserted},
+		{TraceAttributeUpserted, op.upserted},
+	} {
+		if eachCount.value > 0 {
+			attrs = append(attrs, NewTraceAttribute(eachCount.key, eachCount.value))
+		}
+	}
+	if len(attrs) > 0 {
+		op.span.SetAttributes(attrs...)
+	}
+	if err != nil {
+		op.span.RecordError(err)
+	}
+	op.span.End()
+}
+
+// #region result counts
+
+func (op *operation) setUpdateResult(result *mongo.UpdateResult) {
+	if result == nil {
+		return
+	}
+	op.matched = result.MatchedCount
+	op.modified = result.ModifiedCount
+	op.upserted = result.UpsertedCount
+}
+
+func (op *operation) setDeleteResult(result *mongo.DeleteResult) {
+	if result == nil {
+		return
+	}
+	op.deleted = result.DeletedCount
+}
+
+func (op *operation) setBulkWriteResult(result *mongo.BulkWriteResult) {
+	if result == nil {
+		return
+	}
+	op.inserted = result.InsertedCount
+	op.matched = result.MatchedCount
+	op.modified = result.ModifiedCount
+	op.deleted = result.DeletedCount
+	op.upserted = result.UpsertedCount
+}
+
+// #endregion
+
+// length of the slice pointed by v,0 if v is not a pointer to a slice
+func sliceLen(v interface{}) int {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Slice {
+		return 0
+	}
+	return value.Elem().Len()
+}
@@ -2,23 +2,32 @@ package mongodbr
 
 import (
 	"context"
-	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"go.mongodb.org/mongo-driver/event"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+)
+
+const (
+	// key of DefaultClient in the metrics and the health reports
+	DefaultClientKey = "default"
 )
 
 var (
 	DefaultConfiguration = NewConfiguration()
 	//默认的client
 	DefaultClient *mongo.Client
-	_cachedClient map[string]*mongo.Client = make(map[string]*mongo.Client)
 )
 
 // enable mongodb monitor
+//
+// Deprecated: it prints every command and reply including the document contents,use EnableSlogMonitor
 func EnableMongodbMonitor() func(*options.ClientOptions) {
 	return func(co *options.ClientOptions) {
 		monitor := &event.CommandMonitor{
@@ -37,9 +46,9 @@ func EnableMongodbMonitor() func(*options.ClientOptions) {
 	}
 }
 
-// 构建默认的client
+// 构建默认的client,registered in DefaultClientRegistry as DefaultClientKey
 func SetupDefaultClient(uri string, opts ...func(*options.ClientOptions)) (*mongo.Client, error) {
-	client, err := createClient(uri, opts...)
+	client, err := RegistClient(DefaultClientKey, uri, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -47,34 +56,67 @@ func SetupDefaultClient(uri string, opts ...func(*options.ClientOptions)) (*mong
 	return DefaultClient, nil
 }
 
+// connect the client and register it as key in DefaultClientRegistry,nothing is registered if it
+// cannot be connected.the client previously registered as key stays connected for the repositories
+// created from it,it is disconnected by CloseAllClients
 func RegistClient(key string, uri string, opts ...func(*options.ClientOptions)) (*mongo.Client, error) {
-	client, err := createClient(uri, opts...)
-	if err != nil {
-		return nil, err
-	}
-	_cachedClient[key] = client
-	return client, nil
+	return DefaultClientRegistry.Swap(key, uri, opts...)
 }
 
 // get client by key
 func GetClient(key string) *mongo.Client {
-	client, ok := _cachedClient[key]
-	if !ok {
+	client, err := DefaultClientRegistry.Get(key)
+	if err != nil {
 		return nil
 	}
 	return client
 }
 
-func createClient(uri string, opts ...func(*options.ClientOptions)) (*mongo.Client, error) {
+// disconnect every client of DefaultClientRegistry,used for graceful shutdown
+func CloseAllClients(ctx context.Context) error {
+	if client, ok := DefaultClientRegistry.Clients()[DefaultClientKey]; ok && client == DefaultClient {
+		DefaultClient = nil
+	}
+	return DefaultClientRegistry.CloseAll(ctx)
+}
+
+// keys of DefaultClient and the clients of DefaultClientRegistry including the ones not connected
+// yet,sorted
+func registeredClientKeys() []string {
+	keyList := DefaultClientRegistry.Keys()
+	if DefaultClient == nil {
+		return keyList
+	}
+	for _, eachKey := range keyList {
+		if eachKey == DefaultClientKey {
+			return keyList
+		}
+	}
+	keyList = append(keyList, DefaultClientKey)
+	sort.Strings(keyList)
+	return keyList
+}
+
+// DefaultClient or the client of key in DefaultClientRegistry,it is connected if not yet
+func registeredClient(key string) (*mongo.Client, error) {
+	if key == DefaultClientKey && DefaultClient != nil {
+		return DefaultClient, nil
+	}
+	return DefaultClientRegistry.Get(key)
+}
+
+func createClient(key string, uri string, opts ...func(*options.ClientOptions)) (*mongo.Client, error) {
 	//测试能否连接
 	clientOptions := options.Client().ApplyURI(uri)
 	for _, eachOpt := range opts {
 		eachOpt(clientOptions)
 	}
+	//连接池的指标
+	DefaultMetrics.enableClientMetrics(key)(clientOptions)
 
 	client, err := mongo.Connect(context.TODO(), clientOptions)
 	if err != nil {
-		return nil, fmt.Errorf("无法初始化mongodb,在连接到mongodb时出现异常,异常信息:%s", err.Error())
+		return nil, newError(ErrorCodeConnectFailed, err, "client", key)
 	}
 	return client, nil
 }
@@ -86,6 +128,22 @@ type Configuration struct {
 	createItemFunc func() interface{}
 	//查询时设置默认的排序
 	setDefaultSort func(*options.FindOptions) *options.FindOptions
+	//记录查询的形状,用于分析索引
+	indexAdvisor *IndexAdvisor
+	//读取时升级文档的schema版本
+	schemaUpgrader *SchemaUpgrader
+	//跟踪仓储的操作
+	tracer Tracer
+	//记录仓储操作的指标
+	metrics *Metrics
+	//读写的一致性设置
+	readPreference *readpref.ReadPref
+	readConcern    *readconcern.ReadConcern
+	writeConcern   *writeconcern.WriteConcern
+	//读写分离时用于读取的collection
+	readCollection *mongo.Collection
+	//失败时重试操作
+	retryPolicy *RetryPolicy
 }
 
 func CreateContext(c *Configuration) (context.Context, context.CancelFunc) {
@@ -0,0 +1,115 @@
+// Package oteltrace adapts an OpenTelemetry TracerProvider to the mongodbr.Tracer interface
+package oteltrace
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shanluzhineng/mongodbr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// name of the opentelemetry instrumentation
+	InstrumentationName = "github.com/shanluzhineng/mongodbr"
+)
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+var _ mongodbr.Tracer = (*tracer)(nil)
+
+type tracerOptions struct {
+	provider trace.TracerProvider
+}
+
+type TracerOption func(*tracerOptions)
+
+// provider used to create the tracer,default is the global provider
+func WithTracerProvider(provider trace.TracerProvider) TracerOption {
+	return func(o *tracerOptions) {
+		if provider != nil {
+			o.provider = provider
+		}
+	}
+}
+
+// new a mongodbr.Tracer which starts client spans with the opentelemetry tracer
+func NewTracer(opts ...TracerOption) mongodbr.Tracer {
+	o := &tracerOptions{}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	if o.provider == nil {
+		o.provider = otel.GetTracerProvider()
+	}
+	return &tracer{
+		tracer: o.provider.Tracer(InstrumentationName),
+	}
+}
+
+func (t *tracer) Start(ctx context.Context, spanName string, attrs ...mongodbr.TraceAttribute) (context.Context, mongodbr.Span) {
+	ctx, s := t.tracer.Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(toKeyValues(attrs)...))
+	return ctx, &span{span: s}
+}
+
+type span struct {
+	span trace.Span
+}
+
+var _ mongodbr.Span = (*span)(nil)
+
+func (s *span) SetAttributes(attrs ...mongodbr.TraceAttribute) {
+	s.span.SetAttributes(toKeyValues(attrs)...)
+}
+
+func (s *span) AddEvent(name string, attrs ...mongodbr.TraceAttribute) {
+	s.span.AddEvent(name, trace.WithAttributes(toKeyValues(attrs)...))
+}
+
+func (s *span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+func (s *span) End() {
+	s.span.End()
+}
+
+func toKeyValues(attrs []mongodbr.TraceAttribute) []attribute.KeyValue {
+	keyValues := make([]attribute.KeyValue, 0, len(attrs))
+	for _, eachAttr := range attrs {
+		keyValues = append(keyValues, toKeyValue(eachAttr))
+	}
+	return keyValues
+}
+
+func toKeyValue(attr mongodbr.TraceAttribute) attribute.KeyValue {
+	key := attribute.Key(attr.Key)
+	switch v := attr.Value.(type) {
+	case string:
+		return key.String(v)
+	case bool:
+		return key.Bool(v)
+	case int:
+		return key.Int(v)
+	case int32:
+		return key.Int64(int64(v))
+	case int64:
+		return key.Int64(v)
+	case float64:
+		return key.Float64(v)
+	case []string:
+		return key.StringSlice(v)
+	}
+	return key.String(fmt.Sprint(attr.Value))
+}
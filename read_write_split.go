@@ -0,0 +1,103 @@
+package mongodbr
+
+import (
+	"context"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	// operations routed to the read collection
+	_readOperations = map[string]bool{
+		"count":                  true,
+		"estimatedDocumentCount": true,
+		"find":                   true,
+		"findOne":                true,
+		"distinct":               true,
+		"aggregate":              true,
+	}
+	// operations which modify documents
+	_writeOperations = map[string]bool{
+		"insertOne":        true,
+		"insertMany":       true,
+		"updateOne":        true,
+		"updateMany":       true,
+		"replaceOne":       true,
+		"deleteOne":        true,
+		"deleteMany":       true,
+		"findOneAndUpdate": true,
+		"bulkWrite":        true,
+	}
+)
+
+// route the finds,counts,distinct and aggregates of the repository to coll,such as the same
+// collection of another cluster or a hidden member
+func WithReadCollection(coll *mongo.Collection) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.readCollection = coll
+	}
+}
+
+// bind the repository created by NewRepository to the client of key for reads,the client
+// specified by RepositoryOptionWithClientKey or DefaultClient is used for writes
+func RepositoryOptionWithReadClientKey(key string) func(*NewRepositoryOption) {
+	return func(nro *NewRepositoryOption) {
+		nro.readClientKey = key
+	}
+}
+
+type readYourWritesKey struct{}
+
+// read your writes scope of a request
+type readYourWritesScope struct {
+	written atomic.Bool
+}
+
+// start a read your writes scope,once a repository running within ctx writes successfully,its
+// following reads within ctx go to the write side
+func WithReadYourWrites(ctx context.Context) context.Context {
+	if readYourWritesFromContext(ctx) != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, readYourWritesKey{}, &readYourWritesScope{})
+}
+
+func readYourWritesFromContext(ctx context.Context) *readYourWritesScope {
+	if ctx == nil {
+		return nil
+	}
+	scope, _ := ctx.Value(readYourWritesKey{}).(*readYourWritesScope)
+	return scope
+}
+
+// the collection serving the operation name within ctx
+func (r *MongoCol) collectionFor(ctx context.Context, name string) *mongo.Collection {
+	if r.readCollection == nil || !_readOperations[name] {
+		return r.collection
+	}
+	if scope := readYourWritesFromContext(ctx); scope != nil && scope.written.Load() {
+		return r.collection
+	}
+	return r.readCollection
+}
+
+// pin the following reads within ctx to the write side
+func markWritten(ctx context.Context) {
+	if scope := readYourWritesFromContext(ctx); scope != nil {
+		scope.written.Store(true)
+	}
+}
+
+// the pipeline ends with $out or $merge
+func isWritePipeline(pipeline interface{}) bool {
+	stageList, ok := toStageList(pipeline)
+	if !ok || len(stageList) <= 0 {
+		return false
+	}
+	lastStage := stageList[len(stageList)-1]
+	if len(lastStage) <= 0 {
+		return false
+	}
+	return lastStage[0].Key == "$out" || lastStage[0].Key == "$merge"
+}
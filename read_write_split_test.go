@@ -0,0 +1,34 @@
+package mongodbr
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+func TestAggregateFailedWriteKeepsReadSide(t *testing.T) {
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI("mongodb://localhost:1/?serverSelectionTimeoutMS=100"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	database := client.Database("shop")
+	repository, err := NewRepositoryBase(func() *mongo.Collection {
+		return database.Collection("orders")
+	}, WithReadCollection(database.Collection("orders")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithReadYourWrites(context.Background())
+	pipeline := bson.A{bson.M{"$match": bson.M{"status": "paid"}}, bson.M{"$out": "paid_orders"}}
+	if err := repository.WithContext(ctx).Aggregate(pipeline, &[]bson.M{}); err == nil {
+		t.Fatal("expect the aggregate to fail without a server")
+	}
+	if readYourWritesFromContext(ctx).written.Load() {
+		t.Fatal("a failed $out must not send the following reads to the write side")
+	}
+}
@@ -1,8 +1,6 @@
 package mongodbr
 
 import (
-	"fmt"
-
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -10,9 +8,12 @@ import (
 
 type NewRepositoryOption struct {
 	clientKey        string
+	readClientKey    string
 	databaseName     string
 	collectionName   string
 	DefaultSortField string
+
+	repositoryOptions []RepositoryOption
 }
 
 func newDefaultRepositoryOption() *NewRepositoryOption {
@@ -27,14 +28,16 @@ func RepositoryOptionWithClientKey(clientKey string) func(*NewRepositoryOption)
 	}
 }
 
-func NewRepository(databaseName string, collectionName string, opts ...func(*NewRepositoryOption)) (*RepositoryBase, error) {
-	if len(databaseName) <= 0 {
-		err := fmt.Errorf("database参数不能为nil")
-		return nil, err
+// apply RepositoryOption to the configuration of the repository
+func RepositoryOptionWithConfiguration(opts ...RepositoryOption) func(*NewRepositoryOption) {
+	return func(nro *NewRepositoryOption) {
+		nro.repositoryOptions = append(nro.repositoryOptions, opts...)
 	}
+}
+
+func NewRepository(databaseName string, collectionName string, opts ...func(*NewRepositoryOption)) (*RepositoryBase, error) {
 	if len(collectionName) <= 0 {
-		err := fmt.Errorf("collectionName参数不能为nil")
-		return nil, err
+		return nil, ErrEmptyCollectionName
 	}
 	o := newDefaultRepositoryOption()
 	o.databaseName = databaseName
@@ -42,18 +45,44 @@ func NewRepository(databaseName string, collectionName string, opts ...func(*New
 	for _, eachOpt := range opts {
 		eachOpt(o)
 	}
+	clientKey := o.clientKey
+	if len(clientKey) <= 0 {
+		clientKey = DefaultClientKey
+	}
+	//使用配置中client的默认数据库
+	if len(o.databaseName) <= 0 {
+		o.databaseName = configuredDefaultDatabase(clientKey)
+	}
+	if len(o.databaseName) <= 0 {
+		return nil, ErrEmptyDatabaseName
+	}
 	var collection *mongo.Collection
 	if len(o.clientKey) <= 0 {
 		collection = GetCollection(o.databaseName, o.collectionName)
 	} else {
 		collection = GetCollectionByKey(o.clientKey, o.databaseName, o.collectionName)
 	}
-	mongodbrOpts := make([]RepositoryOption, 0)
+	if collection == nil {
+		return nil, newError(ErrorCodeClientNotRegistered, ErrClientNotRegistered, "client", clientKey)
+	}
+	mongodbrOpts, err := configuredRepositoryOptions(clientKey, o.databaseName, o.collectionName)
+	if err != nil {
+		return nil, err
+	}
+	//读写分离
+	if len(o.readClientKey) > 0 {
+		readCollection := GetCollectionByKey(o.readClientKey, o.databaseName, o.collectionName)
+		if readCollection == nil {
+			return nil, newError(ErrorCodeReadClientNotRegistered, nil, "client", o.readClientKey)
+		}
+		mongodbrOpts = append(mongodbrOpts, WithReadCollection(readCollection))
+	}
 	if len(o.DefaultSortField) > 0 {
 		mongodbrOpts = append(mongodbrOpts, WithDefaultSort(func(fo *options.FindOptions) *options.FindOptions {
 			return fo.SetSort(bson.D{{Key: o.DefaultSortField, Value: -1}})
 		}))
 	}
+	mongodbrOpts = append(mongodbrOpts, o.repositoryOptions...)
 	repositoryBase, err := NewRepositoryBase(func() *mongo.Collection {
 		return collection
 	}, mongodbrOpts...)
@@ -52,3 +52,16 @@ func FindOneTByFilter[T any](repository IRepository, filter interface{}, opts ..
 	}
 	return result, nil
 }
+
+// find a page of t by filter,the items of the returned PageResult are decoded into list
+func FindPageT[T any](repository IRepository, filter interface{}, pageIndex int64, pageSize int64, opts ...PageOption) ([]T, *PageResult, error) {
+	page, err := repository.FindPage(filter, pageIndex, pageSize, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	list := make([]T, 0, len(page.rawList))
+	if err := page.Decode(&list); err != nil {
+		return nil, nil, err
+	}
+	return list, page, nil
+}
@@ -0,0 +1,16 @@
+package mongodbr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewRepositoryClientNotRegistered(t *testing.T) {
+	_, err := NewRepository("shop", "products", RepositoryOptionWithClientKey("client-not-registered"))
+	if !errors.Is(err, &Error{Code: ErrorCodeClientNotRegistered}) {
+		t.Fatalf("expect ErrorCodeClientNotRegistered,got %v", err)
+	}
+	if !errors.Is(err, ErrClientNotRegistered) {
+		t.Fatalf("expect the cause ErrClientNotRegistered,got %v", err)
+	}
+}
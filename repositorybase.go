@@ -1,8 +1,8 @@
 package mongodbr
 
 import (
+	"context"
 	"errors"
-	"fmt"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -13,9 +13,13 @@ import (
 type MongoCol struct {
 	configuration *Configuration
 	collection    *mongo.Collection
+	//serve the reads if set
+	readCollection *mongo.Collection
+	//parent context of the operations,set by WithContext
+	ctx context.Context
 }
 
-// new MongoCol instance, panic if col is nil
+// new MongoCol instance, panic if col is nil or the collection options cannot be applied
 func NewMongoCol(col *mongo.Collection, opts ...*Configuration) *MongoCol {
 	if col == nil {
 		panic(errors.New("col cannot be nil"))
@@ -26,7 +30,9 @@ func NewMongoCol(col *mongo.Collection, opts ...*Configuration) *MongoCol {
 	}
 	mongoCol := &MongoCol{
 		configuration: c,
-		collection:    col,
+	}
+	if err := mongoCol.applyCollectionOptions(col, c.readCollection); err != nil {
+		panic(err)
 	}
 	return mongoCol
 }
@@ -42,10 +48,12 @@ var _ IRepository = (*RepositoryBase)(nil)
 // new一个新的实例
 func NewRepositoryBase(getDbCollection func() *mongo.Collection, opts ...RepositoryOption) (*RepositoryBase, error) {
 	if getDbCollection == nil {
-		err := fmt.Errorf("getDbCollection参数不能为nil")
-		return nil, err
+		return nil, ErrNilCollectionGetter
 	}
 	coll := getDbCollection()
+	if coll == nil {
+		return nil, ErrNilCollection
+	}
 	repository := &RepositoryBase{
 		MongoCol:     NewMongoCol(coll),
 		documentName: coll.Name(),
@@ -53,39 +61,65 @@ func NewRepositoryBase(getDbCollection func() *mongo.Collection, opts ...Reposit
 	for _, eachItem := range opts {
 		eachItem(repository.configuration)
 	}
+	if err := repository.applyCollectionOptions(coll, repository.configuration.readCollection); err != nil {
+		return nil, err
+	}
 	return repository, nil
 }
 
 // aggregate
 func (r *RepositoryBase) Aggregate(pipeline interface{}, dataList interface{}, opts ...AggregateOption) (err error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
 	//设置默认搜索参数
 	aggregateOptions := options.Aggregate()
 	for _, o := range opts {
 		o(aggregateOptions)
 	}
-	cur, err := r.collection.Aggregate(ctx, pipeline, aggregateOptions)
-	if err != nil {
-		return err
+	if r.configuration.indexAdvisor != nil {
+		r.configuration.indexAdvisor.RecordPipeline(r.collection, pipeline)
 	}
-	defer cur.Close(ctx)
+	return r.executeWithIdempotency("aggregate", pipeline, func() bool { return isIdempotentPipeline(pipeline) }, func(op *operation) error {
+		collection := op.collection
+		//$out and $merge write to the write side
+		writing := r.readCollection != nil && isWritePipeline(pipeline)
+		if writing {
+			collection = r.collection
+		}
+		cur, err := collection.Aggregate(op.ctx, pipeline, aggregateOptions)
+		if err != nil {
+			return err
+		}
+		if writing {
+			markWritten(op.ctx)
+		}
+		defer cur.Close(op.ctx)
 
-	return cur.All(ctx, dataList)
+		if err := cur.All(op.ctx, dataList); err != nil {
+			return err
+		}
+		op.returned = int64(sliceLen(dataList))
+		return nil
+	})
 }
 
 // #region create members
 
 func (r *RepositoryBase) Create(item interface{}, opts ...*options.InsertOneOptions) (id primitive.ObjectID, err error) {
 	if item == nil {
-		return primitive.NilObjectID, fmt.Errorf("item is nil,col:%s", r.documentName)
+		return primitive.NilObjectID, newError(ErrorCodeNilItem, nil, "collection", r.documentName)
 	}
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
 	r.onBeforeCreate(item)
-	res, err := r.collection.InsertOne(ctx, item, opts...)
+	doc, err := r.configuration.stampSchemaVersion(item)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	var res *mongo.InsertOneResult
+	err = r.execute("insertOne", nil, func(op *operation) (err error) {
+		res, err = op.collection.InsertOne(op.ctx, doc, opts...)
+		if err == nil {
+			op.inserted = 1
+		}
+		return err
+	})
 	if err != nil {
 		return primitive.NilObjectID, err
 	}
@@ -99,13 +133,23 @@ func (r *RepositoryBase) CreateMany(itemList []interface{}, opts ...*options.Ins
 	if len(itemList) <= 0 {
 		return nil, nil
 	}
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
+	docList := make([]interface{}, 0, len(itemList))
 	for index := range itemList {
 		r.onBeforeCreate(itemList[index])
+		doc, err := r.configuration.stampSchemaVersion(itemList[index])
+		if err != nil {
+			return nil, err
+		}
+		docList = append(docList, doc)
 	}
-	res, err := r.collection.InsertMany(ctx, itemList, opts...)
+	var res *mongo.InsertManyResult
+	err = r.execute("insertMany", nil, func(op *operation) (err error) {
+		res, err = op.collection.InsertMany(op.ctx, docList, opts...)
+		if res != nil {
+			op.inserted = int64(len(res.InsertedIDs))
+		}
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -127,35 +171,31 @@ func (r *RepositoryBase) ReplaceById(id primitive.ObjectID, doc interface{}, opt
 }
 
 func (r *RepositoryBase) Replace(filter interface{}, doc interface{}, opts ...*options.ReplaceOptions) (err error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
-	_, err = r.collection.ReplaceOne(ctx, filter, doc, opts...)
+	doc, err = r.configuration.stampSchemaVersion(doc)
 	if err != nil {
 		return err
 	}
-	return nil
+	idempotent := func() bool { return isIdempotentReplace(filter, options.MergeReplaceOptions(opts...).Upsert) }
+	return r.executeWithIdempotency("replaceOne", filter, idempotent, func(op *operation) error {
+		result, err := op.collection.ReplaceOne(op.ctx, filter, doc, opts...)
+		op.setUpdateResult(result)
+		return err
+	})
 }
 
 // 删除指定id的记录
 func (r *RepositoryBase) DeleteOne(id primitive.ObjectID, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
-	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
-	if err != nil {
-		return result, err
-	}
-
-	return result, nil
+	return r.DeleteOneByFilter(bson.M{"_id": id}, opts...)
 }
 
 // 删除指定条件的一条记录
 func (r *RepositoryBase) DeleteOneByFilter(filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
-	result, err := r.collection.DeleteOne(ctx, filter, opts...)
+	var result *mongo.DeleteResult
+	err := r.executeWithIdempotency("deleteOne", filter, func() bool { return isIdempotentDeleteOne(filter) }, func(op *operation) (err error) {
+		result, err = op.collection.DeleteOne(op.ctx, filter, opts...)
+		op.setDeleteResult(result)
+		return err
+	})
 	if err != nil {
 		return result, err
 	}
@@ -166,13 +206,14 @@ func (r *RepositoryBase) DeleteOneByFilter(filter interface{}, opts ...*options.
 // 删除多条记录
 func (r *RepositoryBase) DeleteMany(filter interface{}, opts ...*options.DeleteOptions) (*mongo.DeleteResult, error) {
 	if filter == nil {
-		err := fmt.Errorf("无法删除多条%s记录,filter参数不能为null", r.documentName)
-		return nil, err
+		return nil, newError(ErrorCodeNilFilter, nil, "collection", r.documentName)
 	}
-	ctx, cancel := CreateContext(r.configuration)
-	defer cancel()
-
-	result, err := r.collection.DeleteMany(ctx, filter, opts...)
+	var result *mongo.DeleteResult
+	err := r.execute("deleteMany", filter, func(op *operation) (err error) {
+		result, err = op.collection.DeleteMany(op.ctx, filter, opts...)
+		op.setDeleteResult(result)
+		return err
+	})
 	if err != nil {
 		return result, err
 	}
@@ -0,0 +1,205 @@
+package mongodbr
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	mongodbrerr "github.com/shanluzhineng/mongodbr/err"
+)
+
+var (
+	// operations which can run again without changing the result,the aggregations,replaceOne and
+	// deleteOne depend on their arguments and are checked by their callers
+	_idempotentOperations = map[string]bool{
+		"count":                  true,
+		"estimatedDocumentCount": true,
+		"find":                   true,
+		"findOne":                true,
+		"distinct":               true,
+		"deleteMany":             true,
+	}
+	// update operators which can be applied again without changing the document
+	_idempotentUpdateOperators = map[string]bool{
+		"$set":         true,
+		"$unset":       true,
+		"$setOnInsert": true,
+		"$currentDate": true,
+		"$min":         true,
+		"$max":         true,
+		"$addToSet":    true,
+		"$pull":        true,
+		"$pullAll":     true,
+	}
+)
+
+// RetryPolicy runs the find,write,bulk and aggregate operations again when they fail with a
+// retryable error.
+// the non idempotent operations,such as inserts,updates with $inc or $push,deleteOne and upserts
+// not by _id and the pipelines with $out or $merge,are not retried unless RetryNonIdempotent is set,
+// they may be applied twice if the failed attempt reached the server
+type RetryPolicy struct {
+	// attempts including the first one,the policy is disabled if it is less than 2
+	MaxAttempts int
+	// delay before the first retry,it is multiplied by Multiplier for the following retries
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	// the delay is reduced by a random part of Jitter*delay,0 to 1
+	Jitter float64
+	// whether err is retryable,default is mongodbrerr.IsRetryable
+	Retryable func(err error) bool
+	// retry inserts,updates with non idempotent operators and pipeline updates
+	RetryNonIdempotent bool
+	// called before waiting for the retry,attempt is the failed attempt starting from 1
+	OnRetry func(operation string, attempt int, err error, delay time.Duration)
+}
+
+// 3 attempts,backoff from 100ms to 2s with 50% jitter
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+	}
+}
+
+// retry the operations of the repository by policy,nil disables the retries
+func WithRetryPolicy(policy *RetryPolicy) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.retryPolicy = policy
+	}
+}
+
+func (p *RetryPolicy) enabled() bool {
+	return p != nil && p.MaxAttempts > 1
+}
+
+func (p *RetryPolicy) isRetryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return mongodbrerr.IsRetryable(err)
+}
+
+// the delay before the retry of the attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if jitter := math.Min(math.Max(p.Jitter, 0), 1); jitter > 0 {
+		delay -= jitter * delay * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// run the operation until it succeeds,fails with an error not retryable or the attempts are used up.
+// idempotent is called only when the policy is enabled
+func (r *MongoCol) executeWithRetry(ctx context.Context, name string, filter interface{}, idempotent func() bool, fn func(op *operation) error) error {
+	policy := r.configuration.retryPolicy
+	if !policy.enabled() || (!policy.RetryNonIdempotent && !idempotent()) {
+		return r.executeWithContext(ctx, name, filter, fn)
+	}
+	for attempt := 1; ; attempt++ {
+		err := r.executeWithContext(ctx, name, filter, fn)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.isRetryable(err) {
+			return err
+		}
+		delay := policy.backoff(attempt)
+		if policy.OnRetry != nil {
+			policy.OnRetry(name, attempt, err, delay)
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// #region idempotency
+
+func isIdempotentOperation(name string) func() bool {
+	return func() bool {
+		return _idempotentOperations[name]
+	}
+}
+
+// the update only uses the idempotent operators,a pipeline update is not idempotent
+func isIdempotentUpdate(update interface{}) bool {
+	updateDoc, ok := toBsonD(update)
+	if !ok {
+		return false
+	}
+	for _, eachElement := range updateDoc {
+		if !_idempotentUpdateOperators[eachElement.Key] {
+			return false
+		}
+	}
+	return true
+}
+
+// the pipeline is known and has no $out or $merge stage
+func isIdempotentPipeline(pipeline interface{}) bool {
+	if _, ok := toStageList(pipeline); !ok {
+		return false
+	}
+	return !isWritePipeline(pipeline)
+}
+
+// deleteOne by another filter deletes one more document when it runs again
+func isIdempotentDeleteOne(filter interface{}) bool {
+	_, ok := idOfFilter(filter)
+	return ok
+}
+
+// an upsert by another filter than _id inserts again if the replacement does not match the filter
+func isIdempotentReplace(filter interface{}, upsert *bool) bool {
+	if upsert == nil || !*upsert {
+		return true
+	}
+	_, ok := idOfFilter(filter)
+	return ok
+}
+
+// every model can be applied again,the inserts are not idempotent
+func isIdempotentWriteModels(models []mongo.WriteModel) bool {
+	for _, eachModel := range models {
+		switch model := eachModel.(type) {
+		case *mongo.UpdateOneModel:
+			if !isIdempotentUpdate(model.Update) {
+				return false
+			}
+		case *mongo.UpdateManyModel:
+			if !isIdempotentUpdate(model.Update) {
+				return false
+			}
+		case *mongo.ReplaceOneModel:
+			if !isIdempotentReplace(model.Filter, model.Upsert) {
+				return false
+			}
+		case *mongo.DeleteOneModel:
+			if !isIdempotentDeleteOne(model.Filter) {
+				return false
+			}
+		case *mongo.DeleteManyModel:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// #endregion
@@ -0,0 +1,56 @@
+package mongodbr
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestIsIdempotentWriteModels(t *testing.T) {
+	id := primitive.NewObjectID()
+	testCases := []struct {
+		name   string
+		model  mongo.WriteModel
+		expect bool
+	}{
+		{"update $set", mongo.NewUpdateOneModel().SetFilter(bson.M{"sku": "a"}).SetUpdate(bson.M{"$set": bson.M{"price": 1}}), true},
+		{"update $inc", mongo.NewUpdateOneModel().SetFilter(bson.M{"sku": "a"}).SetUpdate(bson.M{"$inc": bson.M{"stock": 1}}), false},
+		{"insert", mongo.NewInsertOneModel().SetDocument(bson.M{"sku": "a"}), false},
+		{"deleteOne by _id", mongo.NewDeleteOneModel().SetFilter(bson.M{"_id": id}), true},
+		{"deleteOne by filter", mongo.NewDeleteOneModel().SetFilter(bson.M{"sku": "a"}), false},
+		{"deleteOne by _id operator", mongo.NewDeleteOneModel().SetFilter(bson.M{"_id": bson.M{"$in": bson.A{id}}}), false},
+		{"deleteMany", mongo.NewDeleteManyModel().SetFilter(bson.M{"sku": "a"}), true},
+		{"replace", mongo.NewReplaceOneModel().SetFilter(bson.M{"sku": "a"}).SetReplacement(bson.M{"sku": "a"}), true},
+		{"replace upsert by _id", mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": id}).SetReplacement(bson.M{}).SetUpsert(true), true},
+		{"replace upsert by filter", mongo.NewReplaceOneModel().SetFilter(bson.M{"sku": "a"}).SetReplacement(bson.M{}).SetUpsert(true), false},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			if actual := isIdempotentWriteModels([]mongo.WriteModel{eachCase.model}); actual != eachCase.expect {
+				t.Errorf("expect %v,got %v", eachCase.expect, actual)
+			}
+		})
+	}
+}
+
+func TestIsIdempotentPipeline(t *testing.T) {
+	testCases := []struct {
+		name     string
+		pipeline interface{}
+		expect   bool
+	}{
+		{"read", mongo.Pipeline{{{Key: "$match", Value: bson.M{"a": 1}}}}, true},
+		{"$out", mongo.Pipeline{{{Key: "$match", Value: bson.M{}}}, {{Key: "$out", Value: "other"}}}, false},
+		{"$merge", bson.A{bson.M{"$merge": bson.M{"into": "other"}}}, false},
+		{"unknown", 1, false},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			if actual := isIdempotentPipeline(eachCase.pipeline); actual != eachCase.expect {
+				t.Errorf("expect %v,got %v", eachCase.expect, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,350 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// field storing the schema version of a document,documents without it are version 1
+	DefaultSchemaVersionField = "schemaVersion"
+)
+
+// upgrade doc from version n to n+1 in place
+type SchemaUpgradeFunc func(doc bson.M) error
+
+// SchemaUpgrader upgrades documents to the current schema version when they are read
+type SchemaUpgrader struct {
+	field     string
+	upgraders map[int]SchemaUpgradeFunc
+	current   int
+	writeBack bool
+}
+
+type SchemaUpgraderOption func(*SchemaUpgrader)
+
+// field of the schema version,default is schemaVersion
+func SchemaUpgraderWithField(field string) SchemaUpgraderOption {
+	return func(u *SchemaUpgrader) {
+		if len(field) > 0 {
+			u.field = field
+		}
+	}
+}
+
+// write the upgraded fields back after the document is upgraded on read
+func SchemaUpgraderWithWriteBack() SchemaUpgraderOption {
+	return func(u *SchemaUpgrader) {
+		u.writeBack = true
+	}
+}
+
+func NewSchemaUpgrader(opts ...SchemaUpgraderOption) *SchemaUpgrader {
+	u := &SchemaUpgrader{
+		field:     DefaultSchemaVersionField,
+		upgraders: make(map[int]SchemaUpgradeFunc),
+		current:   1,
+	}
+	for _, eachOpt := range opts {
+		eachOpt(u)
+	}
+	return u
+}
+
+// regist the upgrade from fromVersion to fromVersion+1
+func (u *SchemaUpgrader) Register(fromVersion int, fn SchemaUpgradeFunc) *SchemaUpgrader {
+	if fromVersion < 1 || fn == nil {
+		panic(fmt.Errorf("invalid schema upgrader from version %d", fromVersion))
+	}
+	u.upgraders[fromVersion] = fn
+	if fromVersion+1 > u.current {
+		u.current = fromVersion + 1
+	}
+	return u
+}
+
+// the version written to new documents
+func (u *SchemaUpgrader) CurrentVersion() int {
+	return u.current
+}
+
+func (u *SchemaUpgrader) Field() string {
+	return u.field
+}
+
+// upgrade doc to the current version,return the version before the upgrade
+func (u *SchemaUpgrader) Upgrade(doc bson.M) (int, error) {
+	version := u.versionOf(doc)
+	for v := version; v < u.current; v++ {
+		fn, ok := u.upgraders[v]
+		if !ok {
+			return version, fmt.Errorf("no schema upgrader from version %d", v)
+		}
+		if err := fn(doc); err != nil {
+			return version, fmt.Errorf("upgrade schema from version %d failed: %w", v, err)
+		}
+		doc[u.field] = v + 1
+	}
+	return version, nil
+}
+
+func (u *SchemaUpgrader) versionOf(doc bson.M) int {
+	switch v := doc[u.field].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 1
+}
+
+// filter of the documents stored with the version
+func (u *SchemaUpgrader) versionFilter(version int) bson.M {
+	if version <= 1 {
+		return bson.M{"$or": bson.A{
+			bson.M{u.field: bson.M{"$exists": false}},
+			bson.M{u.field: bson.M{"$lte": 1}},
+		}}
+	}
+	return bson.M{u.field: version}
+}
+
+// upgrade raw to the current version,document keeps the field order of raw and the new fields
+// are appended in the order of their names.
+// update $sets the changed fields and $unsets the removed ones,it is nil if raw is not upgraded
+func (u *SchemaUpgrader) upgradeDocument(raw bson.Raw) (document bson.D, update bson.D, oldVersion int, err error) {
+	original := bson.D{}
+	before := bson.M{}
+	doc := bson.M{}
+	for _, eachVal := range []interface{}{&original, &before, &doc} {
+		if err := bson.Unmarshal(raw, eachVal); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	oldVersion, err = u.Upgrade(doc)
+	if err != nil || oldVersion >= u.current {
+		return original, nil, oldVersion, err
+	}
+	if !reflect.DeepEqual(doc["_id"], before["_id"]) {
+		return nil, nil, oldVersion, errors.New("schema upgrader cannot change _id")
+	}
+
+	document = make(bson.D, 0, len(doc))
+	setDocument := bson.D{}
+	unsetDocument := bson.D{}
+	for _, eachElement := range original {
+		value, ok := doc[eachElement.Key]
+		if !ok {
+			unsetDocument = append(unsetDocument, bson.E{Key: eachElement.Key, Value: ""})
+			continue
+		}
+		if reflect.DeepEqual(value, before[eachElement.Key]) {
+			document = append(document, eachElement)
+			continue
+		}
+		document = append(document, bson.E{Key: eachElement.Key, Value: value})
+		setDocument = append(setDocument, bson.E{Key: eachElement.Key, Value: value})
+	}
+	newKeyList := make([]string, 0)
+	for eachKey := range doc {
+		if _, ok := before[eachKey]; !ok {
+			newKeyList = append(newKeyList, eachKey)
+		}
+	}
+	sort.Strings(newKeyList)
+	for _, eachKey := range newKeyList {
+		document = append(document, bson.E{Key: eachKey, Value: doc[eachKey]})
+		setDocument = append(setDocument, bson.E{Key: eachKey, Value: doc[eachKey]})
+	}
+
+	update = bson.D{{Key: "$set", Value: setDocument}}
+	if len(unsetDocument) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unsetDocument})
+	}
+	return document, update, oldVersion, nil
+}
+
+// upgrade the documents of the repository on read
+func WithSchemaUpgrader(upgrader *SchemaUpgrader) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.schemaUpgrader = upgrader
+	}
+}
+
+// decode the current document of the cursor,upgrade it first if the repository has a SchemaUpgrader
+func (r *findResult) decodeCursor(val interface{}) error {
+	if r.configuration.schemaUpgrader == nil {
+		return r.cur.Decode(val)
+	}
+	return r.decodeRaw(r.cur.Current, val)
+}
+
+// upgrade raw to the current schema version and decode it into val
+func (r *findResult) decodeRaw(raw bson.Raw, val interface{}) error {
+	upgrader := r.configuration.schemaUpgrader
+	document, update, oldVersion, err := upgrader.upgradeDocument(raw)
+	if err != nil {
+		return err
+	}
+	if update != nil {
+		if upgrader.writeBack && r.collection != nil {
+			r.writeBack(upgrader, oldVersion, document, update)
+		}
+		data, err := bson.Marshal(document)
+		if err != nil {
+			return err
+		}
+		raw = data
+	}
+	return bson.Unmarshal(raw, val)
+}
+
+// write the changes of the upgrade if the stored document still has the old version,so that the
+// fields written by others since the read are kept.
+// failure is only logged because the document is upgraded again on the next read
+func (r *findResult) writeBack(upgrader *SchemaUpgrader, oldVersion int, document bson.D, update bson.D) {
+	id, ok := lookupBsonPath(document, "_id")
+	if !ok {
+		return
+	}
+	filter := bson.M{"$and": bson.A{bson.M{"_id": id}, upgrader.versionFilter(oldVersion)}}
+	if err := NewMongoCol(r.collection, r.configuration).UpdateOne(filter, update); err != nil {
+		slog.Warn("mongodb schema version write back failed",
+			slog.String("collection", r.collection.Name()),
+			slog.Any("_id", id),
+			slog.Any("error", err))
+	}
+}
+
+// decode every document of the cursor into the slice pointed by val
+func (r *findResult) decodeAllUpgraded(ctx context.Context, val interface{}) error {
+	resultsVal := reflect.ValueOf(val)
+	if resultsVal.Kind() != reflect.Ptr || resultsVal.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("results argument must be a pointer to a slice,but was %T", val)
+	}
+	defer r.cur.Close(context.Background())
+
+	sliceVal := resultsVal.Elem()
+	sliceVal = sliceVal.Slice(0, 0)
+	elementType := sliceVal.Type().Elem()
+	for r.cur.Next(ctx) {
+		elem := reflect.New(elementType)
+		if err := r.decodeRaw(r.cur.Current, elem.Interface()); err != nil {
+			return err
+		}
+		sliceVal = reflect.Append(sliceVal, elem.Elem())
+	}
+	if err := r.cur.Err(); err != nil {
+		return err
+	}
+	resultsVal.Elem().Set(sliceVal)
+	return nil
+}
+
+// set the schema version of doc to the current version,doc is returned unchanged if the
+// repository has no SchemaUpgrader
+func (c *Configuration) stampSchemaVersion(doc interface{}) (interface{}, error) {
+	upgrader := c.schemaUpgrader
+	if upgrader == nil || doc == nil {
+		return doc, nil
+	}
+	document, ok := toBsonD(doc)
+	if !ok {
+		return nil, fmt.Errorf("cannot set schema version of %T", doc)
+	}
+	for i := range document {
+		if document[i].Key == upgrader.field {
+			document[i].Value = upgrader.current
+			return document, nil
+		}
+	}
+	return append(document, bson.E{Key: upgrader.field, Value: upgrader.current}), nil
+}
+
+// upgrade the documents stored with an old schema version in batches,run it in a goroutine
+// to migrate the remaining documents in the background. return the number of upgraded documents.
+// the documents are read in the order of _id and each one is visited once,only the fields changed
+// by the upgrade are written and the documents upgraded by others since they are read are left as they are
+func (r *MongoCol) UpgradeSchemaVersions(ctx context.Context, batchSize int) (int64, error) {
+	upgrader := r.configuration.schemaUpgrader
+	if upgrader == nil {
+		return 0, errors.New("repository has no schema upgrader")
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	oldVersionFilter := bson.M{"$or": bson.A{
+		bson.M{upgrader.field: bson.M{"$exists": false}},
+		bson.M{upgrader.field: bson.M{"$lt": upgrader.current}},
+	}}
+	col := r.WithContext(ctx)
+	findOptions := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(batchSize))
+
+	var (
+		total  int64
+		lastId interface{}
+	)
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+		filter := oldVersionFilter
+		if lastId != nil {
+			filter = bson.M{"$and": bson.A{oldVersionFilter, bson.M{"_id": bson.M{"$gt": lastId}}}}
+		}
+		rawList := make([]bson.Raw, 0, batchSize)
+		err := col.execute("find", filter, func(op *operation) error {
+			cur, err := op.collection.Find(op.ctx, filter, findOptions)
+			if err != nil {
+				return err
+			}
+			if err := cur.All(op.ctx, &rawList); err != nil {
+				return err
+			}
+			op.returned = int64(len(rawList))
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		if len(rawList) <= 0 {
+			return total, nil
+		}
+		lastId = rawList[len(rawList)-1].Lookup("_id")
+
+		modelList := make([]mongo.WriteModel, 0, len(rawList))
+		for _, eachRaw := range rawList {
+			id := eachRaw.Lookup("_id")
+			_, update, oldVersion, err := upgrader.upgradeDocument(eachRaw)
+			if err != nil {
+				return total, fmt.Errorf("document %v: %w", id, err)
+			}
+			if update == nil {
+				continue
+			}
+			modelList = append(modelList, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"$and": bson.A{bson.M{"_id": id}, upgrader.versionFilter(oldVersion)}}).
+				SetUpdate(update))
+		}
+		if len(modelList) <= 0 {
+			continue
+		}
+		res, err := col.BulkWrite(modelList, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return total, err
+		}
+		total += res.ModifiedCount
+	}
+}
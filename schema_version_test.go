@@ -0,0 +1,109 @@
+package mongodbr
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSchemaUpgraderUpgradeDocument(t *testing.T) {
+	upgrader := NewSchemaUpgrader().
+		Register(1, func(doc bson.M) error {
+			doc["fullName"] = doc["name"]
+			delete(doc, "name")
+			return nil
+		}).
+		Register(2, func(doc bson.M) error {
+			doc["price"] = int32(2)
+			return nil
+		})
+	testCases := []struct {
+		name       string
+		document   bson.D
+		expect     bson.D
+		update     bson.D
+		oldVersion int
+	}{
+		{
+			name: "from version 1",
+			document: bson.D{
+				{Key: "_id", Value: int32(1)},
+				{Key: "name", Value: "a"},
+				{Key: "tags", Value: bson.A{"x"}},
+				{Key: "price", Value: int32(1)},
+			},
+			expect: bson.D{
+				{Key: "_id", Value: int32(1)},
+				{Key: "tags", Value: bson.A{"x"}},
+				{Key: "price", Value: int32(2)},
+				{Key: "fullName", Value: "a"},
+				{Key: "schemaVersion", Value: 3},
+			},
+			update: bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "price", Value: int32(2)},
+					{Key: "fullName", Value: "a"},
+					{Key: "schemaVersion", Value: 3},
+				}},
+				{Key: "$unset", Value: bson.D{{Key: "name", Value: ""}}},
+			},
+			oldVersion: 1,
+		},
+		{
+			name: "from version 2",
+			document: bson.D{
+				{Key: "_id", Value: int32(1)},
+				{Key: "schemaVersion", Value: int32(2)},
+				{Key: "fullName", Value: "a"},
+				{Key: "price", Value: int32(1)},
+			},
+			expect: bson.D{
+				{Key: "_id", Value: int32(1)},
+				{Key: "schemaVersion", Value: 3},
+				{Key: "fullName", Value: "a"},
+				{Key: "price", Value: int32(2)},
+			},
+			update: bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "schemaVersion", Value: 3},
+					{Key: "price", Value: int32(2)},
+				}},
+			},
+			oldVersion: 2,
+		},
+		{
+			name: "current version",
+			document: bson.D{
+				{Key: "_id", Value: int32(1)},
+				{Key: "schemaVersion", Value: int32(3)},
+			},
+			expect: bson.D{
+				{Key: "_id", Value: int32(1)},
+				{Key: "schemaVersion", Value: int32(3)},
+			},
+			oldVersion: 3,
+		},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			raw, err := bson.Marshal(eachCase.document)
+			if err != nil {
+				t.Fatal(err)
+			}
+			document, update, oldVersion, err := upgrader.upgradeDocument(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if oldVersion != eachCase.oldVersion {
+				t.Fatalf("expect old version %d,got %d", eachCase.oldVersion, oldVersion)
+			}
+			if !reflect.DeepEqual(document, eachCase.expect) {
+				t.Fatalf("expect document %v,got %v", eachCase.expect, document)
+			}
+			if !reflect.DeepEqual(update, eachCase.update) {
+				t.Fatalf("expect update %v,got %v", eachCase.update, update)
+			}
+		})
+	}
+}
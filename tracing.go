@@ -0,0 +1,196 @@
+package mongodbr
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// attribute keys of the repository spans,follow the opentelemetry database conventions
+const (
+	TraceAttributeDbSystem        = "db.system"
+	TraceAttributeDbName          = "db.name"
+	TraceAttributeCollection      = "db.mongodb.collection"
+	TraceAttributeOperation       = "db.operation"
+	TraceAttributeFilterShape     = "db.mongodb.filter_shape"
+	TraceAttributeReturned        = "db.mongodb.documents.returned"
+	TraceAttributeMatched         = "db.mongodb.documents.matched"
+	TraceAttributeModified        = "db.mongodb.documents.modified"
+	TraceAttributeDeleted         = "db.mongodb.documents.deleted"
+	TraceAttributeInserted        = "db.mongodb.documents.inserted"
+	TraceAttributeUpserted        = "db.mongodb.documents.upserted"
+	TraceAttributeCommandName     = "db.mongodb.command_name"
+	TraceAttributeRequestId       = "db.mongodb.request_id"
+	TraceAttributeDurationNanos   = "db.mongodb.duration_ns"
+	TraceAttributeCommandFailure  = "db.mongodb.failure"
+	TraceAttributeConnectionId    = "db.mongodb.connection_id"
+	traceAttributeDbSystemMongodb = "mongodb"
+
+	filterShapePlaceholder = "?"
+)
+
+type TraceAttribute struct {
+	Key   string
+	Value interface{}
+}
+
+func NewTraceAttribute(key string, value interface{}) TraceAttribute {
+	return TraceAttribute{Key: key, Value: value}
+}
+
+// Span is a unit of work started by a Tracer
+type Span interface {
+	SetAttributes(attrs ...TraceAttribute)
+	AddEvent(name string, attrs ...TraceAttribute)
+	RecordError(err error)
+	End()
+}
+
+// Tracer starts a span for every repository operation,the returned context carries the span
+// to the driver so that the command events are linked to it
+type Tracer interface {
+	Start(ctx context.Context, spanName string, attrs ...TraceAttribute) (context.Context, Span)
+}
+
+var _defaultTracer Tracer
+
+// tracer used by the repositories without their own tracer
+func SetDefaultTracer(tracer Tracer) {
+	_defaultTracer = tracer
+}
+
+// trace the operations of the repository with tracer
+func WithTracer(tracer Tracer) RepositoryOption {
+	return func(configuration *Configuration) {
+		configuration.tracer = tracer
+	}
+}
+
+func (c *Configuration) getTracer() Tracer {
+	if c.tracer != nil {
+		return c.tracer
+	}
+	return _defaultTracer
+}
+
+type spanContextKey struct{}
+
+// the context carrying span,used to link the driver command events to the span
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// the span of the repository operation carried by ctx,nil if none
+func SpanFromContext(ctx context.Context) Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(Span)
+	return span
+}
+
+// add the command events of the driver to the span of the repository operation which runs the command.
+// the monitor is chained with the monitor already set in the client options
+func EnableTraceMonitor() func(*options.ClientOptions) {
+	monitor := &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			span := SpanFromContext(ctx)
+			if span == nil {
+				return
+			}
+			span.AddEvent("mongodb.command.started",
+				NewTraceAttribute(TraceAttributeCommandName, e.CommandName),
+				NewTraceAttribute(TraceAttributeRequestId, e.RequestID),
+				NewTraceAttribute(TraceAttributeDbName, e.DatabaseName),
+				NewTraceAttribute(TraceAttributeConnectionId, e.ConnectionID))
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			span := SpanFromContext(ctx)
+			if span == nil {
+				return
+			}
+			span.AddEvent("mongodb.command.succeeded",
+				NewTraceAttribute(TraceAttributeCommandName, e.CommandName),
+				NewTraceAttribute(TraceAttributeRequestId, e.RequestID),
+				NewTraceAttribute(TraceAttributeDurationNanos, e.DurationNanos))
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			span := SpanFromContext(ctx)
+			if span == nil {
+				return
+			}
+			span.AddEvent("mongodb.command.failed",
+				NewTraceAttribute(TraceAttributeCommandName, e.CommandName),
+				NewTraceAttribute(TraceAttributeRequestId, e.RequestID),
+				NewTraceAttribute(TraceAttributeDurationNanos, e.DurationNanos),
+				NewTraceAttribute(TraceAttributeCommandFailure, e.Failure))
+		},
+	}
+	return func(co *options.ClientOptions) {
+		co.SetMonitor(chainCommandMonitor(co.Monitor, monitor))
+	}
+}
+
+// shape of the filter with the values replaced by ?,keys are sorted
+func FilterShape(filter interface{}) string {
+	if filter == nil {
+		return ""
+	}
+	//the filter is wrapped so that documents and pipelines of any type are converted
+	wrapper, ok := toBsonD(bson.M{"filter": filter})
+	if !ok || len(wrapper) <= 0 {
+		return ""
+	}
+	builder := &strings.Builder{}
+	writeFilterShape(builder, normalizeBsonValue(wrapper[0].Value))
+	return builder.String()
+}
+
+func writeFilterShape(builder *strings.Builder, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keyList := make([]string, 0, len(v))
+		for eachKey := range v {
+			keyList = append(keyList, eachKey)
+		}
+		sort.Strings(keyList)
+		builder.WriteString("{")
+		for i, eachKey := range keyList {
+			if i > 0 {
+				builder.WriteString(",")
+			}
+			builder.WriteString(strconv.Quote(eachKey))
+			builder.WriteString(":")
+			writeFilterShape(builder, v[eachKey])
+		}
+		builder.WriteString("}")
+	case []interface{}:
+		//documents in arrays such as $and:[...] keep their shape,other values are stripped
+		hasDocument := false
+		for _, eachItem := range v {
+			if _, ok := eachItem.(map[string]interface{}); ok {
+				hasDocument = true
+				break
+			}
+		}
+		if !hasDocument {
+			builder.WriteString(strconv.Quote(filterShapePlaceholder))
+			return
+		}
+		builder.WriteString("[")
+		for i, eachItem := range v {
+			if i > 0 {
+				builder.WriteString(",")
+			}
+			writeFilterShape(builder, eachItem)
+		}
+		builder.WriteString("]")
+	default:
+		builder.WriteString(strconv.Quote(filterShapePlaceholder))
+	}
+}
@@ -0,0 +1,281 @@
+package mongodbr
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// mongodbr:"upsertKey" marks a field of the natural key
+	upsertKeyDirective = "upsertKey"
+	// mongodbr:"insertOnly" marks a field written only when the document is inserted
+	insertOnlyDirective = "insertOnly"
+)
+
+var (
+	ErrNoUpsertKey = errors.New("no upsert key field,use UpsertWithKeyFields or the upsertKey tag")
+
+	// fields of Entity and CreationAuditedEntity written only when the document is inserted
+	DefaultInsertOnlyFields = []string{"_id", "creationTime", "creatorId"}
+)
+
+type upsertOptions struct {
+	keyFieldList        []string
+	insertOnlyFieldList []string
+}
+
+type UpsertOption func(*upsertOptions)
+
+// match the existing document by the fields,such as UpsertWithKeyFields("sku") or
+// UpsertWithKeyFields("tenantId", "email").
+// default is the fields tagged by mongodbr:"upsertKey"
+func UpsertWithKeyFields(fieldList ...string) UpsertOption {
+	return func(o *upsertOptions) {
+		o.keyFieldList = append(o.keyFieldList, fieldList...)
+	}
+}
+
+// write the fields with $setOnInsert in addition to DefaultInsertOnlyFields and the fields
+// tagged by mongodbr:"insertOnly",the dotted paths such as meta.createdAt are accepted
+func UpsertWithInsertOnlyFields(fieldList ...string) UpsertOption {
+	return func(o *upsertOptions) {
+		o.insertOnlyFieldList = append(o.insertOnlyFieldList, fieldList...)
+	}
+}
+
+// which items of UpsertMany were inserted and which updated an existing document
+type UpsertResult struct {
+	// positions of the inserted items
+	InsertedIndexes []int
+	// positions of the items matching an existing document,not set if the upsert failed
+	UpdatedIndexes []int
+	// _id of the inserted documents by position
+	UpsertedIDs   map[int]interface{}
+	MatchedCount  int64
+	ModifiedCount int64
+}
+
+// insert item or update the document having the same key fields,return true if it is inserted.
+//
+//	type Product struct {
+//		mongodbr.AuditedEntity `bson:",inline"`
+//		Sku   string  `bson:"sku" mongodbr:"upsertKey"`
+//		Price float64 `bson:"price"`
+//	}
+func (r *MongoCol) UpsertOne(item interface{}, opts ...UpsertOption) (inserted bool, err error) {
+	if item == nil {
+		return false, newError(ErrorCodeNilItem, nil, "collection", r.collection.Name())
+	}
+	o := newUpsertOptions(item, opts)
+	filter, update, err := r.buildUpsert(item, o)
+	if err != nil {
+		return false, err
+	}
+	err = r.executeWithIdempotency("updateOne", filter, func() bool { return isIdempotentUpdate(update) }, func(op *operation) error {
+		result, err := op.collection.UpdateOne(op.ctx, filter, update, options.Update().SetUpsert(true))
+		op.setUpdateResult(result)
+		if err == nil {
+			inserted = result.UpsertedCount > 0
+		}
+		return err
+	})
+	return inserted, err
+}
+
+// insert or update the items in one bulk write by the key fields
+func (r *MongoCol) UpsertMany(itemList []interface{}, opts ...UpsertOption) (*UpsertResult, error) {
+	if len(itemList) <= 0 {
+		return &UpsertResult{}, nil
+	}
+	o := newUpsertOptions(itemList[0], opts)
+	modelList := make([]mongo.WriteModel, 0, len(itemList))
+	for i, eachItem := range itemList {
+		if eachItem == nil {
+			return nil, fmt.Errorf("item %d: %w", i, newError(ErrorCodeNilItem, nil, "collection", r.collection.Name()))
+		}
+		filter, update, err := r.buildUpsert(eachItem, o)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		modelList = append(modelList, mongo.NewUpdateOneModel().
+			SetFilter(filter).
+			SetUpdate(update).
+			SetUpsert(true))
+	}
+	bulkResult, err := r.BulkWrite(modelList)
+
+	result := &UpsertResult{
+		InsertedIndexes: make([]int, 0),
+		UpsertedIDs:     make(map[int]interface{}),
+	}
+	if bulkResult != nil {
+		result.MatchedCount = bulkResult.MatchedCount
+		result.ModifiedCount = bulkResult.ModifiedCount
+		for eachIndex, eachId := range bulkResult.UpsertedIDs {
+			result.UpsertedIDs[int(eachIndex)] = eachId
+		}
+	}
+	for i := range itemList {
+		if _, ok := result.UpsertedIDs[i]; ok {
+			result.InsertedIndexes = append(result.InsertedIndexes, i)
+		}
+	}
+	if err != nil {
+		return result, err
+	}
+	result.UpdatedIndexes = make([]int, 0, len(itemList)-len(result.InsertedIndexes))
+	for i := range itemList {
+		if _, ok := result.UpsertedIDs[i]; !ok {
+			result.UpdatedIndexes = append(result.UpdatedIndexes, i)
+		}
+	}
+	return result, nil
+}
+
+// the key fields and the insert only fields of the options and the tags of item
+func newUpsertOptions(item interface{}, opts []UpsertOption) *upsertOptions {
+	o := &upsertOptions{}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	tagKeyList, tagInsertOnlyList := parseUpsertTags(reflect.TypeOf(item))
+	if len(o.keyFieldList) <= 0 {
+		o.keyFieldList = tagKeyList
+	}
+	o.insertOnlyFieldList = append(append(o.insertOnlyFieldList, DefaultInsertOnlyFields...), tagInsertOnlyList...)
+	return o
+}
+
+func parseUpsertTags(t reflect.Type) (keyFieldList []string, insertOnlyFieldList []string) {
+	if t == nil || indirectType(t).Kind() != reflect.Struct {
+		return nil, nil
+	}
+	walkBsonFields(t, func(path string, field reflect.StructField) {
+		for _, eachDirective := range parseTagDirectives(field) {
+			switch eachDirective {
+			case upsertKeyDirective:
+				keyFieldList = append(keyFieldList, path)
+			case insertOnlyDirective:
+				insertOnlyFieldList = append(insertOnlyFieldList, path)
+			}
+		}
+	})
+	return keyFieldList, insertOnlyFieldList
+}
+
+// filter by the key fields,$setOnInsert for the insert only fields and $set for the others
+func (r *MongoCol) buildUpsert(item interface{}, o *upsertOptions) (filter bson.D, update bson.D, err error) {
+	if len(o.keyFieldList) <= 0 {
+		return nil, nil, ErrNoUpsertKey
+	}
+	if hook, ok := item.(IEntityBeforeCreate); ok {
+		hook.BeforeCreate()
+	}
+	if hook, ok := item.(IEntityBeforeUpdate); ok {
+		hook.BeforeUpdate()
+	}
+	value, err := r.configuration.stampSchemaVersion(item)
+	if err != nil {
+		return nil, nil, err
+	}
+	document, ok := toBsonD(value)
+	if !ok {
+		return nil, nil, fmt.Errorf("cannot upsert %T,a document is required", item)
+	}
+
+	filter = make(bson.D, 0, len(o.keyFieldList))
+	for _, eachKeyField := range o.keyFieldList {
+		keyValue, ok := lookupBsonPath(document, eachKeyField)
+		if !ok {
+			return nil, nil, fmt.Errorf("upsert key field %s is missing", eachKeyField)
+		}
+		filter = append(filter, bson.E{Key: eachKeyField, Value: keyValue})
+	}
+
+	insertOnlyMap := make(map[string]bool, len(o.insertOnlyFieldList))
+	for _, eachField := range o.insertOnlyFieldList {
+		insertOnlyMap[eachField] = true
+	}
+	setDocument := bson.D{}
+	setOnInsertDocument := bson.D{}
+	for _, eachElement := range document {
+		o.splitUpsertField(eachElement.Key, eachElement.Value, insertOnlyMap, &setDocument, &setOnInsertDocument)
+	}
+	update = bson.D{}
+	if len(setDocument) > 0 {
+		update = append(update, bson.E{Key: "$set", Value: setDocument})
+	}
+	if len(setOnInsertDocument) > 0 {
+		update = append(update, bson.E{Key: "$setOnInsert", Value: setOnInsertDocument})
+	}
+	if len(update) <= 0 {
+		update = append(update, bson.E{Key: "$setOnInsert", Value: bson.D{}})
+	}
+	return filter, update, nil
+}
+
+// add the field to $set or $setOnInsert by its dotted path,the embedded documents having nested key
+// or insert only fields are flattened into dotted paths so that those fields are written as declared
+func (o *upsertOptions) splitUpsertField(path string, value interface{}, insertOnlyMap map[string]bool, setDocument *bson.D, setOnInsertDocument *bson.D) {
+	if isUpsertKeyField(path, o.keyFieldList) {
+		//the key fields are written by the filter on insert
+		return
+	}
+	if insertOnlyMap[path] {
+		*setOnInsertDocument = append(*setOnInsertDocument, bson.E{Key: path, Value: value})
+		return
+	}
+	subDocument, ok := value.(bson.D)
+	if ok && len(subDocument) > 0 && (hasNestedField(path, o.keyFieldList) || hasNestedField(path, o.insertOnlyFieldList)) {
+		for _, eachElement := range subDocument {
+			o.splitUpsertField(path+"."+eachElement.Key, eachElement.Value, insertOnlyMap, setDocument, setOnInsertDocument)
+		}
+		return
+	}
+	*setDocument = append(*setDocument, bson.E{Key: path, Value: value})
+}
+
+// a field of the list is within the embedded document of path
+func hasNestedField(path string, fieldList []string) bool {
+	for _, eachField := range fieldList {
+		if strings.HasPrefix(eachField, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// the field is a key field
+func isUpsertKeyField(field string, keyFieldList []string) bool {
+	for _, eachKeyField := range keyFieldList {
+		if eachKeyField == field {
+			return true
+		}
+	}
+	return false
+}
+
+// value of the dotted path within document
+func lookupBsonPath(document bson.D, path string) (interface{}, bool) {
+	head, rest, nested := strings.Cut(path, ".")
+	for _, eachElement := range document {
+		if eachElement.Key != head {
+			continue
+		}
+		if !nested {
+			return eachElement.Value, true
+		}
+		subDocument, ok := eachElement.Value.(bson.D)
+		if !ok {
+			return nil, false
+		}
+		return lookupBsonPath(subDocument, rest)
+	}
+	return nil, false
+}
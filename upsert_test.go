@@ -0,0 +1,74 @@
+package mongodbr
+
+import (
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type upsertTestMeta struct {
+	CreatedBy string `bson:"createdBy" mongodbr:"insertOnly"`
+	Source    string `bson:"source"`
+}
+
+type upsertTestProduct struct {
+	Sku   string         `bson:"sku" mongodbr:"upsertKey"`
+	Price float64        `bson:"price"`
+	Meta  upsertTestMeta `bson:"meta"`
+}
+
+func TestBuildUpsert(t *testing.T) {
+	item := &upsertTestProduct{
+		Sku:   "a",
+		Price: 1,
+		Meta:  upsertTestMeta{CreatedBy: "importer", Source: "csv"},
+	}
+	testCases := []struct {
+		name        string
+		opts        []UpsertOption
+		filter      bson.D
+		set         bson.D
+		setOnInsert bson.D
+	}{
+		{
+			name:        "tags",
+			filter:      bson.D{{Key: "sku", Value: "a"}},
+			set:         bson.D{{Key: "price", Value: 1.0}, {Key: "meta.source", Value: "csv"}},
+			setOnInsert: bson.D{{Key: "meta.createdBy", Value: "importer"}},
+		},
+		{
+			name:        "key fields",
+			opts:        []UpsertOption{UpsertWithKeyFields("meta.source")},
+			filter:      bson.D{{Key: "meta.source", Value: "csv"}},
+			set:         bson.D{{Key: "sku", Value: "a"}, {Key: "price", Value: 1.0}},
+			setOnInsert: bson.D{{Key: "meta.createdBy", Value: "importer"}},
+		},
+		{
+			name:        "insert only option",
+			opts:        []UpsertOption{UpsertWithInsertOnlyFields("price")},
+			filter:      bson.D{{Key: "sku", Value: "a"}},
+			set:         bson.D{{Key: "meta.source", Value: "csv"}},
+			setOnInsert: bson.D{{Key: "price", Value: 1.0}, {Key: "meta.createdBy", Value: "importer"}},
+		},
+	}
+	r := &MongoCol{configuration: NewConfiguration()}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			filter, update, err := r.buildUpsert(item, newUpsertOptions(item, eachCase.opts))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(filter, eachCase.filter) {
+				t.Errorf("expect filter %v,got %v", eachCase.filter, filter)
+			}
+			expectUpdate := bson.D{
+				{Key: "$set", Value: eachCase.set},
+				{Key: "$setOnInsert", Value: eachCase.setOnInsert},
+			}
+			if !reflect.DeepEqual(update, expectUpdate) {
+				t.Errorf("expect update %v,got %v", expectUpdate, update)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package mongodbr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	mongodbrerr "github.com/shanluzhineng/mongodbr/err"
+)
+
+// operation types of the change events
+const (
+	ChangeOperationInsert       = "insert"
+	ChangeOperationUpdate       = "update"
+	ChangeOperationReplace      = "replace"
+	ChangeOperationDelete       = "delete"
+	ChangeOperationDrop         = "drop"
+	ChangeOperationRename       = "rename"
+	ChangeOperationDropDatabase = "dropDatabase"
+	ChangeOperationInvalidate   = "invalidate"
+)
+
+type ChangeNamespace struct {
+	Database   string `bson:"db"`
+	Collection string `bson:"coll"`
+}
+
+type TruncatedArray struct {
+	Field   string `bson:"field"`
+	NewSize int32  `bson:"newSize"`
+}
+
+// the fields changed by an update event
+type UpdateDescription struct {
+	UpdatedFields   bson.M           `bson:"updatedFields"`
+	RemovedFields   []string         `bson:"removedFields"`
+	TruncatedArrays []TruncatedArray `bson:"truncatedArrays,omitempty"`
+}
+
+// ChangeEvent is an event of a change stream
+type ChangeEvent struct {
+	// resume token of the event
+	ID            bson.Raw            `bson:"_id"`
+	OperationType string              `bson:"operationType"`
+	ClusterTime   primitive.Timestamp `bson:"clusterTime"`
+	Namespace     ChangeNamespace     `bson:"ns"`
+	DocumentKey   bson.M              `bson:"documentKey,omitempty"`
+	// the document after the change,only for insert and replace unless WatchWithFullDocument is set
+	FullDocument bson.Raw `bson:"fullDocument,omitempty"`
+	// the document before the change,needs WatchWithFullDocumentBeforeChange and changeStreamPreAndPostImages
+	// of the collection
+	FullDocumentBeforeChange bson.Raw           `bson:"fullDocumentBeforeChange,omitempty"`
+	UpdateDescription        *UpdateDescription `bson:"updateDescription,omitempty"`
+}
+
+// _id of the changed document
+func (e *ChangeEvent) DocumentID() interface{} {
+	return e.DocumentKey["_id"]
+}
+
+// decode the full document into v,return mongo.ErrNoDocuments if the event has no full document
+func (e *ChangeEvent) DecodeFullDocument(v interface{}) error {
+	if len(e.FullDocument) <= 0 {
+		return mongo.ErrNoDocuments
+	}
+	return bson.Unmarshal(e.FullDocument, v)
+}
+
+func (e *ChangeEvent) DecodeFullDocumentBeforeChange(v interface{}) error {
+	if len(e.FullDocumentBeforeChange) <= 0 {
+		return mongo.ErrNoDocuments
+	}
+	return bson.Unmarshal(e.FullDocumentBeforeChange, v)
+}
+
+type watchOptions struct {
+	filter         interface{}
+	changeStream   *options.ChangeStreamOptions
+	checkpoint     CheckpointStore
+	checkpointName string
+}
+
+type WatchOption func(*watchOptions)
+
+// only the events matching filter,such as bson.M{"operationType": bson.M{"$in": bson.A{"insert", "update"}}}
+func WatchWithFilter(filter interface{}) WatchOption {
+	return func(o *watchOptions) {
+		o.filter = filter
+	}
+}
+
+// such as options.UpdateLookup to get the full document of the update events
+func WatchWithFullDocument(fullDocument options.FullDocument) WatchOption {
+	return func(o *watchOptions) {
+		o.changeStream.SetFullDocument(fullDocument)
+	}
+}
+
+// such as options.WhenAvailable,needs mongodb 6.0
+func WatchWithFullDocumentBeforeChange(fullDocument options.FullDocument) WatchOption {
+	return func(o *watchOptions) {
+		o.changeStream.SetFullDocumentBeforeChange(fullDocument)
+	}
+}
+
+func WatchWithBatchSize(batchSize int32) WatchOption {
+	return func(o *watchOptions) {
+		o.changeStream.SetBatchSize(batchSize)
+	}
+}
+
+// start from the operation time when there is no checkpoint
+func WatchWithStartAtOperationTime(t primitive.Timestamp) WatchOption {
+	return func(o *watchOptions) {
+		o.changeStream.SetStartAtOperationTime(&t)
+	}
+}
+
+// resume after the token saved in store as name,ChangeStream.Commit and ChangeStream.Run save
+// the token of the handled events
+func WatchWithCheckpoint(store CheckpointStore, name string) WatchOption {
+	return func(o *watchOptions) {
+		o.checkpoint = store
+		o.checkpointName = name
+	}
+}
+
+type IEntityWatch interface {
+	Watch(ctx context.Context, pipeline interface{}, opts ...WatchOption) (*ChangeStream, error)
+}
+
+var _ IEntityWatch = (*MongoCol)(nil)
+
+// ChangeStream iterates the change events
+type ChangeStream struct {
+	stream         *mongo.ChangeStream
+	event          *ChangeEvent
+	err            error
+	checkpoint     CheckpointStore
+	checkpointName string
+}
+
+// watch the changes of the collection,pipeline is appended after the filter of WatchWithFilter,
+// it can be nil
+func (r *MongoCol) Watch(ctx context.Context, pipeline interface{}, opts ...WatchOption) (*ChangeStream, error) {
+	var changeStream *ChangeStream
+	err := r.WithContext(ctx).execute("watch", pipeline, func(op *operation) (err error) {
+		changeStream, err = openChangeStream(op.ctx, pipeline, opts, func(ctx context.Context, pipeline interface{}, o *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+			return op.collection.Watch(ctx, pipeline, o)
+		})
+		return err
+	})
+	return changeStream, err
+}
+
+// watch the changes of every collection of the database
+func WatchDatabase(ctx context.Context, database *mongo.Database, pipeline interface{}, opts ...WatchOption) (*ChangeStream, error) {
+	changeStream, err := openChangeStream(ctx, pipeline, opts, func(ctx context.Context, pipeline interface{}, o *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+		return database.Watch(ctx, pipeline, o)
+	})
+	return changeStream, mongodbrerr.Wrap(err)
+}
+
+// watch the changes of every database of the client
+func WatchClient(ctx context.Context, client *mongo.Client, pipeline interface{}, opts ...WatchOption) (*ChangeStream, error) {
+	changeStream, err := openChangeStream(ctx, pipeline, opts, func(ctx context.Context, pipeline interface{}, o *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+		return client.Watch(ctx, pipeline, o)
+	})
+	return changeStream, mongodbrerr.Wrap(err)
+}
+
+func openChangeStream(ctx context.Context, pipeline interface{}, opts []WatchOption,
+	watch func(ctx context.Context, pipeline interface{}, o *options.ChangeStreamOptions) (*mongo.ChangeStream, error)) (*ChangeStream, error) {
+	o := &watchOptions{
+		changeStream: options.ChangeStream(),
+	}
+	for _, eachOpt := range opts {
+		eachOpt(o)
+	}
+	stageList := mongo.Pipeline{}
+	if o.filter != nil {
+		stageList = append(stageList, bson.D{{Key: "$match", Value: o.filter}})
+	}
+	if pipeline != nil {
+		pipelineStageList, ok := toStageList(pipeline)
+		if !ok {
+			return nil, fmt.Errorf("invalid change stream pipeline %T", pipeline)
+		}
+		stageList = append(stageList, pipelineStageList...)
+	}
+	if o.checkpoint != nil {
+		token, err := o.checkpoint.Load(ctx, o.checkpointName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the checkpoint %s: %w", o.checkpointName, err)
+		}
+		if len(token) > 0 {
+			o.changeStream.SetResumeAfter(token)
+			o.changeStream.StartAtOperationTime = nil
+		}
+	}
+	stream, err := watch(ctx, stageList, o.changeStream)
+	if err != nil {
+		return nil, err
+	}
+	return &ChangeStream{
+		stream:         stream,
+		checkpoint:     o.checkpoint,
+		checkpointName: o.checkpointName,
+	}, nil
+}
+
+// wait for the next event,return false if ctx is done,the stream is closed or fails
+func (s *ChangeStream) Next(ctx context.Context) bool {
+	s.event = nil
+	if !s.stream.Next(ctx) {
+		return false
+	}
+	event := &ChangeEvent{}
+	if err := s.stream.Decode(event); err != nil {
+		s.err = err
+		return false
+	}
+	s.event = event
+	return true
+}
+
+// the event of the last Next
+func (s *ChangeStream) Event() *ChangeEvent {
+	return s.event
+}
+
+func (s *ChangeStream) Err() error {
+	if s.err != nil {
+		return s.err
+	}
+	return mongodbrerr.Wrap(s.stream.Err())
+}
+
+// token to resume after the last event
+func (s *ChangeStream) ResumeToken() bson.Raw {
+	return s.stream.ResumeToken()
+}
+
+// save the resume token to the checkpoint store,the stream resumes after the last event when it
+// is opened again
+func (s *ChangeStream) Commit(ctx context.Context) error {
+	if s.checkpoint == nil {
+		return nil
+	}
+	token := s.stream.ResumeToken()
+	if len(token) <= 0 {
+		return nil
+	}
+	return s.checkpoint.Save(ctx, s.checkpointName, token)
+}
+
+func (s *ChangeStream) Close(ctx context.Context) error {
+	return s.stream.Close(ctx)
+}
+
+// call handler for each event until ctx is done or handler fails,the checkpoint is committed
+// after each handled event.the stream is closed when Run returns
+func (s *ChangeStream) Run(ctx context.Context, handler func(ctx context.Context, event *ChangeEvent) error) error {
+	defer s.Close(context.Background())
+	for s.Next(ctx) {
+		if err := handler(ctx, s.event); err != nil {
+			return err
+		}
+		if err := s.Commit(ctx); err != nil {
+			return err
+		}
+	}
+	if err := s.Err(); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,162 @@
+package mongodbr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type watchTestCheckpointStore struct {
+	token bson.Raw
+	err   error
+}
+
+func (s *watchTestCheckpointStore) Load(context.Context, string) (bson.Raw, error) {
+	return s.token, s.err
+}
+
+func (s *watchTestCheckpointStore) Save(context.Context, string, bson.Raw) error {
+	return nil
+}
+
+func TestOpenChangeStreamPipeline(t *testing.T) {
+	filter := bson.M{"operationType": "insert"}
+	testCases := []struct {
+		name     string
+		pipeline interface{}
+		opts     []WatchOption
+		expect   bson.A
+		wantErr  bool
+	}{
+		{"empty", nil, nil, bson.A{}, false},
+		{"filter only", nil, []WatchOption{WatchWithFilter(filter)}, bson.A{bson.D{{Key: "$match", Value: filter}}}, false},
+		{
+			"filter before the pipeline",
+			mongo.Pipeline{{{Key: "$project", Value: bson.M{"fullDocument": 1}}}},
+			[]WatchOption{WatchWithFilter(filter)},
+			bson.A{bson.D{{Key: "$match", Value: filter}}, bson.D{{Key: "$project", Value: bson.M{"fullDocument": 1}}}},
+			false,
+		},
+		{
+			"pipeline as bson.A",
+			bson.A{bson.M{"$match": bson.M{"ns.coll": "a"}}, bson.D{{Key: "$project", Value: bson.M{"ns": 1}}}},
+			nil,
+			bson.A{bson.D{{Key: "$match", Value: bson.M{"ns.coll": "a"}}}, bson.D{{Key: "$project", Value: bson.M{"ns": 1}}}},
+			false,
+		},
+		{"invalid pipeline", "$match", nil, nil, true},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			var stageList interface{}
+			_, err := openChangeStream(context.Background(), eachCase.pipeline, eachCase.opts, func(ctx context.Context, pipeline interface{}, o *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+				stageList = pipeline
+				return nil, nil
+			})
+			if eachCase.wantErr {
+				if err == nil {
+					t.Fatalf("expect an error,got %v", stageList)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !sameBson(t, stageList, eachCase.expect) {
+				t.Fatalf("expect %v,got %v", eachCase.expect, stageList)
+			}
+		})
+	}
+}
+
+func TestOpenChangeStreamCheckpoint(t *testing.T) {
+	token, _ := bson.Marshal(bson.M{"_data": "826"})
+	startAt := primitive.Timestamp{T: 1, I: 1}
+	loadErr := errors.New("load failed")
+	testCases := []struct {
+		name          string
+		store         *watchTestCheckpointStore
+		expectToken   bool
+		expectStartAt bool
+		expectErr     error
+	}{
+		{"no checkpoint", nil, false, true, nil},
+		{"no token saved", &watchTestCheckpointStore{}, false, true, nil},
+		{"token replaces the start time", &watchTestCheckpointStore{token: token}, true, false, nil},
+		{"load error", &watchTestCheckpointStore{err: loadErr}, false, false, loadErr},
+	}
+	for _, eachCase := range testCases {
+		t.Run(eachCase.name, func(t *testing.T) {
+			opts := []WatchOption{WatchWithStartAtOperationTime(startAt)}
+			if eachCase.store != nil {
+				opts = append(opts, WatchWithCheckpoint(eachCase.store, "orders"))
+			}
+			var changeStreamOptions *options.ChangeStreamOptions
+			changeStream, err := openChangeStream(context.Background(), nil, opts, func(ctx context.Context, pipeline interface{}, o *options.ChangeStreamOptions) (*mongo.ChangeStream, error) {
+				changeStreamOptions = o
+				return nil, nil
+			})
+			if eachCase.expectErr != nil {
+				if !errors.Is(err, eachCase.expectErr) || changeStreamOptions != nil {
+					t.Fatalf("expect %v before watching,got %v", eachCase.expectErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (changeStreamOptions.ResumeAfter != nil) != eachCase.expectToken {
+				t.Fatalf("expect resume after %v,got %v", eachCase.expectToken, changeStreamOptions.ResumeAfter)
+			}
+			if (changeStreamOptions.StartAtOperationTime != nil) != eachCase.expectStartAt {
+				t.Fatalf("expect start at operation time %v,got %v", eachCase.expectStartAt, changeStreamOptions.StartAtOperationTime)
+			}
+			if eachCase.store != nil && (changeStream.checkpoint != eachCase.store || changeStream.checkpointName != "orders") {
+				t.Fatal("expect the checkpoint kept by the change stream")
+			}
+		})
+	}
+}
+
+func TestMemoryCheckpointStoreCopy(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryCheckpointStore()
+	if token, err := store.Load(ctx, "orders"); err != nil || token != nil {
+		t.Fatalf("expect nil before Save,got %v %v", token, err)
+	}
+
+	token, _ := bson.Marshal(bson.M{"_data": "826"})
+	expect := append(bson.Raw(nil), token...)
+	if err := store.Save(ctx, "orders", token); err != nil {
+		t.Fatal(err)
+	}
+	token[len(token)-2] = 'x'
+	loaded, _ := store.Load(ctx, "orders")
+	if !bytes.Equal(loaded, expect) {
+		t.Fatalf("the saved token is changed by the caller of Save,got %v", loaded)
+	}
+	loaded[len(loaded)-2] = 'x'
+	if loaded, _ = store.Load(ctx, "orders"); !bytes.Equal(loaded, expect) {
+		t.Fatalf("the saved token is changed by the caller of Load,got %v", loaded)
+	}
+}
+
+// compare the encoded documents,the values decoded by the pipeline helpers have other go types
+func sameBson(t *testing.T, a interface{}, b interface{}) bool {
+	t.Helper()
+	encodedA, err := bson.Marshal(bson.M{"v": a})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedB, err := bson.Marshal(bson.M{"v": b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Equal(encodedA, encodedB)
+}